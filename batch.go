@@ -0,0 +1,174 @@
+package wordspell
+
+import (
+	"context"
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/cannonflesh/wordspell/options"
+)
+
+// Result - результат исправления одного запроса пакета или потока.
+type Result struct {
+	Request   string
+	Corrected string
+	// Confidence - уверенность в исправлении, см. Correction.
+	Confidence float64
+	// Err - ошибка исправления запроса: отмена ctx или истекший Batch.Timeout
+	// (context.Canceled, context.DeadlineExceeded). Corrected тогда пуст.
+	// Таймаут проверяется между токенами и между удалениями слова при поиске исправления,
+	// так что запрос может превысить его не больше чем на перебор вставок в одно удаление.
+	Err error
+}
+
+// CorrectBatch исправляет пакет запросов пулом из opt.Workers горутин. Одинаковые запросы исправляются один раз.
+// Результаты отдаются в порядке запросов, ошибки - у каждого запроса своя.
+// Незаданные поля opt берутся из настроек сервиса (Options.Batch).
+func (s *Service) CorrectBatch(ctx context.Context, requests []string, opt options.Batch) []Result {
+	opt = s.batchOptions(opt)
+	res := make([]Result, len(requests))
+
+	// Индексы первых вхождений запросов - только их и нужно исправлять.
+	first := make(map[string]int, len(requests))
+	uniq := make([]int, 0, len(requests))
+	for i, r := range requests {
+		if _, found := first[r]; !found {
+			first[r] = i
+			uniq = append(uniq, i)
+		}
+	}
+
+	workers := opt.Workers
+	if workers > len(uniq) {
+		workers = len(uniq)
+	}
+
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				res[i] = s.correctItem(ctx, requests[i], opt.Timeout)
+			}
+		}()
+	}
+
+	for _, i := range uniq {
+		jobs <- i
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	for i, r := range requests {
+		res[i] = res[first[r]]
+	}
+
+	return res
+}
+
+// CorrectStream исправляет запросы из in пулом горутин согласно Options.Batch и отдает результаты в порядке запросов.
+// Канал результатов закрывается, когда закрыт in и все запросы исправлены, или сразу после отмены ctx:
+// прочитать его до конца или отменить ctx - обязанность вызывающего.
+func (s *Service) CorrectStream(ctx context.Context, in <-chan string) <-chan Result {
+	opt := s.batchOptions(options.Batch{})
+
+	type job struct {
+		request string
+		slot    chan Result
+	}
+
+	res := make(chan Result)
+	// slots хранит ячейки результатов в порядке запросов, его емкость ограничивает число запросов в работе.
+	slots := make(chan chan Result, opt.Workers)
+	jobs := make(chan job)
+
+	for w := 0; w < opt.Workers; w++ {
+		go func() {
+			for j := range jobs {
+				j.slot <- s.correctItem(ctx, j.request, opt.Timeout)
+			}
+		}()
+	}
+
+	go func() {
+		defer close(slots)
+		defer close(jobs)
+
+		for {
+			var (
+				request string
+				ok      bool
+			)
+
+			select {
+			case <-ctx.Done():
+				return
+			case request, ok = <-in:
+				if !ok {
+					return
+				}
+			}
+
+			slot := make(chan Result, 1)
+			select {
+			case <-ctx.Done():
+				return
+			case slots <- slot:
+			}
+
+			jobs <- job{request: request, slot: slot}
+		}
+	}()
+
+	go func() {
+		defer close(res)
+
+		for slot := range slots {
+			// Ячейка заполнится и после отмены ctx: correctItem тогда сразу вернет ошибку.
+			r := <-slot
+
+			select {
+			case <-ctx.Done():
+				return
+			case res <- r:
+			}
+		}
+	}()
+
+	return res
+}
+
+// correctItem исправляет один запрос пакета с таймаутом timeout, если он задан.
+func (s *Service) correctItem(ctx context.Context, request string, timeout time.Duration) Result {
+	if timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	corrected, err := s.correct(ctx, request, defaultCorrectOptions)
+
+	return Result{Request: request, Corrected: corrected.Corrected, Confidence: corrected.Confidence, Err: err}
+}
+
+// batchOptions дополняет opt настройками сервиса, а затем - значениями по умолчанию.
+func (s *Service) batchOptions(opt options.Batch) options.Batch {
+	if opt.Workers <= 0 {
+		opt.Workers = s.batch.Workers
+	}
+	if opt.Workers <= 0 {
+		opt.Workers = runtime.NumCPU()
+	}
+
+	if opt.Timeout <= 0 {
+		opt.Timeout = s.batch.Timeout
+	}
+
+	return opt
+}
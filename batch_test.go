@@ -0,0 +1,117 @@
+package wordspell
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cannonflesh/wordspell/options"
+)
+
+var batchRequests = []string{
+	"организзацияя для безупасност2",
+	"the ящиг",
+	"internationallization",
+	"the ящиг",
+	"really-not-found",
+	"организзацияя для безупасност2",
+	"",
+}
+
+func TestService_CorrectBatch(t *testing.T) {
+	s := testdataSpeller(t, &options.Options{Batch: options.Batch{Workers: 3}})
+
+	t.Run("Ordered", func(t *testing.T) {
+		res := s.CorrectBatch(context.Background(), batchRequests, options.Batch{})
+		require.Len(t, res, len(batchRequests))
+
+		for i, r := range res {
+			require.NoError(t, r.Err)
+			require.Equal(t, batchRequests[i], r.Request)
+			require.Equal(t, s.Correct(batchRequests[i]), r.Corrected, r.Request)
+		}
+
+		require.Equal(t, "организация для безопасности", res[0].Corrected)
+		require.Equal(t, res[1], res[3])
+	})
+	t.Run("Empty", func(t *testing.T) {
+		require.Empty(t, s.CorrectBatch(context.Background(), nil, options.Batch{}))
+	})
+	t.Run("Timeout", func(t *testing.T) {
+		res := s.CorrectBatch(context.Background(), batchRequests, options.Batch{Workers: 2, Timeout: time.Nanosecond})
+		require.Len(t, res, len(batchRequests))
+
+		for i, r := range res {
+			require.ErrorIs(t, r.Err, context.DeadlineExceeded)
+			require.Equal(t, batchRequests[i], r.Request)
+			require.Empty(t, r.Corrected)
+		}
+	})
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		for _, r := range s.CorrectBatch(ctx, batchRequests, options.Batch{}) {
+			require.ErrorIs(t, r.Err, context.Canceled)
+		}
+	})
+}
+
+func TestService_CorrectStream(t *testing.T) {
+	s := testdataSpeller(t, &options.Options{Batch: options.Batch{Workers: 3}})
+
+	t.Run("Ordered", func(t *testing.T) {
+		in := make(chan string)
+		go func() {
+			for _, r := range batchRequests {
+				in <- r
+			}
+			close(in)
+		}()
+
+		var res []Result
+		for r := range s.CorrectStream(context.Background(), in) {
+			res = append(res, r)
+		}
+
+		require.Len(t, res, len(batchRequests))
+		for i, r := range res {
+			require.NoError(t, r.Err)
+			require.Equal(t, batchRequests[i], r.Request)
+			require.Equal(t, s.Correct(batchRequests[i]), r.Corrected, r.Request)
+		}
+	})
+	t.Run("Canceled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		in := make(chan string)
+		res := s.CorrectStream(ctx, in)
+
+		in <- batchRequests[0]
+		require.Equal(t, "организация для безопасности", (<-res).Corrected)
+
+		cancel()
+		// После отмены канал закрывается, хотя in так и не закрыт.
+		for range res {
+		}
+	})
+	t.Run("Timeout", func(t *testing.T) {
+		ts := *s
+		ts.batch.Timeout = time.Nanosecond
+
+		in := make(chan string, len(batchRequests))
+		for _, r := range batchRequests {
+			in <- r
+		}
+		close(in)
+
+		n := 0
+		for r := range ts.CorrectStream(context.Background(), in) {
+			require.ErrorIs(t, r.Err, context.DeadlineExceeded)
+			require.Equal(t, batchRequests[n], r.Request)
+			n++
+		}
+		require.Equal(t, len(batchRequests), n)
+	})
+}
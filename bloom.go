@@ -0,0 +1,398 @@
+package wordspell
+
+import (
+	"runtime"
+	"sync"
+	"time"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/cannonflesh/wordspell/components/bloomfilter"
+	"github.com/cannonflesh/wordspell/components/index"
+	"github.com/cannonflesh/wordspell/components/manifest"
+	"github.com/cannonflesh/wordspell/components/wordmutate"
+	"github.com/cannonflesh/wordspell/internal/hll"
+	"github.com/cannonflesh/wordspell/options"
+)
+
+const (
+	bytesInMB = 1 << 20
+	// falsePositiveProbes - количество случайных строк, на которых замеряется доля ложноположительных ответов фильтра.
+	falsePositiveProbes = 200000
+)
+
+// bloomFilters - фильтры удалений сервиса: общий для всех языков или по одному на язык (Bloom.PerLanguage).
+type bloomFilters struct {
+	shared *bloomfilter.Component
+	byLang map[string]*bloomfilter.Component
+}
+
+// Test проверяет удаление w по фильтру языка lang.
+// Если у языка нет своего фильтра (например, язык не распознан), проверяет по фильтрам всех языков.
+func (b *bloomFilters) Test(lang string, w []byte) bool {
+	if b.shared != nil {
+		return b.shared.TestBytes(w)
+	}
+
+	if f, ok := b.byLang[lang]; ok {
+		return f.TestBytes(w)
+	}
+
+	for _, f := range b.byLang {
+		if f.TestBytes(w) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (b *bloomFilters) set(t bloomTarget, f *bloomfilter.Component) {
+	if t.lang == "" {
+		b.shared = f
+
+		return
+	}
+
+	if b.byLang == nil {
+		b.byLang = make(map[string]*bloomfilter.Component)
+	}
+	b.byLang[t.lang] = f
+}
+
+// bloomTarget описывает один фильтр: его язык (пустой для общего фильтра) и языки индексов, по которым он строится.
+type bloomTarget struct {
+	lang  string
+	langs []string
+}
+
+func bloomTargets(opt *options.Options) []bloomTarget {
+	if !opt.Bloom.PerLanguage {
+		return []bloomTarget{{langs: opt.Langs}}
+	}
+
+	res := make([]bloomTarget, 0, len(opt.Langs))
+	for _, lang := range opt.Langs {
+		res = append(res, bloomTarget{lang: lang, langs: []string{lang}})
+	}
+
+	return res
+}
+
+func (t bloomTarget) newFilter(opt *bloomfilter.Options, store bloomfilter.ReadOnlyStore, l *logrus.Entry) *bloomfilter.Component {
+	if t.lang == "" {
+		return bloomfilter.New(opt, store, l)
+	}
+
+	return bloomfilter.NewLang(t.lang, opt, store, l)
+}
+
+// indexKeys отдает ключи индексов языков langs.
+func indexKeys(langs []string) []string {
+	res := make([]string, 0, len(langs))
+	for _, lang := range langs {
+		res = append(res, index.StoreKey(lang))
+	}
+
+	return res
+}
+
+// loadBloomFilters отдает фильтры для сервиса согласно режиму opt.Bloom.Startup.
+func loadBloomFilters(
+	opt *options.Options,
+	store bloomfilter.ReadOnlyStore,
+	idx *index.Service,
+	mutate *wordmutate.Component,
+	m *manifest.Manifest,
+	l *logrus.Entry,
+) (*bloomFilters, error) {
+	res := &bloomFilters{}
+	for _, t := range bloomTargets(opt) {
+		f, err := loadBloomFilter(&opt.Bloom, t, store, idx, mutate, m, l)
+		if err != nil {
+			return nil, err
+		}
+		res.set(t, f)
+	}
+
+	return res, nil
+}
+
+// loadBloomFilter отдает фильтр t согласно режиму opt.Startup:
+// загружает сохраненный билдером, строит по уже загруженному индексу или делает и то, и другое, сверяя результаты.
+func loadBloomFilter(
+	opt *bloomfilter.Options,
+	t bloomTarget,
+	store bloomfilter.ReadOnlyStore,
+	idx *index.Service,
+	mutate *wordmutate.Component,
+	m *manifest.Manifest,
+	l *logrus.Entry,
+) (*bloomfilter.Component, error) {
+	stored := t.newFilter(opt, store, l)
+	build := func() (*bloomfilter.Component, error) {
+		res := t.newFilter(opt, store, l)
+
+		_, err := buildBloomFilter(res, opt, idx, mutate, t.langs, l)
+
+		return res, err
+	}
+
+	mode := opt.Startup
+	if mode == bloomfilter.StartupBuild {
+		return build()
+	}
+
+	if mode == bloomfilter.StartupFallback {
+		exists, err := store.IsExist(stored.Key())
+		if err != nil {
+			return nil, err
+		}
+		if !exists {
+			l.Warnf("no stored bloom filter %s found, building it from the index", stored.Key())
+
+			return build()
+		}
+	}
+
+	if m != nil {
+		if err := m.RequireDerived(stored.Key(), indexKeys(t.langs)...); err != nil {
+			return nil, err
+		}
+		if err := requireDistances(m, mutate); err != nil {
+			return nil, err
+		}
+	}
+
+	startLoadBloom := time.Now()
+	if err := stored.Load(); err != nil {
+		return nil, err
+	}
+	l.Infof("bloom %s loaded in %s", stored.Key(), time.Since(startLoadBloom))
+
+	if mode != bloomfilter.StartupValidate {
+		return stored, nil
+	}
+
+	built, err := build()
+	if err != nil {
+		return nil, err
+	}
+	if !stored.Equal(built) {
+		l.Warnf("stored bloom filter %s differs from the one built from the index, using the built one", stored.Key())
+
+		return built, nil
+	}
+	l.Infof("stored bloom filter %s is valid", stored.Key())
+
+	return stored, nil
+}
+
+// requireDistances проверяет, что сохраненные фильтры построены с теми же расстояниями редактирования, что настроены сейчас:
+// иначе в фильтре не окажется части удалений, которые будет проверять спеллер.
+// Манифесты без расстояний записаны до их появления, с расстояниями по умолчанию.
+func requireDistances(m *manifest.Manifest, mutate *wordmutate.Component) error {
+	built := m.Options.Distances
+	if built == "" {
+		built = wordmutate.New().Policy()
+	}
+
+	if built != mutate.Policy() {
+		return errors.Errorf("bloom filter was built with max edit distances %s, configured %s", built, mutate.Policy())
+	}
+
+	return nil
+}
+
+// buildBloomFilter строит фильтр по словам языков langs и сообщает, сколько времени и памяти на это ушло,
+// а также расчетную и измеренную долю ложноположительных ответов.
+func buildBloomFilter(
+	bFilter *bloomfilter.Component,
+	opt *bloomfilter.Options,
+	idx *index.Service,
+	mutate *wordmutate.Component,
+	langs []string,
+	l *logrus.Entry,
+) (*manifest.FilterStats, error) {
+	workers := opt.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+
+	l.Infof("[BLOOM FILTER BUILD] start building %s, workers: %d", bFilter.Key(), workers)
+
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+	start := time.Now()
+
+	distinct, err := fillBloomFilter(bFilter, idx, mutate, workers, langs...)
+	if err != nil {
+		return nil, err
+	}
+
+	runtime.ReadMemStats(&after)
+	l.Infof(
+		"[BLOOM FILTER BUILD] %s built in %v, filter size: %d bits, allocated: %d MB, heap in use: %d MB",
+		bFilter.Key(),
+		time.Since(start),
+		bFilter.BitsCount(),
+		(after.TotalAlloc-before.TotalAlloc)/bytesInMB,
+		after.HeapInuse/bytesInMB,
+	)
+
+	estimated, err := idx.DeletesEstimated(langs...)
+	if err != nil {
+		return nil, err
+	}
+
+	stats := &manifest.FilterStats{
+		Type:                      bFilter.FilterType(),
+		Bits:                      uint64(bFilter.BitsCount()),
+		MemoryBudget:              bFilter.MemoryBudget(),
+		EstimatedDeletes:          uint64(estimated),
+		DistinctDeletes:           distinct,
+		FalsePositiveRate:         bFilter.FalsePositiveRate(),
+		MeasuredFalsePositiveRate: bFilter.MeasureFalsePositiveRate(falsePositiveProbes),
+	}
+	l.Infof(
+		"[BLOOM FILTER BUILD] %s: type: %s, deletes estimated: %d, distinct: %d, memory budget: %d bytes, "+
+			"false positive rate: %g, measured: %g",
+		bFilter.Key(),
+		stats.Type,
+		stats.EstimatedDeletes,
+		stats.DistinctDeletes,
+		stats.MemoryBudget,
+		stats.FalsePositiveRate,
+		stats.MeasuredFalsePositiveRate,
+	)
+
+	return stats, nil
+}
+
+// fillBloomFilter заполняет фильтр удалениями слов индекса (только языков langs, если они заданы)
+// и отдает оценку количества уникальных удалений.
+//
+// Слова делятся на workers равных частей, каждая горутина перебирает удаления своей части через EachDelete,
+// без аллокаций, считает уникальные удаления в собственном HyperLogLog-скетче и пишет их в собственный шард фильтра.
+// В конце скетчи объединяются, а шарды сливаются. Первая часть пишется прямо в bFilter,
+// так что дополнительно потребуется память на workers-1 шардов.
+//
+// Если фильтру нужен размер до заполнения (bFilter.NeedsSize), проходов два: сначала только скетчи,
+// фильтр создается под полученное количество (DeletesEstimated не учитывает повторы и заметно завышает размер),
+// затем только шарды. Статический фильтр без бюджета памяти заполняется за один проход.
+func fillBloomFilter(
+	bFilter *bloomfilter.Component,
+	idx *index.Service,
+	mutate *wordmutate.Component,
+	workers int,
+	langs ...string,
+) (uint64, error) {
+	words := idx.WordList(langs...)
+	if workers > len(words) {
+		workers = len(words)
+	}
+	if workers < 1 {
+		bFilter.Reset(0)
+
+		return 0, bFilter.Seal()
+	}
+
+	sketches := make([]*hll.Sketch, workers)
+	for i := range sketches {
+		sk, err := hll.New(hll.DefaultPrecision)
+		if err != nil {
+			return 0, err
+		}
+		sketches[i] = sk
+	}
+
+	sized := bFilter.NeedsSize()
+	if sized {
+		forEachChunk(words, workers, func(i int, part []string) {
+			addDeletes(mutate, part, sketches[i], nil)
+		})
+	}
+
+	distinct := uint64(0)
+	countDistinct := func() error {
+		for _, sk := range sketches[1:] {
+			if err := sketches[0].Merge(sk); err != nil {
+				return err
+			}
+		}
+		distinct = sketches[0].Count()
+
+		return nil
+	}
+
+	if sized {
+		if err := countDistinct(); err != nil {
+			return 0, err
+		}
+	}
+	bFilter.Reset(uint(distinct))
+
+	shards := make([]*bloomfilter.Component, workers)
+	shards[0] = bFilter
+	for i := 1; i < workers; i++ {
+		shards[i] = bFilter.Shard()
+	}
+
+	forEachChunk(words, workers, func(i int, part []string) {
+		if sized {
+			addDeletes(mutate, part, nil, shards[i])
+
+			return
+		}
+		addDeletes(mutate, part, sketches[i], shards[i])
+	})
+
+	if !sized {
+		if err := countDistinct(); err != nil {
+			return 0, err
+		}
+	}
+
+	if err := bFilter.Merge(shards[1:]...); err != nil {
+		return 0, err
+	}
+
+	return distinct, bFilter.Seal()
+}
+
+// addDeletes перебирает удаления слов part и добавляет каждое в скетч sk и в шард shard, если они заданы.
+func addDeletes(mutate *wordmutate.Component, part []string, sk *hll.Sketch, shard *bloomfilter.Component) {
+	add := func(d []byte) bool {
+		if sk != nil {
+			sk.Add(d)
+		}
+		if shard != nil {
+			shard.AddBytes(d)
+		}
+
+		return true
+	}
+
+	for _, w := range part {
+		mutate.EachDelete(w, add)
+	}
+}
+
+// forEachChunk делит words на workers равных частей и обрабатывает каждую в своей горутине.
+func forEachChunk(words []string, workers int, fn func(i int, part []string)) {
+	chunk := (len(words) + workers - 1) / workers
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < workers; i++ {
+		start, end := min(i*chunk, len(words)), min((i+1)*chunk, len(words))
+
+		wg.Add(1)
+		go func(i int, part []string) {
+			defer wg.Done()
+
+			fn(i, part)
+		}(i, words[start:end])
+	}
+	wg.Wait()
+}
@@ -0,0 +1,369 @@
+package wordspell
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"math/rand"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cannonflesh/wordspell/components/bloomfilter"
+	"github.com/cannonflesh/wordspell/components/index"
+	"github.com/cannonflesh/wordspell/components/langdetect"
+	"github.com/cannonflesh/wordspell/components/manifest"
+	"github.com/cannonflesh/wordspell/components/wordmutate"
+	"github.com/cannonflesh/wordspell/domain"
+	"github.com/cannonflesh/wordspell/options"
+	"github.com/cannonflesh/wordspell/repo/embedded"
+	"github.com/cannonflesh/wordspell/repo/memory"
+	"github.com/cannonflesh/wordspell/testdata"
+)
+
+func TestService_loadBloomFilter(t *testing.T) {
+	lgr, _ := testdata.NewTestLogger()
+
+	// В testdata.Indexes только индексы: ни bloom-фильтра, ни манифеста.
+	withoutBloom := embedded.New(testdata.Indexes)
+	skipVerify := manifest.Options{SkipVerify: true}
+
+	t.Run("LoadMissing", func(t *testing.T) {
+		_, err := newService(&options.Options{Manifest: skipVerify}, withoutBloom, lgr)
+		require.EqualError(t, err, "no bloom filter data found: bloom.dat")
+	})
+	t.Run("Fallback", func(t *testing.T) {
+		lgr, lbuf := testdata.NewTestLogger()
+		opt := &options.Options{
+			Bloom:    bloomfilter.Options{Startup: bloomfilter.StartupFallback},
+			Manifest: skipVerify,
+		}
+
+		s, err := newService(opt, withoutBloom, lgr)
+		require.NoError(t, err)
+		require.Equal(t, "для", s.Correct("длf"))
+		require.Contains(t, lbuf.String(), "no stored bloom filter bloom.dat found, building it from the index")
+		require.Contains(t, lbuf.String(), "[BLOOM FILTER BUILD] bloom.dat built in")
+	})
+	t.Run("FallbackStored", func(t *testing.T) {
+		lgr, lbuf := testdata.NewTestLogger()
+		store, _ := goldenGeneration(t)
+		opt := &options.Options{Bloom: bloomfilter.Options{Startup: bloomfilter.StartupFallback}}
+
+		_, err := newService(opt, store, lgr)
+		require.NoError(t, err)
+		require.Contains(t, lbuf.String(), "bloom bloom.dat loaded in")
+		require.NotContains(t, lbuf.String(), "[BLOOM FILTER BUILD]")
+	})
+	t.Run("Build", func(t *testing.T) {
+		lgr, lbuf := testdata.NewTestLogger()
+		store, _ := goldenGeneration(t)
+		opt := &options.Options{Bloom: bloomfilter.Options{Startup: bloomfilter.StartupBuild, Workers: 2}}
+
+		s, err := newService(opt, store, lgr)
+		require.NoError(t, err)
+		require.Equal(t, "для", s.Correct("длf"))
+		require.Contains(t, lbuf.String(), "[BLOOM FILTER BUILD] start building bloom.dat, workers: 2")
+		require.NotContains(t, lbuf.String(), "bloom bloom.dat loaded in")
+	})
+	t.Run("Validate", func(t *testing.T) {
+		lgr, lbuf := testdata.NewTestLogger()
+		store, _ := goldenGeneration(t)
+		opt := &options.Options{Bloom: bloomfilter.Options{Startup: bloomfilter.StartupValidate}}
+
+		_, err := newService(opt, store, lgr)
+		require.NoError(t, err)
+		require.Contains(t, lbuf.String(), "stored bloom filter bloom.dat is valid")
+	})
+	t.Run("ValidateStale", func(t *testing.T) {
+		lgr, lbuf := testdata.NewTestLogger()
+		store, _ := goldenGeneration(t)
+
+		stale := bloomfilter.New(&bloomfilter.Options{}, store, lgr)
+		stale.Reset(100)
+		stale.Add("хрензначо")
+		require.NoError(t, stale.Save())
+
+		opt := &options.Options{
+			Bloom:    bloomfilter.Options{Startup: bloomfilter.StartupValidate},
+			Manifest: skipVerify,
+		}
+
+		s, err := newService(opt, store, lgr)
+		require.NoError(t, err)
+		require.Equal(t, "для", s.Correct("длf"))
+		require.Contains(t, lbuf.String(), "stored bloom filter bloom.dat differs from the one built from the index, using the built one")
+	})
+}
+
+func TestService_BinaryFuseFilter(t *testing.T) {
+	lgr, _ := testdata.NewTestLogger()
+	opt := &options.Options{Langs: []string{domain.EnLangCode, domain.RuLangCode}}
+
+	idx, err := index.NewService(opt, langdetect.New(), embedded.New(testdata.Indexes), lgr)
+	require.NoError(t, err)
+
+	store := memory.New()
+	fuseOpt := &bloomfilter.Options{Filter: bloomfilter.FilterBinaryFuse, Workers: 3}
+	built := bloomfilter.New(fuseOpt, store, lgr)
+	_, err = buildBloomFilter(built, fuseOpt, idx, wordmutate.New(), nil, lgr)
+	require.NoError(t, err)
+	require.NoError(t, built.Save())
+
+	// Тип фильтра берется из заголовка сохраненных данных, а не из настроек.
+	loaded := bloomfilter.New(&bloomfilter.Options{}, store, lgr)
+	require.NoError(t, loaded.Load())
+	require.True(t, built.Equal(loaded))
+
+	bloom := bloomfilter.New(&bloomfilter.Options{}, store, lgr)
+	_, err = buildBloomFilter(bloom, &bloomfilter.Options{}, idx, wordmutate.New(), nil, lgr)
+	require.NoError(t, err)
+	require.Less(t, loaded.BitsCount(), bloom.BitsCount())
+
+	s, err := newService(&options.Options{Manifest: manifest.Options{SkipVerify: true}}, &overlayStore{
+		ReadOnlyStore: embedded.New(testdata.Indexes),
+		bloom:         store,
+	}, lgr)
+	require.NoError(t, err)
+	require.Equal(t, "для", s.Correct("длf"))
+}
+
+// overlayStore читает bloom-фильтр из отдельного хранилища.
+type overlayStore struct {
+	index.ReadOnlyStore
+	bloom index.ReadOnlyStore
+}
+
+func (s *overlayStore) DataReader(key string) (io.ReadCloser, error) {
+	if strings.HasSuffix(key, bloomfilter.StoreKey) {
+		return s.bloom.DataReader(key)
+	}
+
+	return s.ReadOnlyStore.DataReader(key)
+}
+
+func (s *overlayStore) IsExist(key string) (bool, error) {
+	if strings.HasSuffix(key, bloomfilter.StoreKey) {
+		return s.bloom.IsExist(key)
+	}
+
+	return s.ReadOnlyStore.IsExist(key)
+}
+
+func TestService_PerLanguageBloomFilters(t *testing.T) {
+	lgr, lbuf := testdata.NewTestLogger()
+	langs := []string{domain.EnLangCode, domain.RuLangCode}
+
+	idx, err := index.NewService(&options.Options{Langs: langs}, langdetect.New(), embedded.New(testdata.Indexes), lgr)
+	require.NoError(t, err)
+
+	store := memory.New()
+	opt := &options.Options{
+		Bloom: bloomfilter.Options{
+			FalsePositiveRate: 0.01,
+			Startup:           bloomfilter.StartupBuild,
+			PerLanguage:       true,
+			Langs: map[string]bloomfilter.LangOptions{
+				domain.EnLangCode: {FalsePositiveRate: 0.0001},
+			},
+		},
+		Langs: langs,
+	}
+
+	built, err := loadBloomFilters(opt, store, idx, wordmutate.New(), nil, lgr)
+	require.NoError(t, err)
+	require.Nil(t, built.shared)
+	require.Len(t, built.byLang, 2)
+
+	en, ru := built.byLang[domain.EnLangCode], built.byLang[domain.RuLangCode]
+	require.Equal(t, "en.bloom.dat", en.Key())
+	require.Equal(t, "ru.bloom.dat", ru.Key())
+
+	// У английского фильтра своя, более строгая доля ложноположительных ответов.
+	enDeletes, err := idx.DeletesEstimated(domain.EnLangCode)
+	require.NoError(t, err)
+	ruDeletes, err := idx.DeletesEstimated(domain.RuLangCode)
+	require.NoError(t, err)
+	require.Greater(t, float64(en.BitsCount())/float64(enDeletes), float64(ru.BitsCount())/float64(ruDeletes)*1.5)
+
+	require.True(t, built.Test(domain.EnLangCode, []byte("th")))
+	require.False(t, built.Test(domain.RuLangCode, []byte("th")))
+	require.True(t, built.Test(domain.UnknownLangCode, []byte("th")))
+	require.True(t, built.Test(domain.RuLangCode, []byte("дл")))
+
+	require.NoError(t, en.Save())
+	require.NoError(t, ru.Save())
+	require.Contains(t, lbuf.String(), "[BLOOM FILTER BUILD] start building en.bloom.dat")
+
+	opt.Bloom.Startup = bloomfilter.StartupLoad
+	s, err := newService(opt, &overlayStore{ReadOnlyStore: embedded.New(testdata.Indexes), bloom: store}, lgr)
+	require.ErrorContains(t, err, "no manifest found")
+
+	opt.Manifest.SkipVerify = true
+	s, err = newService(opt, &overlayStore{ReadOnlyStore: embedded.New(testdata.Indexes), bloom: store}, lgr)
+	require.NoError(t, err)
+	require.Equal(t, "для", s.Correct("длf"))
+	require.Contains(t, lbuf.String(), "bloom ru.bloom.dat loaded in")
+}
+
+func TestFillBloomFilter_Workers(t *testing.T) {
+	lgr, _ := testdata.NewTestLogger()
+	opt := &options.Options{Langs: []string{domain.EnLangCode, domain.RuLangCode}}
+
+	idx, err := index.NewService(opt, langdetect.New(), embedded.New(testdata.Indexes), lgr)
+	require.NoError(t, err)
+
+	sequential := bloomfilter.New(&bloomfilter.Options{}, nil, lgr)
+	_, err = fillBloomFilter(sequential, idx, wordmutate.New(), 1)
+	require.NoError(t, err)
+
+	parallel := bloomfilter.New(&bloomfilter.Options{}, nil, lgr)
+	_, err = fillBloomFilter(parallel, idx, wordmutate.New(), 8)
+	require.NoError(t, err)
+
+	require.True(t, sequential.Equal(parallel))
+}
+
+func TestFillBloomFilter_DistinctDeletes(t *testing.T) {
+	lgr, _ := testdata.NewTestLogger()
+	opt := &options.Options{Langs: []string{domain.EnLangCode, domain.RuLangCode}}
+
+	idx, err := index.NewService(opt, langdetect.New(), embedded.New(testdata.Indexes), lgr)
+	require.NoError(t, err)
+
+	mutate := wordmutate.New()
+	exact := make(map[string]struct{})
+	for _, w := range idx.WordList() {
+		for _, d := range mutate.Deletes(w) {
+			exact[d] = struct{}{}
+		}
+	}
+
+	estimated, err := idx.DeletesEstimated()
+	require.NoError(t, err)
+
+	// bloom-фильтр заполняется в два прохода, binary fuse - в один.
+	for _, filter := range []string{bloomfilter.FilterBloom, bloomfilter.FilterBinaryFuse} {
+		bloom := bloomfilter.New(&bloomfilter.Options{Filter: filter}, nil, lgr)
+		distinct, err := fillBloomFilter(bloom, idx, mutate, 4)
+		require.NoError(t, err)
+		require.InEpsilon(t, float64(len(exact)), float64(distinct), 0.03, filter)
+		require.Greater(t, uint64(estimated), distinct, filter)
+
+		for d := range exact {
+			require.True(t, bloom.Test(d), "filter: %s, delete: %s", filter, d)
+		}
+	}
+}
+
+func TestBuildBloomFilter_MemoryBudget(t *testing.T) {
+	lgr, lbuf := testdata.NewTestLogger()
+	opt := &options.Options{Langs: []string{domain.EnLangCode, domain.RuLangCode}}
+
+	idx, err := index.NewService(opt, langdetect.New(), embedded.New(testdata.Indexes), lgr)
+	require.NoError(t, err)
+
+	const budget = 64 << 10
+
+	bloomOpt := &bloomfilter.Options{MemoryBudget: budget, Workers: 2}
+	bloom := bloomfilter.New(bloomOpt, nil, lgr)
+	stats, err := buildBloomFilter(bloom, bloomOpt, idx, wordmutate.New(), nil, lgr)
+	require.NoError(t, err)
+
+	require.Equal(t, "bloom", stats.Type)
+	require.Equal(t, uint64(budget), stats.MemoryBudget)
+	require.InEpsilon(t, budget*8, float64(stats.Bits), 0.05)
+	require.Less(t, stats.DistinctDeletes, stats.EstimatedDeletes)
+	require.InDelta(t, stats.FalsePositiveRate, stats.MeasuredFalsePositiveRate, stats.FalsePositiveRate)
+	require.Contains(t, lbuf.String(), "[BLOOM FILTER BUILD] bloom.dat: type: bloom, deletes estimated: ")
+
+	fuseOpt := &bloomfilter.Options{Filter: bloomfilter.FilterBinaryFuse, MemoryBudget: budget}
+	fuse := bloomfilter.New(fuseOpt, nil, lgr)
+	stats, err = buildBloomFilter(fuse, fuseOpt, idx, wordmutate.New(), nil, lgr)
+	require.NoError(t, err)
+	require.Equal(t, "fuse8", stats.Type)
+
+	fuseOpt.MemoryBudget = 4 * budget
+	fuse = bloomfilter.New(fuseOpt, nil, lgr)
+	stats, err = buildBloomFilter(fuse, fuseOpt, idx, wordmutate.New(), nil, lgr)
+	require.NoError(t, err)
+	require.Equal(t, "fuse16", stats.Type)
+	require.Less(t, stats.MeasuredFalsePositiveRate, 0.001)
+}
+
+func TestFillBloomFilter_MoreWorkersThanChunks(t *testing.T) {
+	idx := syntheticIndex(t, 5)
+	lgr, _ := testdata.NewTestLogger()
+
+	sequential := bloomfilter.New(&bloomfilter.Options{}, nil, lgr)
+	_, err := fillBloomFilter(sequential, idx, wordmutate.New(), 1)
+	require.NoError(t, err)
+
+	for _, workers := range []int{2, 4, 5, 16} {
+		parallel := bloomfilter.New(&bloomfilter.Options{}, nil, lgr)
+		_, err = fillBloomFilter(parallel, idx, wordmutate.New(), workers)
+		require.NoError(t, err)
+		require.True(t, sequential.Equal(parallel), "workers: %d", workers)
+	}
+}
+
+var (
+	benchIndexOnce sync.Once
+	benchIndex     *index.Service
+)
+
+func BenchmarkFillBloomFilter(b *testing.B) {
+	benchIndexOnce.Do(func() {
+		benchIndex = syntheticIndex(b, 1_000_000)
+	})
+	lgr, _ := testdata.NewTestLogger()
+
+	for _, workers := range []int{1, 2, 4, 8} {
+		b.Run(fmt.Sprintf("workers=%d", workers), func(b *testing.B) {
+			b.ReportAllocs()
+
+			for i := 0; i < b.N; i++ {
+				bloom := bloomfilter.New(&bloomfilter.Options{FalsePositiveRate: 0.01}, nil, lgr)
+				if _, err := fillBloomFilter(bloom, benchIndex, wordmutate.New(), workers); err != nil {
+					b.Fatal(err)
+				}
+			}
+		})
+	}
+}
+
+// syntheticIndex строит русский индекс из n случайных слов длиной от 3 до 14 рун.
+func syntheticIndex(tb testing.TB, n int) *index.Service {
+	lgr, _ := testdata.NewTestLogger()
+	alphabet := []rune("абвгдеёжзийклмнопрстуфхцчшщъыьэюя")
+	rnd := rand.New(rand.NewSource(1))
+
+	buf := bytes.Buffer{}
+	word := make([]rune, 0, 14)
+	for i := 0; i < n; i++ {
+		word = word[:0]
+		for l := 3 + rnd.Intn(12); l > 0; l-- {
+			word = append(word, alphabet[rnd.Intn(len(alphabet))])
+		}
+
+		buf.WriteString(string(word))
+		buf.WriteByte('\t')
+		buf.WriteString(strconv.Itoa(1 + rnd.Intn(1000)))
+		buf.WriteByte('\n')
+	}
+
+	store := memory.New()
+	if err := store.Save(index.StoreKey(domain.RuLangCode), &buf); err != nil {
+		tb.Fatal(err)
+	}
+
+	idx, err := index.NewService(&options.Options{Langs: []string{domain.RuLangCode}}, langdetect.New(), store, lgr)
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	return idx
+}
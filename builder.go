@@ -1,6 +1,7 @@
 package wordspell
 
 import (
+	"strings"
 	"time"
 
 	"github.com/sirupsen/logrus"
@@ -8,6 +9,7 @@ import (
 	"github.com/cannonflesh/wordspell/components/bloomfilter"
 	"github.com/cannonflesh/wordspell/components/index"
 	"github.com/cannonflesh/wordspell/components/langdetect"
+	"github.com/cannonflesh/wordspell/components/manifest"
 	"github.com/cannonflesh/wordspell/components/trademarkindex"
 	"github.com/cannonflesh/wordspell/components/wordmutate"
 	"github.com/cannonflesh/wordspell/domain"
@@ -15,22 +17,39 @@ import (
 	"github.com/cannonflesh/wordspell/internal/s3"
 	"github.com/cannonflesh/wordspell/options"
 	"github.com/cannonflesh/wordspell/repo/catalog"
+	"github.com/cannonflesh/wordspell/repo/compress"
 	s3repo "github.com/cannonflesh/wordspell/repo/s3"
 )
 
+// publisher публикует собранное поколение артефактов и удаляет устаревшие.
+type publisher interface {
+	Publish(generation string) error
+	Cleanup() ([]string, error)
+}
+
 type Builder struct {
 	opt *options.Options
 
+	itemSource      index.DataSource
+	tradeMarkSource trademarkindex.DataSource
+	langs           *langdetect.Component
+
+	// generationStore отдает хранилище артефактов поколения generation.
+	generationStore func(generation string) (index.DataStore, error)
+	// publisher публикует поколение после записи манифеста, nil - поколений нет.
+	publisher publisher
+
+	// Билдеры индексов и recorder создаются заново для каждой сборки: у каждой - свое поколение.
 	indexBuilder          *index.Builder
 	tradeMarkIndexBuilder *trademarkindex.Builder
+	// recorder собирает контрольные суммы всех артефактов поколения для манифеста.
+	recorder *manifest.Recorder
 
 	store  index.DataStore
 	logger *logrus.Entry
 }
 
 func NewBuilder(opt *options.Options, l *logrus.Entry) (*Builder, error) {
-	lang := langdetect.New()
-
 	pgConn, err := postgres.New(&opt.SiteDB)
 	if err != nil {
 		return nil, err
@@ -41,7 +60,7 @@ func NewBuilder(opt *options.Options, l *logrus.Entry) (*Builder, error) {
 	if err != nil {
 		return nil, err
 	}
-	store, err := s3repo.NewStore(s3cli, opt.S3Data)
+	s3store, err := s3repo.NewStore(s3cli, opt.S3Data)
 	if err != nil {
 		return nil, err
 	}
@@ -49,71 +68,149 @@ func NewBuilder(opt *options.Options, l *logrus.Entry) (*Builder, error) {
 	return &Builder{
 		opt: opt,
 
-		indexBuilder:          index.NewBuilder(source, store, lang, l),
-		tradeMarkIndexBuilder: trademarkindex.NewBuilder(source, store, l),
+		itemSource:      source,
+		tradeMarkSource: source,
+		langs:           langdetect.New(),
+
+		generationStore: func(generation string) (index.DataStore, error) {
+			// Сжатие прозрачно для остальных слоев: контрольные суммы манифеста считаются по несжатым данным.
+			return compress.New(&opt.Compression, s3store.WithGeneration(generation))
+		},
+		publisher: s3store,
 
-		store:  store,
 		logger: l.WithField(domain.CategoryFieldName, "service.indexes_builder"),
 	}, nil
 }
 
+// startGeneration создает новое поколение, его хранилище, recorder и билдеры индексов, пишущие в это поколение.
+func (b *Builder) startGeneration() (string, error) {
+	generation := manifest.NewGeneration()
+
+	store, err := b.generationStore(generation)
+	if err != nil {
+		return "", err
+	}
+
+	b.store = store
+	b.recorder = manifest.NewRecorder()
+	b.indexBuilder = index.NewBuilder(b.opt, b.itemSource, b.recorder.Wrap(store), b.langs, b.logger)
+	b.tradeMarkIndexBuilder = trademarkindex.NewBuilder(b.tradeMarkSource, b.recorder.Wrap(store), b.logger)
+
+	return generation, nil
+}
+
 func (b *Builder) Build() error {
-	err := b.indexBuilder.LoadIndexFromDB()
+	generation, err := b.startGeneration()
 	if err != nil {
 		return err
 	}
+	b.logger.Infof("[BUILD] generation: %s", generation)
 
-	err = b.tradeMarkIndexBuilder.LoadIndexData()
+	err = b.indexBuilder.LoadIndexFromDB()
 	if err != nil {
 		return err
 	}
 
-	idx, err := index.NewService(b.opt, langdetect.New(), b.store, b.logger)
+	err = b.tradeMarkIndexBuilder.LoadIndexData()
 	if err != nil {
 		return err
 	}
 
-	bloom := bloomfilter.New(&b.opt.Bloom, b.store, b.logger)
-
-	b.logger.Info("[BLOOM FILTER BUILD] start building")
-	startBloomBuild := time.Now()
-	err = fillBloomFilter(bloom, idx, wordmutate.New())
+	// Запросы к хранилищу повторяет клиент s3, второй слой повторов здесь не нужен.
+	idx, err := index.NewService(b.opt, langdetect.New(), b.store, b.logger)
 	if err != nil {
 		return err
 	}
-	b.logger.Infof("[BLOOM FILTER BUILD] built in %v", time.Since(startBloomBuild))
 
-	b.logger.Info("[BLOOM FILTER SAVE] start saving")
-	startBloomSave := time.Now()
-	err = bloom.Save()
+	mutate, err := wordmutate.NewWithOptions(&b.opt.Mutate)
 	if err != nil {
 		return err
 	}
-	b.logger.Infof("[BLOOM FILTER SAVE] saved in %v", time.Since(startBloomSave))
 
-	return nil
-}
+	for _, t := range bloomTargets(b.opt) {
+		bloom := t.newFilter(&b.opt.Bloom, b.recorder.Wrap(b.store), b.logger)
+		stats, err := buildBloomFilter(bloom, &b.opt.Bloom, idx, mutate, t.langs, b.logger)
+		if err != nil {
+			return err
+		}
+
+		b.logger.Infof("[BLOOM FILTER SAVE] start saving %s", bloom.Key())
+		startBloomSave := time.Now()
+		err = bloom.Save()
+		if err != nil {
+			return err
+		}
+		b.logger.Infof("[BLOOM FILTER SAVE] %s saved in %v", bloom.Key(), time.Since(startBloomSave))
+
+		b.recorder.Derive(bloom.Key(), indexKeys(t.langs)...)
+		b.recorder.Filter(bloom.Key(), *stats)
+	}
 
-func fillBloomFilter(bFilter *bloomfilter.Component, idx *index.Service, mutate *wordmutate.Component) error {
-	bFilterSize, err := idx.DeletesEstimated()
-	if err != nil {
+	if b.opt.Bloom.Rare.Enabled {
+		rare := bloomfilter.NewRare(&b.opt.Bloom, b.recorder.Wrap(b.store), b.logger)
+		stats, err := buildRareFilter(rare, b.indexBuilder.RareWords(), b.logger)
+		if err != nil {
+			return err
+		}
+
+		if err = rare.Save(); err != nil {
+			return err
+		}
+		b.logger.Infof("[RARE FILTER SAVE] %s saved", rare.Key())
+
+		b.recorder.Filter(rare.Key(), *stats)
+	}
+	// Статистика каталога и редкие слова больше не нужны, а билдер живет до следующей сборки.
+	b.indexBuilder.Release()
+
+	// Манифест пишется последним: его появление означает, что все артефакты поколения сохранены.
+	m := b.recorder.Manifest(
+		generation,
+		manifest.BuildOptions{
+			Langs:             b.opt.Langs,
+			FalsePositiveRate: b.opt.Bloom.FalsePositiveRate,
+			MemoryBudget:      b.opt.Bloom.MemoryBudget,
+			Filter:            b.opt.Bloom.Filter,
+			Thresholds:        b.indexBuilder.Thresholds(),
+			Weight:            b.indexBuilder.Options().Weight,
+			MinItems:          b.indexBuilder.Options().MinItems,
+			Distances:         mutate.Policy(),
+			Compression:       b.opt.Compression.Codec,
+		},
+		idx.WordsCount(),
+	)
+	if err = m.Save(rawStore(b.store)); err != nil {
 		return err
 	}
+	b.logger.Infof("[MANIFEST SAVE] generation %s saved, artifacts: %d", generation, len(m.Artifacts))
 
-	bFilter.Reset(bFilterSize)
-	if err != nil {
-		return err
+	if b.publisher == nil {
+		return nil
 	}
 
-	idxWords, err := idx.Words()
-	if err != nil {
+	if err = b.publisher.Publish(generation); err != nil {
 		return err
 	}
+	b.logger.Infof("[PUBLISH] generation %s published", generation)
 
-	for w := range idxWords {
-		dts := mutate.Deletes(w)
-		bFilter.Add(dts...)
+	removed, err := b.publisher.Cleanup()
+	if err != nil {
+		// Поколение уже опубликовано, старые удалятся при следующей сборке.
+		b.logger.WithError(err).Warn("[PUBLISH] removing outdated generations")
+	}
+	if len(removed) > 0 {
+		b.logger.Infof("[PUBLISH] outdated generations removed: %s", strings.Join(removed, ", "))
 	}
 
 	return nil
 }
+
+// rawStore отдает хранилище, в котором артефакты лежат без сжатия. Манифест всегда пишется несжатым:
+// по нему спеллер узнает, каким алгоритмом сжаты остальные артефакты поколения.
+func rawStore(store index.DataStore) index.DataStore {
+	if cs, ok := store.(*compress.Store); ok {
+		return cs.Raw()
+	}
+
+	return store
+}
@@ -1,7 +1,10 @@
 package wordspell
 
 import (
+	"context"
+	"encoding/json"
 	"io"
+	"sort"
 	"testing"
 
 	"github.com/stretchr/testify/mock"
@@ -10,8 +13,11 @@ import (
 	"github.com/cannonflesh/wordspell/components/bloomfilter"
 	"github.com/cannonflesh/wordspell/components/index"
 	"github.com/cannonflesh/wordspell/components/langdetect"
+	"github.com/cannonflesh/wordspell/components/manifest"
 	"github.com/cannonflesh/wordspell/components/trademarkindex"
+	"github.com/cannonflesh/wordspell/domain"
 	"github.com/cannonflesh/wordspell/options"
+	"github.com/cannonflesh/wordspell/repo/memory"
 	"github.com/cannonflesh/wordspell/testdata"
 )
 
@@ -37,8 +43,9 @@ func TestBuilder_Build(t *testing.T) {
 		Return(catNames, nil).
 		Once()
 
-	idxStore := index.NewMockDataStore(t)
-	idxStore.EXPECT().Save("ru.index", mock.Anything).
+	// Все артефакты поколения пишутся в одно хранилище.
+	store := index.NewMockDataStore(t)
+	store.EXPECT().Save("ru.index", mock.Anything).
 		Run(func(_ string, payload io.Reader) {
 			cont, err := io.ReadAll(payload)
 			require.NoError(t, err)
@@ -48,7 +55,7 @@ func TestBuilder_Build(t *testing.T) {
 		}).
 		Return(nil).
 		Once()
-	idxStore.EXPECT().Save("en.index", mock.Anything).
+	store.EXPECT().Save("en.index", mock.Anything).
 		Run(func(_ string, payload io.Reader) {
 			cont, err := io.ReadAll(payload)
 			require.NoError(t, err)
@@ -64,8 +71,7 @@ func TestBuilder_Build(t *testing.T) {
 		Return(tms, nil).
 		Once()
 
-	tmStore := trademarkindex.NewMockDataStore(t)
-	tmStore.EXPECT().Save("trademark.index", mock.Anything).
+	store.EXPECT().Save("trademark.index", mock.Anything).
 		Run(func(_ string, payload io.Reader) {
 			cont, err := io.ReadAll(payload)
 			require.NoError(t, err)
@@ -77,28 +83,51 @@ func TestBuilder_Build(t *testing.T) {
 		Return(nil).
 		Once()
 
-	bloomStore := bloomfilter.NewMockDataStore(t)
-	bloomStore.EXPECT().Save("bloom.dat", mock.Anything).
+	store.EXPECT().Save("bloom.dat", mock.Anything).
 		Run(func(_ string, payload io.Reader) {
 			cont, err := io.ReadAll(payload)
 			require.NoError(t, err)
-			require.Len(t, cont, 456)
+			require.Len(t, cont, 253)
 		}).
 		Return(nil).
 		Once()
-	bloomStore.EXPECT().DataReader("ru.index").
+	store.EXPECT().Save("manifest.json", mock.Anything).
+		Run(func(_ string, payload io.Reader) {
+			m := &manifest.Manifest{}
+			require.NoError(t, json.NewDecoder(payload).Decode(m))
+			require.NotEmpty(t, m.Generation)
+			require.NoError(t, m.Verify(m.Generation))
+			require.Equal(t, []string{"bloom.dat", "en.index", "ru.index", "trademark.index"}, m.Keys())
+			require.Equal(t, int64(253), m.Artifacts["bloom.dat"].Size)
+			require.NoError(t, m.RequireDerived("bloom.dat", "ru.index", "en.index"))
+			require.Equal(t, map[string]int{"ru": 22, "en": 6}, m.Words)
+			require.Equal(t, 0.01, m.Options.FalsePositiveRate)
+			require.Equal(t, "tf", m.Options.Weight)
+
+			stats := m.Filters["bloom.dat"]
+			require.NotNil(t, stats)
+			require.Equal(t, "bloom", stats.Type)
+			require.Less(t, stats.DistinctDeletes, stats.EstimatedDeletes)
+			require.Equal(t, 0.01, stats.FalsePositiveRate)
+		}).
+		Return(nil).
+		Once()
+	store.EXPECT().DataReader("ru.index").
 		Return(ruIdxRC, nil).
 		Once()
-	bloomStore.EXPECT().DataReader("en.index").
+	store.EXPECT().DataReader("en.index").
 		Return(enIdxRC, nil).
 		Once()
 
 	b := &Builder{
-		opt:                   opt,
-		indexBuilder:          index.NewBuilder(idxSrc, idxStore, langs, l),
-		tradeMarkIndexBuilder: trademarkindex.NewBuilder(tmSrc, tmStore, l),
-		store:                 bloomStore,
-		logger:                l,
+		opt:             opt,
+		itemSource:      idxSrc,
+		tradeMarkSource: tmSrc,
+		langs:           langs,
+		generationStore: func(string) (index.DataStore, error) {
+			return store, nil
+		},
+		logger: l,
 	}
 
 	err = b.Build()
@@ -111,6 +140,195 @@ func TestBuilder_Build(t *testing.T) {
 	require.Contains(t, logStr, `[LANG INDEX SAVE] saving index, lang: ru`)
 	require.Contains(t, logStr, `[TRADEMARK INDEX BUILD] trademarks total: 100`)
 	require.Contains(t, logStr, `[TRADEMARK INDEX SAVE] saved`)
-	require.Contains(t, logStr, `[BLOOM FILTER BUILD] built`)
-	require.Contains(t, logStr, `[BLOOM FILTER SAVE] saved`)
+	require.Contains(t, logStr, `[BLOOM FILTER BUILD] bloom.dat built`)
+	require.Contains(t, logStr, `[BLOOM FILTER SAVE] bloom.dat saved`)
+	require.Contains(t, logStr, `[MANIFEST SAVE] generation`)
+}
+
+func TestBuilder_Build_RareWords(t *testing.T) {
+	l, lbuf := testdata.NewTestLogger()
+
+	opt := &options.Options{
+		Bloom: bloomfilter.Options{
+			FalsePositiveRate: 0.01,
+			Startup:           bloomfilter.StartupLoad,
+			Rare:              bloomfilter.RareOptions{Enabled: true},
+		},
+		Langs: []string{domain.RuLangCode, domain.EnLangCode},
+	}
+
+	itemNames, itemDesc, catNames, tms, err := testdata.CatalogData()
+	require.NoError(t, err)
+
+	idxSrc := index.NewMockDataSource(t)
+	idxSrc.EXPECT().ItemData(0, 100000).Return(itemNames, itemDesc, nil).Twice()
+	idxSrc.EXPECT().CategoryNames(0, 10000).Return(catNames, nil).Twice()
+
+	tmSrc := trademarkindex.NewMockDataSource(t)
+	tmSrc.EXPECT().TradeMarkNames(0, 5000).Return(tms, nil).Once()
+
+	store := memory.New()
+
+	b := &Builder{
+		opt:             opt,
+		itemSource:      idxSrc,
+		tradeMarkSource: tmSrc,
+		langs:           langdetect.New(),
+		generationStore: func(string) (index.DataStore, error) {
+			return store, nil
+		},
+		logger: l,
+	}
+	require.NoError(t, b.Build())
+	require.Contains(t, lbuf.String(), `[RARE FILTER SAVE] rare.dat saved`)
+
+	// После сборки статистика каталога освобождена.
+	require.Empty(t, b.indexBuilder.RareWords())
+
+	// Все слова каталога ниже порогов: редкие встретились хотя бы в двух товарах и категориях,
+	// остальные - однократные опечатки.
+	catalogOpt := *opt
+	catalogOpt.Index.RareMinItems = 1
+	catalog := index.NewBuilder(&catalogOpt, idxSrc, memory.New(), langdetect.New(), l)
+	require.NoError(t, catalog.LoadIndexFromDB())
+
+	var rare, once []string
+	for _, w := range catalog.RareWords() {
+		st, found := catalog.Stats(domain.RuLangCode, w)
+		if !found {
+			st, found = catalog.Stats(domain.EnLangCode, w)
+		}
+		require.True(t, found, w)
+
+		if st.Docs > 1 {
+			rare = append(rare, w)
+		} else {
+			once = append(once, w)
+		}
+	}
+	require.NotEmpty(t, rare)
+	require.NotEmpty(t, once)
+
+	// Исправление через bloom-фильтр без таблицы удалений небыстрое, хватит и части слов.
+	sort.Strings(rare)
+	sample := rare[:min(len(rare), 50)]
+	sort.Strings(once)
+	onceSample := once[:min(len(once), 50)]
+
+	m, err := manifest.Load(store)
+	require.NoError(t, err)
+	require.Contains(t, m.Keys(), bloomfilter.RareStoreKey)
+	require.NotNil(t, m.Filters[bloomfilter.RareStoreKey])
+	require.Equal(t, 0.001, m.Filters[bloomfilter.RareStoreKey].FalsePositiveRate)
+
+	opt.Manifest = manifest.Options{Generation: m.Generation}
+	withRare, err := newService(opt, store, l)
+	require.NoError(t, err)
+	require.NotNil(t, withRare.rare)
+
+	noRareOpt := *opt
+	noRareOpt.Bloom.Rare.Enabled = false
+	withoutRare, err := newService(&noRareOpt, store, l)
+	require.NoError(t, err)
+	require.Nil(t, withoutRare.rare)
+
+	var kept int
+	for _, w := range sample {
+		require.Zero(t, withRare.index.Weight(w), w)
+		require.True(t, withRare.rare.Test(w), w)
+
+		// Редкое слово не исправляется, а без фильтра редких слов его "исправили" бы в слово индекса.
+		corrected, _ := withRare.correctWordWith(context.Background(), domain.NewDigestRaw(w), defaultCorrectOptions)
+		require.Equal(t, domain.DigestElement(domain.NewDigestRaw(w)), corrected, w)
+		if without, _ := withoutRare.correctWordWith(context.Background(), domain.NewDigestRaw(w), defaultCorrectOptions); without.String() != w {
+			kept++
+		}
+	}
+	require.NotZero(t, kept)
+
+	// Однократная опечатка каталога в фильтр редких слов не попадает и исправляется как обычно.
+	var fixed int
+	for _, w := range onceSample {
+		expected, _ := withoutRare.correctWordWith(context.Background(), domain.NewDigestRaw(w), defaultCorrectOptions)
+		corrected, _ := withRare.correctWordWith(context.Background(), domain.NewDigestRaw(w), defaultCorrectOptions)
+		require.Equal(t, expected, corrected, w)
+		if expected.String() != w {
+			fixed++
+		}
+	}
+	require.NotZero(t, fixed)
+
+	// Редкие слова не предлагаются в качестве исправлений: исправление - всегда слово индекса.
+	for _, w := range sample {
+		typo := w + "ъ"
+		if corrected, _ := withRare.correctWordWith(context.Background(), domain.NewDigestRaw(typo), defaultCorrectOptions); corrected.String() != typo {
+			require.NotZero(t, withRare.index.Weight(corrected.String()), typo)
+		}
+	}
+}
+
+// testPublisher запоминает опубликованные поколения.
+type testPublisher struct {
+	published []string
+}
+
+func (p *testPublisher) Publish(generation string) error {
+	p.published = append(p.published, generation)
+
+	return nil
+}
+
+func (p *testPublisher) Cleanup() ([]string, error) {
+	return nil, nil
+}
+
+func TestBuilder_Build_NewGenerationEachTime(t *testing.T) {
+	l, _ := testdata.NewTestLogger()
+
+	opt := &options.Options{
+		Bloom: bloomfilter.Options{FalsePositiveRate: 0.01},
+	}
+
+	itemNames, itemDesc, catNames, tms, err := testdata.CatalogData()
+	require.NoError(t, err)
+
+	idxSrc := index.NewMockDataSource(t)
+	idxSrc.EXPECT().ItemData(0, 100000).Return(itemNames, itemDesc, nil).Twice()
+	idxSrc.EXPECT().CategoryNames(0, 10000).Return(catNames, nil).Twice()
+
+	tmSrc := trademarkindex.NewMockDataSource(t)
+	tmSrc.EXPECT().TradeMarkNames(0, 5000).Return(tms, nil).Twice()
+
+	stores := make(map[string]*memory.Store)
+	pub := &testPublisher{}
+
+	b := &Builder{
+		opt:             opt,
+		itemSource:      idxSrc,
+		tradeMarkSource: tmSrc,
+		langs:           langdetect.New(),
+		generationStore: func(generation string) (index.DataStore, error) {
+			stores[generation] = memory.New()
+
+			return stores[generation], nil
+		},
+		publisher: pub,
+		logger:    l,
+	}
+
+	require.NoError(t, b.Build())
+	require.NoError(t, b.Build())
+
+	// Вторая сборка пишет в новое поколение, а не в уже опубликованное.
+	require.Len(t, pub.published, 2)
+	require.NotEqual(t, pub.published[0], pub.published[1])
+	require.Len(t, stores, 2)
+
+	for _, generation := range pub.published {
+		m, err := manifest.Load(stores[generation])
+		require.NoError(t, err)
+		require.Equal(t, generation, m.Generation)
+		require.NoError(t, m.Verify(generation))
+		require.Equal(t, []string{"bloom.dat", "en.index", "ru.index", "trademark.index"}, m.Keys())
+	}
 }
@@ -0,0 +1,68 @@
+package bloomfilter
+
+import (
+	"github.com/bits-and-blooms/bloom/v3"
+	"github.com/pkg/errors"
+)
+
+// bloomFilter - MembershipFilter поверх bits-and-blooms/bloom.
+type bloomFilter struct {
+	impl *bloom.BloomFilter
+}
+
+func newBloomFilter(size uint, fpr float64) *bloomFilter {
+	return &bloomFilter{impl: bloom.NewWithEstimates(size, fpr)}
+}
+
+func (f *bloomFilter) Add(w string) {
+	f.impl.AddString(w)
+}
+
+func (f *bloomFilter) AddBytes(w []byte) {
+	f.impl.Add(w)
+}
+
+func (f *bloomFilter) Seal() error {
+	return nil
+}
+
+func (f *bloomFilter) Test(w string) bool {
+	return f.impl.TestString(w)
+}
+
+func (f *bloomFilter) TestBytes(w []byte) bool {
+	return f.impl.Test(w)
+}
+
+func (f *bloomFilter) Shard() MembershipFilter {
+	return &bloomFilter{impl: bloom.New(f.impl.Cap(), f.impl.K())}
+}
+
+func (f *bloomFilter) Merge(other MembershipFilter) error {
+	o, ok := other.(*bloomFilter)
+	if !ok {
+		return errors.Errorf("can not merge %T into bloom filter", other)
+	}
+
+	return errors.WithStack(f.impl.Merge(o.impl))
+}
+
+func (f *bloomFilter) Equal(other MembershipFilter) bool {
+	o, ok := other.(*bloomFilter)
+
+	return ok && f.impl.Equal(o.impl)
+}
+
+func (f *bloomFilter) BitsCount() uint {
+	return f.impl.Cap()
+}
+
+func (f *bloomFilter) MarshalBinary() ([]byte, error) {
+	return f.impl.GobEncode()
+}
+
+func (f *bloomFilter) UnmarshalBinary(data []byte) error {
+	f.impl = &bloom.BloomFilter{}
+
+	return f.impl.GobDecode(data)
+}
@@ -0,0 +1,49 @@
+package bloomfilter
+
+import (
+	"math"
+)
+
+const (
+	// minFalsePositiveRate - нижняя граница подбираемой доли ошибок: дальше растет лишь число хеш-функций bloom-фильтра.
+	minFalsePositiveRate = 1e-9
+	// maxFalsePositiveRate - при большей доле ошибок фильтр почти бесполезен.
+	maxFalsePositiveRate = 0.5
+
+	fuse8FalsePositiveRate  = 1.0 / (1 << 8)
+	fuse16FalsePositiveRate = 1.0 / (1 << 16)
+)
+
+// falsePositiveRateForBudget подбирает наименьшую долю ложноположительных ответов,
+// при которой фильтр типа filterType на size элементов укладывается в budget байт.
+// ok == false, если в бюджет не укладывается даже фильтр с наибольшей допустимой долей ошибок:
+// тогда отдается именно она, а фильтр получится больше бюджета.
+func falsePositiveRateForBudget(filterType string, size uint, budget uint64) (fpr float64, ok bool) {
+	if size < 2 {
+		if filterType == FilterBinaryFuse {
+			return fuse16FalsePositiveRate, true
+		}
+
+		return minFalsePositiveRate, true
+	}
+
+	if filterType == FilterBinaryFuse {
+		// Отпечатков в фильтре примерно size * fuseSizeFactor(size), каждый 1 или 2 байта.
+		fingerprints := float64(size) * fuseSizeFactor(uint32(min(size, math.MaxUint32)))
+		if float64(budget) >= 2*fingerprints {
+			return fuse16FalsePositiveRate, true
+		}
+
+		return fuse8FalsePositiveRate, float64(budget) >= fingerprints
+	}
+
+	// Оптимальный bloom-фильтр тратит на элемент -ln(fpr) / ln(2)^2 бит.
+	bitsPerKey := float64(budget) * 8 / float64(size)
+	fpr = math.Exp(-bitsPerKey * math.Ln2 * math.Ln2)
+
+	if fpr > maxFalsePositiveRate {
+		return maxFalsePositiveRate, false
+	}
+
+	return math.Max(fpr, minFalsePositiveRate), true
+}
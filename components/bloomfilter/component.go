@@ -3,9 +3,9 @@ package bloomfilter
 import (
 	"bytes"
 	"io"
+	"math/rand"
 	"sync"
 
-	"github.com/bits-and-blooms/bloom/v3"
 	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
@@ -13,21 +13,42 @@ import (
 )
 
 const (
-	defaultFalsePositiveRate = 0.005
-	defaultFilterSize        = 10000
-	storeKey                 = "bloom.dat"
+	defaultFalsePositiveRate     = 0.005
+	defaultRareFalsePositiveRate = 0.001
+	defaultFilterSize            = 10000
+	// StoreKey ключ общего для всех языков фильтра в DataStore.
+	StoreKey = "bloom.dat"
+	// RareStoreKey - ключ фильтра редких слов в DataStore.
+	RareStoreKey = "rare.dat"
+
+	probeLen      = 12
+	probeAlphabet = "abcdefghijklmnopqrstuvwxyz0123456789"
 )
 
-type DataStore interface {
+// LangStoreKey отдает ключ фильтра языка lang в DataStore.
+func LangStoreKey(lang string) string {
+	return lang + "." + StoreKey
+}
+
+// ReadOnlyStore - хранилище, из которого фильтр загружается. Для работы сервиса больше ничего не нужно.
+type ReadOnlyStore interface {
 	DataReader(key string) (io.ReadCloser, error)
 	IsExist(key string) (bool, error)
+}
+
+// DataStore - хранилище, в которое билдер сохраняет построенный фильтр.
+type DataStore interface {
+	ReadOnlyStore
 	Save(key string, content io.Reader) error
 }
 
 type Component struct {
+	key               string
 	falsePositiveRate float64
-	impl              *bloom.BloomFilter
-	store             DataStore
+	memoryBudget      uint64
+	filterType        string
+	impl              MembershipFilter
+	store             ReadOnlyStore
 	logger            *logrus.Entry
 
 	mu sync.Mutex
@@ -41,33 +62,108 @@ type Component struct {
 // Тогда фильтр всегда ответит f.Test(el) == true для элемента,
 // который в него добавляли, и с вероятностью f.falsePositiveRate ответит false
 // для элемента, который не был в него добавлен.
-func New(opt *Options, store DataStore, logger *logrus.Entry) *Component {
+func New(opt *Options, store ReadOnlyStore, logger *logrus.Entry) *Component {
 	fpr := defaultFalsePositiveRate
 	if opt.FalsePositiveRate > 0.0 {
 		fpr = opt.FalsePositiveRate
 	}
 
 	res := &Component{
+		key:               StoreKey,
 		falsePositiveRate: fpr,
-		impl:              bloom.NewWithEstimates(defaultFilterSize, fpr),
+		memoryBudget:      opt.MemoryBudget,
+		filterType:        opt.Filter,
 		store:             store,
 		logger:            logger.WithField(domain.CategoryFieldName, "components.bloom_filter"),
 	}
+	res.impl = res.newFilter(defaultFilterSize)
 
 	return res
 }
 
+// NewLang создает пустой фильтр языка lang с учетом настроек opt.Langs.
+// Сохраняется и загружается такой фильтр под ключом LangStoreKey(lang).
+func NewLang(lang string, opt *Options, store ReadOnlyStore, logger *logrus.Entry) *Component {
+	res := New(opt.ForLang(lang), store, logger.WithField("lang", lang))
+	res.key = LangStoreKey(lang)
+
+	return res
+}
+
+// NewRare создает пустой фильтр редких слов с настройками opt.ForRare().
+// Сохраняется и загружается такой фильтр под ключом RareStoreKey.
+func NewRare(opt *Options, store ReadOnlyStore, logger *logrus.Entry) *Component {
+	res := New(opt.ForRare(), store, logger.WithField("filter", "rare"))
+	res.key = RareStoreKey
+
+	return res
+}
+
+// Key отдает ключ фильтра в DataStore.
+func (c *Component) Key() string {
+	return c.key
+}
+
+func (c *Component) newFilter(size uint) MembershipFilter {
+	if c.filterType == FilterBinaryFuse {
+		return newBinaryFuse(size, c.falsePositiveRate)
+	}
+
+	return newBloomFilter(size, c.falsePositiveRate)
+}
+
 // Reset инициирует фильтр, после этой процедуры его можно начинать заполнять.
 // принимает точное количество элементов, которые будут содержаться в фильтре.
 // Если указать значение меньше, вероятность ложноположительных тестов
 // будет выше f.falsePositiveRate.
 // Если же указать значение больше, вероятность будет гарантирована,
 // но память, занимаемая фильтром, будет больше оптимальной.
+// Статическому фильтру (binary fuse) size служит лишь подсказкой, его размер определится в Seal.
+// Если задан Options.MemoryBudget, доля ложноположительных ответов подбирается под бюджет и size.
 func (c *Component) Reset(size uint) {
-	c.impl = bloom.NewWithEstimates(size, c.falsePositiveRate)
+	if c.memoryBudget > 0 {
+		fpr, ok := falsePositiveRateForBudget(c.filterType, size, c.memoryBudget)
+		if !ok {
+			c.logger.Warnf(
+				"memory budget of %d bytes is too small for %d elements, filter %s will exceed it",
+				c.memoryBudget, size, c.key,
+			)
+		}
+		c.falsePositiveRate = fpr
+	}
+
+	c.impl = c.newFilter(size)
+}
+
+// NeedsSize сообщает, что количество элементов нужно передать в Reset до заполнения фильтра:
+// bloom-фильтру - всегда, статическому - только при заданном MemoryBudget, от него зависит ширина отпечатков.
+// Иначе статический фильтр можно заполнять сразу, его размер определится в Seal.
+func (c *Component) NeedsSize() bool {
+	return c.filterType != FilterBinaryFuse || c.memoryBudget > 0
+}
+
+// FalsePositiveRate отдает расчетную долю ложноположительных ответов фильтра.
+func (c *Component) FalsePositiveRate() float64 {
+	return c.falsePositiveRate
+}
+
+// MemoryBudget отдает заданный в настройках объем памяти фильтра в байтах, 0 - если не задан.
+func (c *Component) MemoryBudget() uint64 {
+	return c.memoryBudget
+}
+
+// FilterType отдает тип построенного фильтра: bloom, fuse8 или fuse16.
+func (c *Component) FilterType() string {
+	ft, err := typeOf(c.impl)
+	if err != nil {
+		return ""
+	}
+
+	return filterNames[ft]
 }
 
 // Add добавляет элементы в фильтр. Элементы - это строки любого размера.
+// Можно вызывать из нескольких горутин одновременно.
 func (c *Component) Add(words ...string) {
 	if c.impl == nil {
 		c.logger.Warn("bloom filter not initialized, nothing changed")
@@ -75,11 +171,93 @@ func (c *Component) Add(words ...string) {
 		return
 	}
 
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
 	for _, w := range words {
-		_ = c.impl.Add([]byte(w))
+		c.impl.Add(w)
 	}
 }
 
+// AddBytes добавляет элемент в виде байтов, без аллокаций: w можно переиспользовать сразу после возврата.
+// Можно вызывать из нескольких горутин одновременно.
+func (c *Component) AddBytes(w []byte) {
+	if c.impl == nil {
+		c.logger.Warn("bloom filter not initialized, nothing changed")
+
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.impl.AddBytes(w)
+}
+
+// Seal завершает заполнение фильтра: статический фильтр строится именно здесь.
+// Save вызывает его сам.
+func (c *Component) Seal() error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	return c.impl.Seal()
+}
+
+// Shard отдает пустой фильтр с теми же параметрами, что и у c.
+// Шарды заполняются независимо, каждый в своей горутине, без конкуренции за блокировку,
+// а затем сливаются с исходным фильтром через Merge.
+func (c *Component) Shard() *Component {
+	res := &Component{
+		key:               c.key,
+		falsePositiveRate: c.falsePositiveRate,
+		memoryBudget:      c.memoryBudget,
+		filterType:        c.filterType,
+		store:             c.store,
+		logger:            c.logger,
+	}
+	if c.impl != nil {
+		res.impl = c.impl.Shard()
+	}
+
+	return res
+}
+
+// Merge добавляет в фильтр все элементы шардов (для bloom-фильтра - побитовое ИЛИ битовых карт).
+func (c *Component) Merge(shards ...*Component) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	for _, sh := range shards {
+		if c.impl == nil || sh.impl == nil {
+			return errors.New("bloom filter not initialized, can not merge")
+		}
+
+		if err := c.impl.Merge(sh.impl); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Equal сообщает, совпадают ли тип, параметры и содержимое двух фильтров.
+func (c *Component) Equal(other *Component) bool {
+	if c.impl == nil || other.impl == nil {
+		return c.impl == other.impl
+	}
+
+	return c.impl.Equal(other.impl)
+}
+
+// BitsCount отдает объем памяти, занимаемой фильтром, в битах.
+func (c *Component) BitsCount() uint {
+	if c.impl == nil {
+		return 0
+	}
+
+	return c.impl.BitsCount()
+}
+
 // Test - рабочий метод фильтра.
 // - Всегда возвращает true для элементов, ранее добавленных к фильтру.
 // - С вероятностью falsePositiveRate возвращает false для элементов, которые не добавляли к фильтру.
@@ -90,30 +268,78 @@ func (c *Component) Test(w string) bool {
 		return false
 	}
 
-	return c.impl.TestString(w)
+	return c.impl.Test(w)
+}
+
+// TestBytes - то же, что Test, для элемента в виде байтов. Не выделяет память.
+func (c *Component) TestBytes(w []byte) bool {
+	if c.impl == nil {
+		c.logger.Warn("not initialized bloom filter always returnt false")
+
+		return false
+	}
+
+	return c.impl.TestBytes(w)
+}
+
+// MeasureFalsePositiveRate проверяет фильтр probes случайными строками и отдает долю положительных ответов.
+// Строки начинаются с управляющего символа, так что в фильтре их заведомо нет:
+// каждый положительный ответ на них ложный. Фильтр должен быть запечатан (Seal).
+func (c *Component) MeasureFalsePositiveRate(probes int) float64 {
+	if c.impl == nil || probes <= 0 {
+		return 0.0
+	}
+
+	// Генератор с фиксированным seed: замер одного и того же фильтра воспроизводим.
+	rnd := rand.New(rand.NewSource(1))
+	probe := make([]byte, 1+probeLen)
+	probe[0] = '\x01'
+
+	positives := 0
+	for i := 0; i < probes; i++ {
+		for j := 1; j < len(probe); j++ {
+			probe[j] = probeAlphabet[rnd.Intn(len(probeAlphabet))]
+		}
+		if c.impl.Test(string(probe)) {
+			positives++
+		}
+	}
+
+	return float64(positives) / float64(probes)
 }
 
 // Save - записывает заполненный фильтр в DataStore.
+// Если фильтр создан поверх хранилища только для чтения, отдает *domain.ReadOnlyError.
 func (c *Component) Save() error {
-	data, err := c.impl.GobEncode()
+	store, ok := c.store.(DataStore)
+	if !ok {
+		return &domain.ReadOnlyError{Key: c.key}
+	}
+
+	if err := c.Seal(); err != nil {
+		return err
+	}
+
+	data, err := encodeFilter(c.impl)
 	if err != nil {
-		return errors.WithStack(err)
+		return err
 	}
 
-	return c.store.Save(storeKey, bytes.NewReader(data))
+	return store.Save(c.key, bytes.NewReader(data))
 }
 
-// Load - загружает фильтр из DataStore.
+// Load - загружает фильтр из DataStore. Тип фильтра определяется по заголовку сохраненных данных,
+// а не по Options.Filter.
 func (c *Component) Load() error {
-	exists, err := c.store.IsExist(storeKey)
+	exists, err := c.store.IsExist(c.key)
 	if err != nil {
 		return err
 	}
 	if !exists {
-		return errors.New("no bloom filter data found")
+		return errors.New("no bloom filter data found: " + c.key)
 	}
 
-	dr, err := c.store.DataReader(storeKey)
+	dr, err := c.store.DataReader(c.key)
 	if err != nil {
 		return err
 	}
@@ -129,10 +355,11 @@ func (c *Component) Load() error {
 		return err
 	}
 
-	err = c.impl.GobDecode(data)
+	impl, err := decodeFilter(data)
 	if err != nil {
-		return errors.WithStack(err)
+		return err
 	}
+	c.impl = impl
 
-	return c.store.Save(storeKey, bytes.NewReader(data))
+	return nil
 }
@@ -1,10 +1,15 @@
 package bloomfilter
 
 import (
+	"strconv"
 	"testing"
+	"testing/fstest"
 
 	"github.com/stretchr/testify/require"
 
+	"github.com/cannonflesh/wordspell/domain"
+	"github.com/cannonflesh/wordspell/repo/embedded"
+	"github.com/cannonflesh/wordspell/repo/memory"
 	"github.com/cannonflesh/wordspell/testdata"
 )
 
@@ -27,3 +32,97 @@ func TestComponent_All(t *testing.T) {
 
 	require.Empty(t, lbuf.String())
 }
+
+func TestComponent_Save_Load(t *testing.T) {
+	lgr, _ := testdata.NewTestLogger()
+	opt := &Options{FalsePositiveRate: 0.01}
+
+	t.Run("ReadOnly", func(t *testing.T) {
+		err := New(opt, embedded.New(fstest.MapFS{}), lgr).Save()
+		require.True(t, domain.IsReadOnly(err))
+	})
+
+	store := memory.New()
+	built := New(opt, store, lgr)
+	built.Reset(100)
+	built.Add("хрензначо")
+	require.NoError(t, built.Save())
+
+	dr, err := store.DataReader(StoreKey)
+	require.NoError(t, err)
+
+	// Загрузка только читает: у ReadOnlyStore нет Save, а мок упадет на любом лишнем вызове.
+	ro := NewMockReadOnlyStore(t)
+	ro.EXPECT().IsExist(StoreKey).Return(true, nil).Once()
+	ro.EXPECT().DataReader(StoreKey).Return(dr, nil).Once()
+
+	loaded := New(opt, ro, lgr)
+	require.NoError(t, loaded.Load())
+	require.True(t, loaded.Test("хрензначо"))
+	require.False(t, loaded.Test("значохрен"))
+}
+
+func TestOptions_ForLang(t *testing.T) {
+	opt := &Options{
+		FalsePositiveRate: 0.01,
+		Filter:            FilterBloom,
+		PerLanguage:       true,
+		Langs: map[string]LangOptions{
+			"en": {FalsePositiveRate: 0.001},
+			"ru": {Filter: FilterBinaryFuse, MemoryBudget: 1 << 20},
+		},
+	}
+
+	en := opt.ForLang("en")
+	require.Equal(t, 0.001, en.FalsePositiveRate)
+	require.Equal(t, FilterBloom, en.Filter)
+
+	ru := opt.ForLang("ru")
+	require.Equal(t, 0.01, ru.FalsePositiveRate)
+	require.Equal(t, FilterBinaryFuse, ru.Filter)
+	require.Equal(t, uint64(1<<20), ru.MemoryBudget)
+
+	require.Equal(t, opt, opt.ForLang("de"))
+	require.Equal(t, 0.01, opt.FalsePositiveRate)
+
+	lgr, _ := testdata.NewTestLogger()
+	require.Equal(t, "en.bloom.dat", NewLang("en", opt, nil, lgr).Key())
+	require.Equal(t, StoreKey, New(opt, nil, lgr).Key())
+}
+
+func TestComponent_MemoryBudget(t *testing.T) {
+	lgr, lbuf := testdata.NewTestLogger()
+
+	const size = 100000
+
+	for _, budget := range []uint64{60 << 10, 90 << 10, 120 << 10} {
+		c := New(&Options{MemoryBudget: budget}, nil, lgr)
+		c.Reset(size)
+		for i := 0; i < size; i++ {
+			c.Add(strconv.Itoa(i))
+		}
+		require.NoError(t, c.Seal())
+
+		require.InEpsilon(t, budget*8, float64(c.BitsCount()), 0.01, "budget: %d", budget)
+		require.InEpsilon(t, c.FalsePositiveRate(), c.MeasureFalsePositiveRate(100000), 0.3, "budget: %d", budget)
+	}
+
+	for _, tc := range []struct {
+		budget     uint64
+		filterType string
+	}{
+		{budget: 50 << 10, filterType: "fuse8"},
+		{budget: 150 << 10, filterType: "fuse8"},
+		{budget: 300 << 10, filterType: "fuse16"},
+	} {
+		c := New(&Options{Filter: FilterBinaryFuse, MemoryBudget: tc.budget}, nil, lgr)
+		c.Reset(size)
+		for i := 0; i < size; i++ {
+			c.Add(strconv.Itoa(i))
+		}
+		require.NoError(t, c.Seal())
+		require.Equal(t, tc.filterType, c.FilterType(), "budget: %d", tc.budget)
+	}
+
+	require.Contains(t, lbuf.String(), "memory budget of 51200 bytes is too small for 100000 elements, filter bloom.dat will exceed it")
+}
@@ -0,0 +1,114 @@
+package bloomfilter
+
+import (
+	"bytes"
+
+	"github.com/pkg/errors"
+)
+
+// MembershipFilter - приближенное представление множества строк:
+// на добавленный элемент всегда отвечает true, на прочие - false, за исключением редких ложноположительных ответов.
+//
+// Динамические фильтры (bloom) готовы отвечать сразу после Add.
+// Статические (binary fuse) лишь запоминают элементы, а строятся при вызове Seal.
+type MembershipFilter interface {
+	Add(w string)
+	// AddBytes - то же, что Add, для элемента в виде байтов, без аллокаций.
+	AddBytes(w []byte)
+	// Seal завершает заполнение фильтра. Повторный вызов ничего не меняет.
+	Seal() error
+	Test(w string) bool
+	// TestBytes - то же, что Test, для элемента в виде байтов, без аллокаций.
+	TestBytes(w []byte) bool
+	// Shard отдает пустой фильтр с теми же параметрами.
+	Shard() MembershipFilter
+	// Merge добавляет в фильтр все элементы other, other должен быть того же типа и с теми же параметрами.
+	Merge(other MembershipFilter) error
+	Equal(other MembershipFilter) bool
+	// BitsCount отдает объем памяти, занимаемой фильтром, в битах.
+	BitsCount() uint
+
+	MarshalBinary() ([]byte, error)
+	UnmarshalBinary(data []byte) error
+}
+
+/* Формат сериализации.
+ *
+ * Сохраненный фильтр начинается с заголовка: магической последовательности headerMagic и байта типа фильтра.
+ * bloom.dat, записанный до появления заголовка, - это bloom-фильтр в формате GobEncode,
+ * он начинается с размера битовой карты (uint64, big-endian) и с заголовком спутан быть не может.
+ */
+
+var headerMagic = []byte("WSMF")
+
+const (
+	typeBloom byte = iota + 1
+	typeBinaryFuse8
+	typeBinaryFuse16
+)
+
+func typeOf(f MembershipFilter) (byte, error) {
+	switch f.(type) {
+	case *bloomFilter:
+		return typeBloom, nil
+	case *binaryFuse[uint8]:
+		return typeBinaryFuse8, nil
+	case *binaryFuse[uint16]:
+		return typeBinaryFuse16, nil
+	}
+
+	return 0, errors.Errorf("unknown membership filter type %T", f)
+}
+
+// filterNames - названия типов фильтра для логов и манифеста.
+var filterNames = map[byte]string{
+	typeBloom:        FilterBloom,
+	typeBinaryFuse8:  "fuse8",
+	typeBinaryFuse16: "fuse16",
+}
+
+func encodeFilter(f MembershipFilter) ([]byte, error) {
+	ft, err := typeOf(f)
+	if err != nil {
+		return nil, err
+	}
+
+	payload, err := f.MarshalBinary()
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	res := make([]byte, 0, len(headerMagic)+1+len(payload))
+	res = append(res, headerMagic...)
+	res = append(res, ft)
+
+	return append(res, payload...), nil
+}
+
+func decodeFilter(data []byte) (MembershipFilter, error) {
+	var res MembershipFilter
+
+	if !bytes.HasPrefix(data, headerMagic) {
+		res = &bloomFilter{}
+
+		return res, errors.WithStack(res.UnmarshalBinary(data))
+	}
+
+	data = data[len(headerMagic):]
+	if len(data) == 0 {
+		return nil, errors.New("membership filter data is truncated")
+	}
+
+	switch data[0] {
+	case typeBloom:
+		res = &bloomFilter{}
+	case typeBinaryFuse8:
+		res = &binaryFuse[uint8]{}
+	case typeBinaryFuse16:
+		res = &binaryFuse[uint16]{}
+	default:
+		return nil, errors.Errorf("unknown membership filter type %d", data[0])
+	}
+
+	return res, errors.WithStack(res.UnmarshalBinary(data[1:]))
+}
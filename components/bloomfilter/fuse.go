@@ -0,0 +1,421 @@
+package bloomfilter
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"math/bits"
+	"slices"
+
+	"github.com/pkg/errors"
+)
+
+/* Binary fuse filter.
+ *
+ * Статический фильтр (Graf, Lemire, "Binary Fuse Filters: Fast and Smaller Than Xor Filters", 2022).
+ * Каждому элементу соответствуют три ячейки массива отпечатков, XOR которых равен отпечатку элемента.
+ * Ячейки выбираются в трех соседних сегментах массива, поэтому массив получается всего на ~13% больше
+ * числа элементов: при 8-битных отпечатках это ~9 бит на элемент при доле ложноположительных ответов 1/256,
+ * а bloom-фильтру на ту же долю нужно ~11.5 бит.
+ *
+ * Построить фильтр можно только по полному набору элементов, поэтому Add лишь копит хеши,
+ * а сам фильтр строится в Seal. На время построения нужно ~24 байта на элемент.
+ */
+
+const (
+	fuseArity         = 3
+	fuseMaxIterations = 100
+	fuseMaxSegment    = 262144
+)
+
+type fingerprint interface {
+	~uint8 | ~uint16
+}
+
+type binaryFuse[T fingerprint] struct {
+	seed               uint64
+	segmentLength      uint32
+	segmentLengthMask  uint32
+	segmentCount       uint32
+	segmentCountLength uint32
+	fingerprints       []T
+
+	// hashes - хеши добавленных элементов, копятся до Seal.
+	hashes []uint64
+	sealed bool
+}
+
+// newBinaryFuse отдает binary fuse filter с отпечатками, достаточными для доли ложноположительных ответов fpr.
+func newBinaryFuse(size uint, fpr float64) MembershipFilter {
+	if fpr < 1.0/(1<<8) {
+		return &binaryFuse[uint16]{hashes: make([]uint64, 0, size)}
+	}
+
+	return &binaryFuse[uint8]{hashes: make([]uint64, 0, size)}
+}
+
+func (f *binaryFuse[T]) Add(w string) {
+	f.hashes = append(f.hashes, hashString(w))
+}
+
+func (f *binaryFuse[T]) AddBytes(w []byte) {
+	f.hashes = append(f.hashes, hashString(w))
+}
+
+func (f *binaryFuse[T]) Seal() error {
+	if f.sealed {
+		return nil
+	}
+
+	slices.Sort(f.hashes)
+	keys := slices.Compact(f.hashes)
+	f.hashes = nil
+
+	if err := f.populate(keys); err != nil {
+		return err
+	}
+	f.sealed = true
+
+	return nil
+}
+
+// Test до Seal всегда отвечает false.
+func (f *binaryFuse[T]) Test(w string) bool {
+	return f.test(hashString(w))
+}
+
+func (f *binaryFuse[T]) TestBytes(w []byte) bool {
+	return f.test(hashString(w))
+}
+
+func (f *binaryFuse[T]) test(key uint64) bool {
+	if len(f.fingerprints) == 0 {
+		return false
+	}
+
+	hash := mixSplit(key, f.seed)
+	fp := T(hash ^ (hash >> 32))
+	h0, h1, h2 := f.hashPositions(hash)
+
+	return fp^f.fingerprints[h0]^f.fingerprints[h1]^f.fingerprints[h2] == 0
+}
+
+func (f *binaryFuse[T]) Shard() MembershipFilter {
+	return &binaryFuse[T]{}
+}
+
+func (f *binaryFuse[T]) Merge(other MembershipFilter) error {
+	o, ok := other.(*binaryFuse[T])
+	if !ok {
+		return errors.Errorf("can not merge %T into %T", other, f)
+	}
+	if f.sealed || o.sealed {
+		return errors.New("can not merge sealed binary fuse filters")
+	}
+
+	f.hashes = append(f.hashes, o.hashes...)
+	o.hashes = nil
+
+	return nil
+}
+
+func (f *binaryFuse[T]) Equal(other MembershipFilter) bool {
+	o, ok := other.(*binaryFuse[T])
+	if !ok {
+		return false
+	}
+
+	return f.seed == o.seed &&
+		f.segmentLength == o.segmentLength &&
+		f.segmentCount == o.segmentCount &&
+		slices.Equal(f.fingerprints, o.fingerprints)
+}
+
+func (f *binaryFuse[T]) BitsCount() uint {
+	var zero T
+
+	return uint(len(f.fingerprints)) * uint(binary.Size(zero)) * 8
+}
+
+// fuseHeader - параметры фильтра в сериализованном виде, за ними следуют отпечатки.
+type fuseHeader struct {
+	Seed               uint64
+	SegmentLength      uint32
+	SegmentCount       uint32
+	SegmentCountLength uint32
+	Len                uint32
+}
+
+func (f *binaryFuse[T]) MarshalBinary() ([]byte, error) {
+	if !f.sealed {
+		return nil, errors.New("binary fuse filter is not sealed")
+	}
+
+	buf := bytes.Buffer{}
+	h := fuseHeader{
+		Seed:               f.seed,
+		SegmentLength:      f.segmentLength,
+		SegmentCount:       f.segmentCount,
+		SegmentCountLength: f.segmentCountLength,
+		Len:                uint32(len(f.fingerprints)),
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, h); err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if err := binary.Write(&buf, binary.LittleEndian, f.fingerprints); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (f *binaryFuse[T]) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+
+	h := fuseHeader{}
+	if err := binary.Read(r, binary.LittleEndian, &h); err != nil {
+		return errors.Wrap(err, "reading binary fuse filter header")
+	}
+	if h.SegmentLength == 0 || h.SegmentLength&(h.SegmentLength-1) != 0 {
+		return errors.Errorf("binary fuse filter segment length %d is not a power of 2", h.SegmentLength)
+	}
+	if uint64(h.Len) != uint64(h.SegmentCount+fuseArity-1)*uint64(h.SegmentLength) {
+		return errors.New("binary fuse filter parameters do not match its size")
+	}
+
+	fps := make([]T, h.Len)
+	if err := binary.Read(r, binary.LittleEndian, fps); err != nil {
+		return errors.Wrap(err, "reading binary fuse filter fingerprints")
+	}
+
+	*f = binaryFuse[T]{
+		seed:               h.Seed,
+		segmentLength:      h.SegmentLength,
+		segmentLengthMask:  h.SegmentLength - 1,
+		segmentCount:       h.SegmentCount,
+		segmentCountLength: h.SegmentCountLength,
+		fingerprints:       fps,
+		sealed:             true,
+	}
+
+	return nil
+}
+
+func (f *binaryFuse[T]) hashPositions(hash uint64) (uint32, uint32, uint32) {
+	hi, _ := bits.Mul64(hash, uint64(f.segmentCountLength))
+	h0 := uint32(hi)
+	h1 := h0 + f.segmentLength
+	h2 := h1 + f.segmentLength
+	h1 ^= uint32(hash>>18) & f.segmentLengthMask
+	h2 ^= uint32(hash) & f.segmentLengthMask
+
+	return h0, h1, h2
+}
+
+func (f *binaryFuse[T]) initParameters(size uint32) {
+	f.segmentLength = fuseSegmentLength(size)
+	f.segmentLengthMask = f.segmentLength - 1
+
+	capacity := uint32(0)
+	if size > 1 {
+		capacity = uint32(math.Round(float64(size) * fuseSizeFactor(size)))
+	}
+
+	// Вычитание может переполниться, но прибавление fuseArity-1 строчкой ниже все вернет на место.
+	initSegmentCount := (capacity+f.segmentLength-1)/f.segmentLength - (fuseArity - 1)
+	arrayLength := (initSegmentCount + fuseArity - 1) * f.segmentLength
+
+	f.segmentCount = (arrayLength + f.segmentLength - 1) / f.segmentLength
+	if f.segmentCount <= fuseArity-1 {
+		f.segmentCount = 1
+	} else {
+		f.segmentCount -= fuseArity - 1
+	}
+
+	f.segmentCountLength = f.segmentCount * f.segmentLength
+	f.fingerprints = make([]T, (f.segmentCount+fuseArity-1)*f.segmentLength)
+}
+
+// populate строит фильтр по уникальным хешам keys.
+func (f *binaryFuse[T]) populate(keys []uint64) error {
+	size := uint32(len(keys))
+	f.initParameters(size)
+
+	rng := uint64(1)
+	f.seed = splitMix64(&rng)
+	capacity := uint32(len(f.fingerprints))
+
+	alone := make([]uint32, capacity)
+	// Младшие 2 бита t2count - номер хеша (0, 1 или 2), оставшихся 6 бит хватает на счетчик.
+	t2count := make([]uint8, capacity)
+	t2hash := make([]uint64, capacity)
+	reverseH := make([]uint8, size)
+	reverseOrder := make([]uint64, size+1)
+	reverseOrder[size] = 1
+
+	blockBits := 1
+	for (uint32(1) << blockBits) < f.segmentCount {
+		blockBits++
+	}
+	startPos := make([]uint, 1<<blockBits)
+
+	var h012 [5]uint32
+
+	for iteration := 0; ; iteration++ {
+		if iteration >= fuseMaxIterations {
+			return errors.New("binary fuse filter construction failed: too many iterations")
+		}
+
+		// Раскладываем хеши по сегментам, так построение лучше использует кеш процессора.
+		for i := range startPos {
+			startPos[i] = uint((uint64(i) * uint64(size)) >> blockBits)
+		}
+		for _, key := range keys {
+			hash := mixSplit(key, f.seed)
+			segment := hash >> (64 - blockBits)
+			for reverseOrder[startPos[segment]] != 0 {
+				segment++
+				segment &= (1 << blockBits) - 1
+			}
+			reverseOrder[startPos[segment]] = hash
+			startPos[segment]++
+		}
+
+		overflow := false
+		for i := uint32(0); i < size; i++ {
+			hash := reverseOrder[i]
+			i0, i1, i2 := f.hashPositions(hash)
+			t2count[i0] += 4
+			t2hash[i0] ^= hash
+			t2count[i1] += 4
+			t2count[i1] ^= 1
+			t2hash[i1] ^= hash
+			t2count[i2] += 4
+			t2count[i2] ^= 2
+			t2hash[i2] ^= hash
+
+			if t2count[i0] < 4 || t2count[i1] < 4 || t2count[i2] < 4 {
+				overflow = true
+			}
+		}
+
+		if !overflow {
+			// Очищаем ячейки, в которые попал ровно один хеш, пока такие находятся.
+			queueSize := uint32(0)
+			for i := uint32(0); i < capacity; i++ {
+				alone[queueSize] = i
+				if t2count[i]>>2 == 1 {
+					queueSize++
+				}
+			}
+
+			stackSize := uint32(0)
+			for queueSize > 0 {
+				queueSize--
+				idx := alone[queueSize]
+				if t2count[idx]>>2 != 1 {
+					continue
+				}
+
+				hash := t2hash[idx]
+				found := t2count[idx] & 3
+				reverseH[stackSize] = found
+				reverseOrder[stackSize] = hash
+				stackSize++
+
+				i0, i1, i2 := f.hashPositions(hash)
+				h012[1], h012[2], h012[3], h012[4] = i1, i2, i0, i1
+
+				for n := uint8(1); n <= 2; n++ {
+					other := h012[found+n]
+					alone[queueSize] = other
+					if t2count[other]>>2 == 2 {
+						queueSize++
+					}
+					t2count[other] -= 4
+					t2count[other] ^= mod3(found + n)
+					t2hash[other] ^= hash
+				}
+			}
+
+			if stackSize == size {
+				break
+			}
+		}
+
+		// Не получилось: пробуем с другим seed.
+		clear(reverseOrder[:size])
+		clear(t2count)
+		clear(t2hash)
+		f.seed = splitMix64(&rng)
+	}
+
+	for i := int(size) - 1; i >= 0; i-- {
+		hash := reverseOrder[i]
+		i0, i1, i2 := f.hashPositions(hash)
+		h012[0], h012[1], h012[2], h012[3], h012[4] = i0, i1, i2, i0, i1
+
+		found := reverseH[i]
+		f.fingerprints[h012[found]] = T(hash^(hash>>32)) ^
+			f.fingerprints[h012[found+1]] ^
+			f.fingerprints[h012[found+2]]
+	}
+
+	return nil
+}
+
+func fuseSegmentLength(size uint32) uint32 {
+	if size == 0 {
+		return 4
+	}
+
+	res := uint32(1) << int(math.Floor(math.Log(float64(size))/math.Log(3.33)+2.25))
+	if res > fuseMaxSegment {
+		return fuseMaxSegment
+	}
+
+	return res
+}
+
+func fuseSizeFactor(size uint32) float64 {
+	return math.Max(1.125, 0.875+0.25*math.Log(1000000)/math.Log(float64(size)))
+}
+
+func mod3(x uint8) uint8 {
+	if x > 2 {
+		x -= 3
+	}
+
+	return x
+}
+
+// hashString - FNV-1a, без аллокаций.
+func hashString[S string | []byte](w S) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(w); i++ {
+		h ^= uint64(w[i])
+		h *= 1099511628211
+	}
+
+	return h
+}
+
+func mixSplit(key, seed uint64) uint64 {
+	h := key + seed
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+
+	return h
+}
+
+func splitMix64(seed *uint64) uint64 {
+	*seed += 0x9e3779b97f4a7c15
+	z := *seed
+	z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+	z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+
+	return z ^ (z >> 31)
+}
@@ -0,0 +1,157 @@
+package bloomfilter
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func fuseKeys(n int, prefix string) []string {
+	res := make([]string, n)
+	for i := range res {
+		res[i] = prefix + strconv.Itoa(i)
+	}
+
+	return res
+}
+
+func falsePositives(f MembershipFilter, probes []string) float64 {
+	fp := 0
+	for _, w := range probes {
+		if f.Test(w) {
+			fp++
+		}
+	}
+
+	return float64(fp) / float64(len(probes))
+}
+
+func TestBinaryFuse(t *testing.T) {
+	keys := fuseKeys(100000, "слово-")
+	probes := fuseKeys(100000, "не-слово-")
+
+	for _, tc := range []struct {
+		name   string
+		fpr    float64
+		maxFPR float64
+		bits   float64
+	}{
+		{name: "Fuse8", fpr: 0.005, maxFPR: 0.006, bits: 8},
+		{name: "Fuse16", fpr: 0.0001, maxFPR: 0.0001, bits: 16},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			f := newBinaryFuse(uint(len(keys)), tc.fpr)
+			for _, w := range keys {
+				f.Add(w)
+			}
+			// Дубликаты не мешают построению.
+			f.Add(keys[0])
+			require.False(t, f.Test(keys[0]))
+			require.NoError(t, f.Seal())
+			require.NoError(t, f.Seal())
+
+			for _, w := range keys {
+				require.True(t, f.Test(w), w)
+			}
+			require.Less(t, falsePositives(f, probes), tc.maxFPR)
+
+			bitsPerKey := float64(f.BitsCount()) / float64(len(keys))
+			require.Less(t, bitsPerKey, tc.bits*1.2)
+
+			data, err := f.MarshalBinary()
+			require.NoError(t, err)
+
+			loaded, err := decodeFilter(append(append([]byte("WSMF"), mustType(t, f)), data...))
+			require.NoError(t, err)
+			require.True(t, f.Equal(loaded))
+			require.True(t, loaded.Test(keys[len(keys)-1]))
+		})
+	}
+}
+
+func TestBinaryFuse_SmallSets(t *testing.T) {
+	for n := 0; n < 20; n++ {
+		f := newBinaryFuse(uint(n), 0.005)
+		keys := fuseKeys(n, "w")
+		for _, w := range keys {
+			f.Add(w)
+		}
+		require.NoError(t, f.Seal(), "size: %d", n)
+
+		for _, w := range keys {
+			require.True(t, f.Test(w), "size: %d, key: %s", n, w)
+		}
+	}
+}
+
+func TestBinaryFuse_Merge(t *testing.T) {
+	f := newBinaryFuse(0, 0.005)
+	shard := f.Shard()
+
+	f.Add("первое")
+	shard.Add("второе")
+	require.NoError(t, f.Merge(shard))
+	require.Error(t, f.Merge(newBloomFilter(10, 0.01)))
+	require.NoError(t, f.Seal())
+
+	require.True(t, f.Test("первое"))
+	require.True(t, f.Test("второе"))
+	require.EqualError(t, f.Merge(f.Shard()), "can not merge sealed binary fuse filters")
+}
+
+func TestBinaryFuse_SmallerThanBloom(t *testing.T) {
+	keys := fuseKeys(100000, "слово-")
+
+	bf := newBloomFilter(uint(len(keys)), 1.0/256)
+	fuse := newBinaryFuse(uint(len(keys)), 1.0/256)
+	for _, w := range keys {
+		bf.Add(w)
+		fuse.Add(w)
+	}
+	require.NoError(t, fuse.Seal())
+
+	require.Less(t, float64(fuse.BitsCount()), float64(bf.BitsCount())*0.85)
+}
+
+func TestDecodeFilter(t *testing.T) {
+	bf := newBloomFilter(100, 0.01)
+	bf.Add("хрензначо")
+
+	t.Run("Legacy", func(t *testing.T) {
+		data, err := bf.impl.GobEncode()
+		require.NoError(t, err)
+
+		loaded, err := decodeFilter(data)
+		require.NoError(t, err)
+		require.True(t, bf.Equal(loaded))
+	})
+	t.Run("Header", func(t *testing.T) {
+		data, err := encodeFilter(bf)
+		require.NoError(t, err)
+		require.Equal(t, "WSMF\x01", string(data[:5]))
+
+		loaded, err := decodeFilter(data)
+		require.NoError(t, err)
+		require.True(t, bf.Equal(loaded))
+	})
+	t.Run("UnknownType", func(t *testing.T) {
+		_, err := decodeFilter([]byte("WSMF\x09"))
+		require.EqualError(t, err, "unknown membership filter type 9")
+	})
+	t.Run("Truncated", func(t *testing.T) {
+		_, err := decodeFilter([]byte("WSMF"))
+		require.EqualError(t, err, "membership filter data is truncated")
+	})
+	t.Run("UnsealedFuse", func(t *testing.T) {
+		_, err := encodeFilter(newBinaryFuse(10, 0.01))
+		require.EqualError(t, err, "binary fuse filter is not sealed")
+	})
+}
+
+func mustType(t *testing.T, f MembershipFilter) byte {
+	ft, err := typeOf(f)
+	require.NoError(t, err)
+
+	return ft
+}
@@ -1,5 +1,100 @@
 package bloomfilter
 
+// Режимы получения фильтра при старте сервиса.
+const (
+	// StartupLoad - фильтр загружается из хранилища, без него сервис не стартует (по умолчанию).
+	StartupLoad = "load"
+	// StartupFallback - фильтр загружается из хранилища, а если его там нет - строится по индексу.
+	StartupFallback = "fallback"
+	// StartupBuild - фильтр всегда строится по индексу, сохраненный не используется.
+	StartupBuild = "build"
+	// StartupValidate - фильтр загружается из хранилища и сверяется с построенным по индексу.
+	// При расхождении используется построенный.
+	StartupValidate = "validate"
+)
+
+// Типы фильтра.
+const (
+	// FilterBloom - классический bloom-фильтр (по умолчанию).
+	FilterBloom = "bloom"
+	// FilterBinaryFuse - статический binary fuse filter, заметно меньше bloom-фильтра при той же доле ошибок.
+	// Отпечатки 8-битные (доля ложноположительных ответов 1/256), если FalsePositiveRate меньше - 16-битные.
+	FilterBinaryFuse = "fuse"
+)
+
 type Options struct {
 	FalsePositiveRate float64
+	// MemoryBudget - объем памяти фильтра в байтах. Если задан, доля ложноположительных ответов
+	// подбирается под него по числу уникальных удалений, а FalsePositiveRate не учитывается.
+	MemoryBudget uint64
+	// Filter - тип фильтра, который строит билдер: FilterBloom или FilterBinaryFuse.
+	// Загружается фильтр того типа, с которым он был сохранен.
+	Filter string
+	// Startup - режим получения фильтра при старте сервиса, см. StartupLoad и прочие.
+	Startup string
+	// Workers - количество горутин, строящих фильтр. По умолчанию - по числу ядер.
+	Workers int
+
+	// PerLanguage - строить по фильтру на каждый язык (ключи LangStoreKey) вместо общего для всех языков.
+	PerLanguage bool
+	// Langs - настройки фильтров отдельных языков, заданные поля перекрывают общие. Учитываются при PerLanguage.
+	Langs map[string]LangOptions
+
+	// Rare - фильтр редких слов каталога (RareStoreKey).
+	Rare RareOptions
+}
+
+// RareOptions - настройки фильтра редких слов: слов каталога, которые встречаются реже порогов частоты индекса.
+// Ложноположительный ответ такого фильтра оставляет опечатку неисправленной, поэтому доля ошибок по умолчанию
+// ниже, чем у фильтра удалений.
+type RareOptions struct {
+	// Enabled - билдер строит фильтр редких слов, а сервис его загружает и такие слова не исправляет.
+	Enabled           bool
+	FalsePositiveRate float64
+	Filter            string
+}
+
+// LangOptions - настройки фильтра одного языка.
+type LangOptions struct {
+	FalsePositiveRate float64
+	MemoryBudget      uint64
+	Filter            string
+}
+
+// ForRare отдает настройки фильтра редких слов. Бюджет памяти фильтра удалений к нему не относится.
+func (o *Options) ForRare() *Options {
+	res := *o
+	res.MemoryBudget = 0
+	res.FalsePositiveRate = defaultRareFalsePositiveRate
+
+	if o.Rare.FalsePositiveRate > 0.0 {
+		res.FalsePositiveRate = o.Rare.FalsePositiveRate
+	}
+	if o.Rare.Filter != "" {
+		res.Filter = o.Rare.Filter
+	}
+
+	return &res
+}
+
+// ForLang отдает настройки фильтра языка lang.
+func (o *Options) ForLang(lang string) *Options {
+	res := *o
+
+	lo, ok := o.Langs[lang]
+	if !ok {
+		return &res
+	}
+
+	if lo.FalsePositiveRate > 0.0 {
+		res.FalsePositiveRate = lo.FalsePositiveRate
+	}
+	if lo.MemoryBudget > 0 {
+		res.MemoryBudget = lo.MemoryBudget
+	}
+	if lo.Filter != "" {
+		res.Filter = lo.Filter
+	}
+
+	return &res
 }
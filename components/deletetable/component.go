@@ -0,0 +1,180 @@
+// Package deletetable - компактная таблица удалений для поиска исправлений по алгоритму SymSpell.
+//
+// Для каждого слова индекса хранятся хеши всех его удалений (вместе с самим словом)
+// и номер слова. Хеши лежат в отсортированном массиве uint64, номера слов - в параллельном массиве uint32,
+// так что одна пара удаление-слово занимает 12 байт, а поиск - двоичный, без аллокаций.
+// Кандидаты на исправление слова - слова таблицы, у которых с ним есть общее удаление:
+// их не нужно искать перебором вставок по всему алфавиту.
+package deletetable
+
+import (
+	"cmp"
+	"runtime"
+	"slices"
+	"sort"
+	"sync"
+	"unicode/utf8"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/cannonflesh/wordspell/domain"
+)
+
+type Component struct {
+	words  []string
+	hashes []uint64
+	ids    []uint32
+
+	logger *logrus.Entry
+}
+
+// New создает пустую таблицу, в которой не найдется ни одного кандидата.
+// Заполняется таблица методом Build.
+func New(logger *logrus.Entry) *Component {
+	return &Component{
+		logger: logger.WithField(domain.CategoryFieldName, "components.delete_table"),
+	}
+}
+
+type entry struct {
+	hash uint64
+	id   uint32
+}
+
+// Build заполняет таблицу удалениями слов words, которые перебирает функция eachDelete
+// (wordmutate.Component.EachDelete: удаление передается в fn байтами, без аллокаций).
+// Слова делятся на workers равных частей, удаления каждой части собираются в своей горутине,
+// затем все пары сортируются по хешу, повторы удаляются.
+func (c *Component) Build(words []string, eachDelete func(w string, fn func(d []byte) bool) bool, workers int) error {
+	if uint64(len(words)) > uint64(^uint32(0)) {
+		return errors.Errorf("too many words for delete table: %d", len(words))
+	}
+
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	workers = max(min(workers, len(words)), 1)
+
+	parts := make([][]entry, workers)
+	chunk := (len(words) + workers - 1) / workers
+
+	wg := sync.WaitGroup{}
+	for i := range parts {
+		start, end := min(i*chunk, len(words)), min((i+1)*chunk, len(words))
+
+		wg.Add(1)
+		go func(i, start, end int) {
+			defer wg.Done()
+
+			var id int
+			add := func(d []byte) bool {
+				parts[i] = append(parts[i], entry{hash: hashString(d), id: uint32(id)})
+
+				return true
+			}
+			for id = start; id < end; id++ {
+				eachDelete(words[id], add)
+			}
+		}(i, start, end)
+	}
+	wg.Wait()
+
+	total := 0
+	for _, p := range parts {
+		total += len(p)
+	}
+
+	entries := make([]entry, 0, total)
+	for i, p := range parts {
+		entries = append(entries, p...)
+		parts[i] = nil
+	}
+
+	slices.SortFunc(entries, func(a, b entry) int {
+		if res := cmp.Compare(a.hash, b.hash); res != 0 {
+			return res
+		}
+
+		return cmp.Compare(a.id, b.id)
+	})
+	entries = slices.Compact(entries)
+
+	c.words = words
+	c.hashes = make([]uint64, len(entries))
+	c.ids = make([]uint32, len(entries))
+	for i, e := range entries {
+		c.hashes[i] = e.hash
+		c.ids[i] = e.id
+	}
+
+	return nil
+}
+
+// Len отдает количество пар удаление-слово в таблице.
+func (c *Component) Len() int {
+	return len(c.hashes)
+}
+
+// Bytes отдает объем памяти, занимаемой массивами таблицы (без самих слов).
+func (c *Component) Bytes() uint64 {
+	return uint64(len(c.hashes))*8 + uint64(len(c.ids))*4
+}
+
+// Candidates вызывает fn для каждого слова таблицы, одним из удалений которого является d.
+// Совпадения хешей проверяются, так что fn получает только настоящих кандидатов. Если fn отдает false, перебор прекращается.
+// Удаление передается байтами, чтобы его можно было брать прямо из буфера генератора wordmutate.Component.EachDelete.
+func (c *Component) Candidates(d []byte, fn func(w string) bool) {
+	h := hashString(d)
+
+	i := sort.Search(len(c.hashes), func(i int) bool {
+		return c.hashes[i] >= h
+	})
+	for ; i < len(c.hashes) && c.hashes[i] == h; i++ {
+		w := c.words[c.ids[i]]
+		if !isDelete(d, w) {
+			continue
+		}
+
+		if !fn(w) {
+			return
+		}
+	}
+}
+
+// isDelete сообщает, получается ли d из w удалением рун. Отсекает случайные совпадения хешей.
+func isDelete(d []byte, w string) bool {
+	if len(d) > len(w) {
+		return false
+	}
+
+	for _, r := range w {
+		if len(d) == 0 {
+			break
+		}
+
+		dr, size := utf8.DecodeRune(d)
+		if dr == r {
+			d = d[size:]
+		}
+	}
+
+	return len(d) == 0
+}
+
+// hashString - FNV-1a с финальным перемешиванием murmur3, без аллокаций.
+func hashString[S string | []byte](w S) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(w); i++ {
+		h ^= uint64(w[i])
+		h *= 1099511628211
+	}
+
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+
+	return h
+}
@@ -0,0 +1,76 @@
+package deletetable
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cannonflesh/wordspell/components/wordmutate"
+	"github.com/cannonflesh/wordspell/testdata"
+)
+
+func candidates(c *Component, d string) []string {
+	var res []string
+	c.Candidates([]byte(d), func(w string) bool {
+		res = append(res, w)
+
+		return true
+	})
+	sort.Strings(res)
+
+	return res
+}
+
+func TestComponent_Candidates(t *testing.T) {
+	lgr, lbuf := testdata.NewTestLogger()
+	mutate := wordmutate.New()
+	words := []string{"ящик", "ящики", "для", "дня", "the", "they", "организация"}
+
+	empty := New(lgr)
+	require.Empty(t, candidates(empty, "ящик"))
+
+	for _, workers := range []int{1, 3, 16} {
+		c := New(lgr)
+		require.NoError(t, c.Build(words, mutate.EachDelete, workers))
+
+		require.Equal(t, []string{"ящик", "ящики"}, candidates(c, "ящк"))
+		require.Equal(t, []string{"ящики"}, candidates(c, "яки"))
+		require.Equal(t, []string{"для", "дня"}, candidates(c, "д"))
+		require.Equal(t, []string{"the", "they"}, candidates(c, "the"))
+		require.Equal(t, []string{"организация"}, candidates(c, "органзаця"))
+		require.Empty(t, candidates(c, "оргация"))
+		require.Empty(t, candidates(c, "хрензначо"))
+	}
+
+	c := New(lgr)
+	require.NoError(t, c.Build(words, mutate.EachDelete, 2))
+
+	var first []string
+	c.Candidates([]byte("д"), func(w string) bool {
+		first = append(first, w)
+
+		return false
+	})
+	require.Len(t, first, 1)
+
+	// Повторяющиеся удаления одного слова (в "организация" три "а") хранятся один раз.
+	total := 0
+	for _, w := range words {
+		total += len(mutate.Deletes(w))
+	}
+	require.Less(t, c.Len(), total)
+	require.Equal(t, uint64(c.Len())*12, c.Bytes())
+
+	require.Empty(t, lbuf.String())
+}
+
+func TestIsDelete(t *testing.T) {
+	require.True(t, isDelete([]byte("ящк"), "ящик"))
+	require.True(t, isDelete([]byte("ящик"), "ящик"))
+	require.True(t, isDelete([]byte("як"), "ящик"))
+	require.True(t, isDelete([]byte("я"), "ящик"))
+	require.False(t, isDelete([]byte("кя"), "ящик"))
+	require.False(t, isDelete([]byte("яшк"), "ящик"))
+	require.False(t, isDelete([]byte("ящики"), "ящик"))
+}
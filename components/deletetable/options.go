@@ -0,0 +1,9 @@
+package deletetable
+
+type Options struct {
+	// Enabled - искать исправления по таблице удалений (классический SymSpell) вместо bloom-фильтра
+	// и перебора вставок. Таблица строится по индексу при старте сервиса, bloom-фильтр тогда не загружается.
+	Enabled bool
+	// Workers - количество горутин, строящих таблицу. По умолчанию - по числу ядер.
+	Workers int
+}
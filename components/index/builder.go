@@ -1,7 +1,8 @@
 package index
 
 import (
-	"bytes"
+	"io"
+	"math/bits"
 	"regexp"
 	"sort"
 	"strings"
@@ -12,18 +13,23 @@ import (
 	"github.com/sirupsen/logrus"
 
 	"github.com/cannonflesh/wordspell/domain"
+	"github.com/cannonflesh/wordspell/internal/stream"
+	"github.com/cannonflesh/wordspell/options"
 )
 
 var (
 	htmlAddSpacesRE = regexp.MustCompile("([^\\s>])(<)")
 )
 
+// Значения options.IndexBuilder по умолчанию.
 const (
 	categoryNameBatchLen = 10000
 	itemDataBatchLen     = 100000
 	pairFreqTreshold     = 50
 	ruIndexFreqTreshold  = 23
 	enIndexFreqTreshold  = 10
+	sourceWeight         = 1
+	rareMinItems         = 2
 )
 
 // DataSource извлекает все данные в память, но позволяет это делать постепенно.
@@ -44,61 +50,116 @@ type Builder struct {
 	store  DataStore
 	langs  langDetector
 	logger *logrus.Entry
+	// opt - настройки построения с подставленными значениями по умолчанию.
+	opt options.IndexBuilder
+
+	// rare - слова, не прошедшие пороги частоты при последнем построении индекса.
+	rare []string
+	// stats - статистика слов и пар каталога при последнем построении индекса.
+	stats *stats
 }
 
-func NewBuilder(source DataSource, store DataStore, langs langDetector, l *logrus.Entry) *Builder {
+// NewBuilder создает билдер индексов с настройками opt.Index.
+func NewBuilder(opt *options.Options, source DataSource, store DataStore, langs langDetector, l *logrus.Entry) *Builder {
 	return &Builder{
 		source: source,
 		store:  store,
 		langs:  langs,
 		logger: l.WithField(domain.CategoryFieldName, "component.speller_index_builder"),
+		opt:    builderOptions(&opt.Index),
 	}
 }
 
-func (b *Builder) LoadIndexFromDB() error {
-	res := newData()
+// builderOptions отдает копию opt, в которой незаданные поля заменены значениями по умолчанию.
+func builderOptions(opt *options.IndexBuilder) options.IndexBuilder {
+	res := *opt
+
+	res.Thresholds = map[string]uint32{
+		ruLangCode: ruIndexFreqTreshold,
+		enLangCode: enIndexFreqTreshold,
+	}
+	for lang, t := range opt.Thresholds {
+		res.Thresholds[lang] = t
+	}
+
+	res.SourceWeights = map[string]uint32{
+		options.SourceItemName:    sourceWeight,
+		options.SourceDescription: sourceWeight,
+		options.SourceCategory:    sourceWeight,
+	}
+	for source, w := range opt.SourceWeights {
+		res.SourceWeights[source] = w
+	}
+
+	if res.Weight == "" {
+		res.Weight = options.WeightTF
+	}
+	if res.PairThreshold == 0 {
+		res.PairThreshold = pairFreqTreshold
+	}
+	if res.RareMinItems == 0 {
+		res.RareMinItems = rareMinItems
+	}
+	if res.ItemBatchSize <= 0 {
+		res.ItemBatchSize = itemDataBatchLen
+	}
+	if res.CategoryBatchSize <= 0 {
+		res.CategoryBatchSize = categoryNameBatchLen
+	}
+
+	return res
+}
 
-	err := b.buildItemIndex(res, itemDataBatchLen)
+func (b *Builder) LoadIndexFromDB() error {
+	weight, err := weightFormula(b.opt.Weight)
 	if err != nil {
 		return err
 	}
 
-	err = b.buildCategoryIndex(res, categoryNameBatchLen)
+	res := newStats()
+
+	err = b.buildItemIndex(res, b.opt.ItemBatchSize)
 	if err != nil {
 		return err
 	}
 
-	for k, v := range res.words[ruLangCode] {
-		if v < ruIndexFreqTreshold {
-			delete(res.words[ruLangCode], k)
-		}
+	err = b.buildCategoryIndex(res, b.opt.CategoryBatchSize)
+	if err != nil {
+		return err
 	}
 
-	for k, v := range res.words[enLangCode] {
-		if v < enIndexFreqTreshold {
-			delete(res.words[enLangCode], k)
+	idx := make(wordCollection, len(res.words))
+
+	b.rare = b.rare[:0]
+	for lang, words := range res.words {
+		idx[lang] = make(map[word]frequency)
+		threshold := b.opt.Thresholds[lang]
+		for k, st := range words {
+			if w := weight(&st); w >= threshold && st.docs >= b.opt.MinItems {
+				idx[lang][k] = w
+			} else if st.docs >= b.opt.RareMinItems {
+				b.rare = append(b.rare, k)
+			}
 		}
 	}
 
-	for k, v := range res.dwords[ruLangCode] {
-		if v < pairFreqTreshold {
-			delete(res.dwords[ruLangCode], k)
+	for lang, dwords := range res.dwords {
+		if idx[lang] == nil {
+			idx[lang] = make(map[word]frequency)
 		}
-	}
-
-	for k, v := range res.dwords[enLangCode] {
-		if v < pairFreqTreshold {
-			delete(res.dwords[enLangCode], k)
+		for k, st := range dwords {
+			if w := weight(&st); w >= b.opt.PairThreshold && st.docs >= b.opt.MinItems {
+				idx[lang][k] = w
+			}
 		}
 	}
 
-	res.words.merge(res.dwords)
-	res.dwords = nil
+	b.stats = res
 
-	for lang := range res.words {
+	for lang := range idx {
 		startSave := time.Now()
 		b.logger.Infof("[LANG INDEX SAVE] saving index, lang: %s", lang)
-		if err = b.saveLangIndex(lang, res.words); err != nil {
+		if err = b.saveLangIndex(lang, idx); err != nil {
 			return err
 		}
 		b.logger.Infof("[LANG INDEX SAVE] index saved in %v, lang: %s", time.Since(startSave), lang)
@@ -107,7 +168,57 @@ func (b *Builder) LoadIndexFromDB() error {
 	return nil
 }
 
-func (b *Builder) buildItemIndex(res *data, batchSize int) error {
+// weightFormula отдает формулу веса слова в индексе по ее названию (options.IndexBuilder.Weight).
+func weightFormula(name string) (func(st *wordStats) frequency, error) {
+	switch name {
+	case options.WeightTF:
+		return func(st *wordStats) frequency { return st.tf }, nil
+	case options.WeightDF:
+		return func(st *wordStats) frequency { return st.df }, nil
+	case options.WeightDFSources:
+		return func(st *wordStats) frequency {
+			return st.df * frequency(bits.OnesCount8(uint8(st.sources)))
+		}, nil
+	}
+
+	return nil, errors.Errorf("unknown index weight formula: %q", name)
+}
+
+// RareWords отдает слова каталога, которые встречаются реже порогов частоты и потому не попали в индекс
+// при последнем LoadIndexFromDB, но встретились не меньше чем в RareMinItems товарах и категориях.
+// Пары слов сюда не входят.
+func (b *Builder) RareWords() []string {
+	return b.rare
+}
+
+// Release освобождает RareWords и Stats последнего LoadIndexFromDB, когда они больше не нужны:
+// статистика всех слов каталога занимает заметно больше памяти, чем сам индекс.
+func (b *Builder) Release() {
+	b.rare = nil
+	b.stats = nil
+}
+
+// Stats отдает статистику слова или пары слов ("left right") языка lang по каталогу при последнем LoadIndexFromDB,
+// в том числе слов, не попавших в индекс.
+func (b *Builder) Stats(lang, w string) (WordStats, bool) {
+	if b.stats == nil {
+		return WordStats{}, false
+	}
+
+	st, found := b.stats.words[lang][w]
+	if !found {
+		st, found = b.stats.dwords[lang][w]
+	}
+
+	return st.export(), found
+}
+
+// Options отдает настройки построения с подставленными значениями по умолчанию.
+func (b *Builder) Options() options.IndexBuilder {
+	return b.opt
+}
+
+func (b *Builder) buildItemIndex(res *stats, batchSize int) error {
 	start := 0
 	startTime := time.Now()
 	b.logger.Info("[ITEM INDEX BUILD] start building")
@@ -121,14 +232,16 @@ func (b *Builder) buildItemIndex(res *data, batchSize int) error {
 			return err
 		}
 
-		for _, n := range names {
-			wordSlice := textPreProcess(n)
-			b.processWordSlice(res, wordSlice)
-		}
+		// Названия и описания идут парами, по строке на товар.
+		item := newData()
+		for i, n := range names {
+			item.reset()
+			b.processWordSlice(item, textPreProcess(n), options.SourceItemName)
+			if i < len(descs) {
+				b.processWordSlice(item, htmlPreProcess(descs[i]), options.SourceDescription)
+			}
 
-		for _, d := range descs {
-			wordSlice := htmlPreProcess(d)
-			b.processWordSlice(res, wordSlice)
+			res.add(item, b.opt.MaxItemFrequency)
 		}
 
 		totalNames += len(names)
@@ -155,7 +268,7 @@ func (b *Builder) buildItemIndex(res *data, batchSize int) error {
 	return nil
 }
 
-func (b *Builder) buildCategoryIndex(res *data, batchSize int) error {
+func (b *Builder) buildCategoryIndex(res *stats, batchSize int) error {
 	start := 0
 	startTime := time.Now()
 	b.logger.Info("[CATEGORY INDEX BUILD] start building")
@@ -166,9 +279,12 @@ func (b *Builder) buildCategoryIndex(res *data, batchSize int) error {
 			return err
 		}
 
+		category := newData()
 		for _, l := range lines {
-			wordSlice := textPreProcess(l)
-			b.processWordSlice(res, wordSlice)
+			category.reset()
+			b.processWordSlice(category, textPreProcess(l), options.SourceCategory)
+
+			res.add(category, b.opt.MaxItemFrequency)
 		}
 
 		b.logger.Infof("[CATEGORY INDEX BUILD] total: %d, elapsed: %v", start+len(lines), time.Since(startTime))
@@ -193,9 +309,9 @@ func (b *Builder) saveLangIndex(lang langCode, idx wordCollection) error {
 		return errors.New("no index for the such language: " + ruLangCode)
 	}
 
-	sorted := make([]*wordFrequency, 0, len(idx[lang]))
+	sorted := make([]wordFrequency, 0, len(idx[lang]))
 	for k, v := range idx[lang] {
-		sorted = append(sorted, &wordFrequency{
+		sorted = append(sorted, wordFrequency{
 			word:      k,
 			frequency: v,
 		})
@@ -205,15 +321,18 @@ func (b *Builder) saveLangIndex(lang langCode, idx wordCollection) error {
 		return sorted[i].frequency > sorted[j].frequency
 	})
 
-	writeBuffer := new(bytes.Buffer)
-	for _, line := range sorted {
-		_, err := writeBuffer.Write(line.toLine())
-		if err != nil {
-			return errors.WithStack(err)
+	// Индекс пишется в хранилище по мере сериализации, без промежуточного буфера на весь индекс.
+	return stream.Save(b.store, langCodeIndexKey(lang), func(w io.Writer) error {
+		var line []byte
+		for i := range sorted {
+			line = sorted[i].appendLine(line[:0])
+			if _, err := w.Write(line); err != nil {
+				return errors.WithStack(err)
+			}
 		}
-	}
 
-	return b.store.Save(langCodeIndexKey(lang), writeBuffer)
+		return nil
+	})
 }
 
 const (
@@ -221,7 +340,14 @@ const (
 	keySuffix    = ".index"
 )
 
-func (b *Builder) processWordSlice(d *data, ws []string) {
+// processWordSlice добавляет к d слова и пары слов ws из источника source, каждое вхождение - с весом источника.
+func (b *Builder) processWordSlice(d *data, ws []string, source string) {
+	weight := b.opt.SourceWeights[source]
+	if weight == 0 {
+		return
+	}
+	mask := sourceBits[source]
+
 	for i := 0; i < len(ws); i++ {
 		start := i
 		end := i + wordPairSize
@@ -235,11 +361,10 @@ func (b *Builder) processWordSlice(d *data, ws []string) {
 		}
 
 		if left != "" {
-			d.words[lang][left] = d.words[lang][left] + 1
+			d.words.add(lang, left, weight, mask)
 
 			if right != "" {
-				dword := left + " " + right
-				d.dwords[lang][dword] = d.dwords[lang][dword] + 1
+				d.dwords.add(lang, left+" "+right, weight, mask)
 			}
 		}
 	}
@@ -301,3 +426,40 @@ func wordFilter(w string) bool {
 func langCodeIndexKey(l langCode) string {
 	return l + keySuffix
 }
+
+// StoreKey отдает ключ индекса языка lang в DataStore.
+func StoreKey(lang string) string {
+	return langCodeIndexKey(lang)
+}
+
+// WordThreshold отдает порог частоты по умолчанию, ниже которого слова языка lang не попадают в индекс.
+// 0 - для других языков.
+func WordThreshold(lang string) uint32 {
+	switch lang {
+	case ruLangCode:
+		return ruIndexFreqTreshold
+	case enLangCode:
+		return enIndexFreqTreshold
+	}
+
+	return 0
+}
+
+// Thresholds отдает пороги частоты, с которыми строятся индексы: <lang>_word - слов языка lang, pair - пар слов.
+func (b *Builder) Thresholds() map[string]uint32 {
+	res := make(map[string]uint32, len(b.opt.Thresholds)+1)
+	for lang, t := range b.opt.Thresholds {
+		res[ThresholdKey(lang)] = t
+	}
+	res[pairThresholdKey] = b.opt.PairThreshold
+
+	return res
+}
+
+// pairThresholdKey - ключ порога частоты пар слов в Thresholds.
+const pairThresholdKey = "pair"
+
+// ThresholdKey отдает ключ порога частоты слов языка lang в Thresholds.
+func ThresholdKey(lang string) string {
+	return lang + "_word"
+}
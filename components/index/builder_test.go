@@ -1,11 +1,17 @@
 package index
 
 import (
+	"fmt"
+	"io"
+	"runtime"
+	"strings"
 	"testing"
 
+	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 
 	"github.com/cannonflesh/wordspell/components/langdetect"
+	"github.com/cannonflesh/wordspell/options"
 	"github.com/cannonflesh/wordspell/testdata"
 )
 
@@ -46,19 +52,247 @@ func TestComponent_processWordSlice(t *testing.T) {
 	source := NewMockDataSource(t)
 	store := NewMockDataStore(t)
 
-	d := NewBuilder(source, store, langer, l)
+	d := NewBuilder(&options.Options{}, source, store, langer, l)
 	ws := []string{"один", "два", "one", "три", "two", "четыре", "пять", "three", "four", "oneодин", "шесть"}
 	dt := newData()
-	d.processWordSlice(dt, ws)
+	d.processWordSlice(dt, ws, options.SourceItemName)
 	require.Len(t, dt.words[enLangCode], 4)
-	require.Equal(t, frequency(1), dt.words[enLangCode]["four"])
+	require.Equal(t, frequency(1), dt.words[enLangCode]["four"].tf)
 	require.Len(t, dt.words[ruLangCode], 6)
-	require.Equal(t, frequency(1), dt.words[ruLangCode]["пять"])
-	require.Equal(t, frequency(1), dt.words[ruLangCode]["шесть"])
+	require.Equal(t, frequency(1), dt.words[ruLangCode]["пять"].tf)
+	require.Equal(t, frequency(1), dt.words[ruLangCode]["шесть"].tf)
 	require.Len(t, dt.dwords[enLangCode], 1)
-	require.Equal(t, frequency(1), dt.dwords[enLangCode]["three four"])
+	require.Equal(t, frequency(1), dt.dwords[enLangCode]["three four"].tf)
 	require.Len(t, dt.dwords[ruLangCode], 2)
-	require.Equal(t, frequency(1), dt.dwords[ruLangCode]["четыре пять"])
-	require.Equal(t, frequency(0), dt.words[ruLangCode]["oneодин"])
-	require.Equal(t, frequency(0), dt.words[enLangCode]["oneодин"])
+	require.Equal(t, frequency(1), dt.dwords[ruLangCode]["четыре пять"].tf)
+	require.Equal(t, frequency(0), dt.words[ruLangCode]["oneодин"].tf)
+	require.Equal(t, frequency(0), dt.words[enLangCode]["oneодин"].tf)
+}
+
+func TestBuilder_saveLangIndex_Memory(t *testing.T) {
+	l, _ := testdata.NewTestLogger()
+	store := NewMockDataStore(t)
+
+	const wordsCount = 200000
+
+	idx := wordCollection{ruLangCode: make(map[word]frequency, wordsCount)}
+	for i := 0; i < wordsCount; i++ {
+		idx[ruLangCode][fmt.Sprintf("синтетическое-длинное-слово-%08d", i)] = frequency(i + 1)
+	}
+
+	var saved int64
+	store.EXPECT().Save(langCodeIndexKey(ruLangCode), mock.Anything).
+		RunAndReturn(func(_ string, content io.Reader) error {
+			var err error
+			saved, err = io.Copy(io.Discard, content)
+
+			return err
+		}).
+		Once()
+
+	b := NewBuilder(&options.Options{}, NewMockDataSource(t), store, langdetect.New(), l)
+
+	runtime.GC()
+	var before, after runtime.MemStats
+	runtime.ReadMemStats(&before)
+
+	require.NoError(t, b.saveLangIndex(ruLangCode, idx))
+
+	runtime.ReadMemStats(&after)
+
+	// Сериализованный индекс не накапливается в памяти: всего выделено заметно меньше его размера,
+	// это в основном слайс для сортировки.
+	allocated := int64(after.TotalAlloc - before.TotalAlloc)
+	require.Greater(t, saved, int64(wordsCount*60))
+	require.Less(t, allocated, saved/2)
+}
+
+func TestBuilder_LoadIndexFromDB_Options(t *testing.T) {
+	l, _ := testdata.NewTestLogger()
+
+	source := NewMockDataSource(t)
+	source.EXPECT().ItemData(0, 2).
+		Return([]string{"Красная ручка", "Синяя ручка"}, []string{"<p>ручка ручка ручка ручка ручка</p>", ""}, nil).
+		Once()
+	source.EXPECT().ItemData(2, 2).
+		Return([]string{"Pen"}, []string{"red pen"}, nil).
+		Once()
+	source.EXPECT().CategoryNames(0, 2).
+		Return([]string{"Ручки"}, nil).
+		Once()
+
+	saved := make(map[string]string)
+	store := NewMockDataStore(t)
+	store.EXPECT().Save(mock.Anything, mock.Anything).
+		RunAndReturn(func(key string, content io.Reader) error {
+			data, err := io.ReadAll(content)
+			saved[key] = string(data)
+
+			return err
+		}).
+		Times(2)
+
+	b := NewBuilder(&options.Options{
+		Index: options.IndexBuilder{
+			Thresholds:        map[string]uint32{ruLangCode: 3, enLangCode: 2},
+			PairThreshold:     100,
+			ItemBatchSize:     2,
+			CategoryBatchSize: 2,
+			SourceWeights: map[string]uint32{
+				options.SourceItemName: 2,
+				options.SourceCategory: 5,
+			},
+			MaxItemFrequency: 3,
+			RareMinItems:     1,
+		},
+	}, source, store, langdetect.New(), l)
+
+	require.NoError(t, b.LoadIndexFromDB())
+
+	// "ручка" в первом товаре: 2 за название и 5 за описание, но не больше 3 на товар, во втором - еще 2.
+	// Вес категории 5 тоже ограничен MaxItemFrequency.
+	require.ElementsMatch(t, []string{"ручка\t5", "ручки\t3"}, strings.Split(strings.TrimSpace(saved["ru.index"]), "\n"))
+	require.Equal(t, "pen\t3\n", saved["en.index"])
+	require.ElementsMatch(t, []string{"красная", "синяя", "red"}, b.RareWords())
+	require.Equal(t, map[string]uint32{"ru_word": 3, "en_word": 2, "pair": 100}, b.Thresholds())
+}
+
+func TestBuilder_Thresholds_Defaults(t *testing.T) {
+	l, _ := testdata.NewTestLogger()
+
+	b := NewBuilder(&options.Options{}, NewMockDataSource(t), NewMockDataStore(t), langdetect.New(), l)
+	require.Equal(t, map[string]uint32{"ru_word": 23, "en_word": 10, "pair": 50}, b.Thresholds())
+	require.Equal(t, uint32(23), WordThreshold(ruLangCode))
+	require.Zero(t, WordThreshold(numLangCode))
+}
+
+func TestBuilder_LoadIndexFromDB_DocumentFrequency(t *testing.T) {
+	l, _ := testdata.NewTestLogger()
+
+	source := NewMockDataSource(t)
+	source.EXPECT().ItemData(0, 10).
+		Return(
+			[]string{"Кроссовки", "Кроссовки беговые", "Беговые кроссовки"},
+			[]string{"<p>кросовки кросовки кросовки кросовки кросовки ##Name##</p>", "кроссовки", ""},
+			nil,
+		).
+		Once()
+	source.EXPECT().CategoryNames(0, 10).
+		Return([]string{"Кроссовки"}, nil).
+		Once()
+
+	saved := make(map[string]string)
+	store := NewMockDataStore(t)
+	store.EXPECT().Save(mock.Anything, mock.Anything).
+		RunAndReturn(func(key string, content io.Reader) error {
+			data, err := io.ReadAll(content)
+			saved[key] = string(data)
+
+			return err
+		})
+
+	b := NewBuilder(&options.Options{
+		Index: options.IndexBuilder{
+			Thresholds:        map[string]uint32{ruLangCode: 2, enLangCode: 1},
+			PairThreshold:     1,
+			ItemBatchSize:     10,
+			CategoryBatchSize: 10,
+			Weight:            options.WeightDF,
+			MinItems:          2,
+		},
+	}, source, store, langdetect.New(), l)
+
+	require.NoError(t, b.LoadIndexFromDB())
+
+	// Опечатка, повторенная в одном описании, и плейсхолдер шаблона встречаются лишь в одном товаре.
+	require.ElementsMatch(t, []string{"кроссовки\t4", "беговые\t2"}, strings.Split(strings.TrimSpace(saved["ru.index"]), "\n"))
+	require.Empty(t, saved["en.index"])
+	// Редкими они тоже не считаются: RareMinItems по умолчанию - 2.
+	require.Empty(t, b.RareWords())
+
+	st, found := b.Stats(ruLangCode, "кросовки")
+	require.True(t, found)
+	require.Equal(t, WordStats{TF: 5, DF: 1, Docs: 1, Sources: 1}, st)
+
+	st, found = b.Stats(ruLangCode, "кроссовки")
+	require.True(t, found)
+	require.Equal(t, WordStats{TF: 5, DF: 4, Docs: 4, Sources: 3}, st)
+
+	st, found = b.Stats(ruLangCode, "беговые кроссовки")
+	require.True(t, found)
+	require.Equal(t, WordStats{TF: 1, DF: 1, Docs: 1, Sources: 1}, st)
+
+	_, found = b.Stats(ruLangCode, "ботинки")
+	require.False(t, found)
+
+	b.Release()
+	require.Empty(t, b.RareWords())
+	_, found = b.Stats(ruLangCode, "кроссовки")
+	require.False(t, found)
+}
+
+func TestBuilder_LoadIndexFromDB_RareMinItems(t *testing.T) {
+	l, _ := testdata.NewTestLogger()
+
+	source := NewMockDataSource(t)
+	source.EXPECT().ItemData(0, 10).
+		Return(
+			[]string{"Ботильоны", "Ботильоны замшевые", "Ботинки", "Ботинки", "Ботинки"},
+			[]string{"ботиники", "", "", "", ""},
+			nil,
+		).
+		Times(3)
+	source.EXPECT().CategoryNames(0, 10).
+		Return(nil, nil).
+		Times(3)
+
+	store := NewMockDataStore(t)
+	store.EXPECT().Save(mock.Anything, mock.Anything).
+		RunAndReturn(func(_ string, content io.Reader) error {
+			_, err := io.Copy(io.Discard, content)
+
+			return err
+		})
+
+	for _, tc := range []struct {
+		name         string
+		rareMinItems uint32
+		expected     []string
+	}{
+		// Опечатка "ботиники" встретилась в одном товаре и редким словом не считается.
+		{name: "Default", expected: []string{"ботильоны"}},
+		{name: "All", rareMinItems: 1, expected: []string{"ботильоны", "замшевые", "ботиники"}},
+		{name: "Frequent", rareMinItems: 3, expected: nil},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			b := NewBuilder(&options.Options{
+				Index: options.IndexBuilder{
+					Thresholds:        map[string]uint32{ruLangCode: 3},
+					ItemBatchSize:     10,
+					CategoryBatchSize: 10,
+					RareMinItems:      tc.rareMinItems,
+				},
+			}, source, store, langdetect.New(), l)
+
+			require.NoError(t, b.LoadIndexFromDB())
+			require.ElementsMatch(t, tc.expected, b.RareWords())
+		})
+	}
+}
+
+func TestWeightFormula(t *testing.T) {
+	st := &wordStats{tf: 7, df: 3, docs: 2, sources: sourceBits[options.SourceItemName] | sourceBits[options.SourceCategory]}
+
+	for name, expected := range map[string]frequency{
+		options.WeightTF:        7,
+		options.WeightDF:        3,
+		options.WeightDFSources: 6,
+	} {
+		weight, err := weightFormula(name)
+		require.NoError(t, err)
+		require.Equal(t, expected, weight(st), name)
+	}
+
+	_, err := weightFormula("bm25")
+	require.EqualError(t, err, `unknown index weight formula: "bm25"`)
 }
@@ -1,8 +1,10 @@
 package index
 
 import (
-	"bytes"
+	"math/bits"
 	"strconv"
+
+	"github.com/cannonflesh/wordspell/options"
 )
 
 const numWeight = 1000
@@ -13,15 +15,13 @@ type wordFrequency struct {
 	frequency uint32
 }
 
-func (wf *wordFrequency) toLine() []byte {
-	var buf bytes.Buffer
-
-	buf.WriteString(wf.word)
-	buf.WriteString("\t")
-	buf.WriteString(strconv.FormatUint(uint64(wf.frequency), 10))
-	buf.WriteString("\n")
+// appendLine дописывает к dst строку индекса: слово и частоту, разделенные табулятором.
+func (wf *wordFrequency) appendLine(dst []byte) []byte {
+	dst = append(dst, wf.word...)
+	dst = append(dst, '\t')
+	dst = strconv.AppendUint(dst, uint64(wf.frequency), 10)
 
-	return buf.Bytes()
+	return append(dst, '\n')
 }
 
 type (
@@ -29,12 +29,70 @@ type (
 	word           = string
 	frequency      = uint32
 	wordCollection map[langCode]map[word]frequency
-	data           struct {
-		words  wordCollection
-		dwords wordCollection
+	// data - слова и пары слов одного товара (названия с описанием) или одной категории.
+	data struct {
+		words  itemCollection
+		dwords itemCollection
 	}
+	itemCollection map[langCode]map[word]itemWord
+	// stats - статистика слов и пар слов по всему каталогу.
+	stats struct {
+		words  statsCollection
+		dwords statsCollection
+	}
+	statsCollection map[langCode]map[word]wordStats
 )
 
+// sourceMask - набор источников, в которых встретилось слово, по биту на источник.
+type sourceMask uint8
+
+var sourceBits = map[string]sourceMask{
+	options.SourceItemName:    1 << 0,
+	options.SourceDescription: 1 << 1,
+	options.SourceCategory:    1 << 2,
+}
+
+// itemWord - слово или пара в одном товаре или категории.
+type itemWord struct {
+	// tf - вхождения с весами источников.
+	tf frequency
+	// weight - наибольший вес источника, в котором встретилось слово.
+	weight  frequency
+	sources sourceMask
+}
+
+// wordStats - статистика слова или пары по каталогу.
+type wordStats struct {
+	// tf - вхождения с весами источников, не больше MaxItemFrequency на товар или категорию.
+	tf frequency
+	// df - товары и категории со словом, каждый учитывается один раз, с весом itemWord.weight.
+	df frequency
+	// docs - число товаров и категорий со словом.
+	docs    frequency
+	sources sourceMask
+}
+
+// WordStats - статистика слова или пары слов по каталогу, см. Builder.Stats.
+type WordStats struct {
+	// TF - вхождения с весами источников, не больше MaxItemFrequency на товар или категорию.
+	TF uint32
+	// DF - товары и категории со словом: каждый учитывается один раз, с наибольшим весом источника, где встретилось слово.
+	DF uint32
+	// Docs - число товаров и категорий со словом.
+	Docs uint32
+	// Sources - в скольких разных источниках (названия, описания, категории) встретилось слово.
+	Sources int
+}
+
+func (ws *wordStats) export() WordStats {
+	return WordStats{
+		TF:      ws.tf,
+		DF:      ws.df,
+		Docs:    ws.docs,
+		Sources: bits.OnesCount8(uint8(ws.sources)),
+	}
+}
+
 const (
 	numLangCode     langCode = "num"
 	ruLangCode      langCode = "ru"
@@ -44,29 +102,66 @@ const (
 
 func newData() *data {
 	return &data{
-		words: wordCollection{
-			enLangCode: make(map[word]frequency),
-			ruLangCode: make(map[word]frequency),
+		words: itemCollection{
+			enLangCode: make(map[word]itemWord),
+			ruLangCode: make(map[word]itemWord),
 		},
-		dwords: wordCollection{
-			enLangCode: make(map[word]frequency),
-			ruLangCode: make(map[word]frequency),
+		dwords: itemCollection{
+			enLangCode: make(map[word]itemWord),
+			ruLangCode: make(map[word]itemWord),
 		},
 	}
 }
 
-func (d *data) merge(add *data) {
-	d.words.merge(add.words)
-	d.dwords.merge(add.dwords)
+// reset очищает слова, сохраняя выделенную под них память.
+func (d *data) reset() {
+	for _, words := range d.words {
+		clear(words)
+	}
+	for _, dwords := range d.dwords {
+		clear(dwords)
+	}
+}
+
+func (ic itemCollection) add(lang langCode, w word, weight frequency, source sourceMask) {
+	e := ic[lang][w]
+	e.tf += weight
+	e.weight = max(e.weight, weight)
+	e.sources |= source
+	ic[lang][w] = e
 }
 
-func (wc wordCollection) merge(add wordCollection) {
-	for k, v := range add {
-		if wc[k] == nil {
-			wc[k] = make(map[word]frequency, len(v))
+func newStats() *stats {
+	return &stats{
+		words:  make(statsCollection),
+		dwords: make(statsCollection),
+	}
+}
+
+// add добавляет к статистике слова одного товара или категории, ограничивая их вклад в tf значением limit.
+// limit == 0 - без ограничения.
+func (s *stats) add(item *data, limit frequency) {
+	s.words.add(item.words, limit)
+	s.dwords.add(item.dwords, limit)
+}
+
+func (sc statsCollection) add(item itemCollection, limit frequency) {
+	for lang, words := range item {
+		if sc[lang] == nil {
+			sc[lang] = make(map[word]wordStats, len(words))
 		}
-		for kk, vv := range v {
-			wc[k][kk] = wc[k][kk] + vv
+		for w, e := range words {
+			tf := e.tf
+			if limit > 0 && tf > limit {
+				tf = limit
+			}
+
+			st := sc[lang][w]
+			st.tf += tf
+			st.df += e.weight
+			st.docs++
+			st.sources |= e.sources
+			sc[lang][w] = st
 		}
 	}
 }
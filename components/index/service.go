@@ -6,6 +6,7 @@ import (
 	"io"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"unicode/utf8"
 
 	"github.com/pkg/errors"
@@ -17,6 +18,7 @@ import (
 
 type langDetector interface {
 	LangByWord(word string) string
+	LangByBytes(word []byte) string
 	ParseWordPair(pair []string) (string, string, string)
 }
 
@@ -36,19 +38,29 @@ type langDetector interface {
 // зависит только от индексов, строится относительно быстро, и его можно создавать "на лету".
 //
 // Таким образом памяти будет использовано ровно столько, сколько нужно.
-type DataStore interface {
+//
+// Сервису для работы достаточно ReadOnlyStore: под, обслуживающий запросы, ничего в хранилище не пишет.
+// Писать может только билдер, ему нужен DataStore.
+type ReadOnlyStore interface {
 	DataReader(key string) (io.ReadCloser, error)
 	IsExist(key string) (bool, error)
+}
+
+type DataStore interface {
+	ReadOnlyStore
 	Save(key string, content io.Reader) error
 }
 
 type Service struct {
 	logger *logrus.Entry
 	langs  langDetector
-	mu     sync.RWMutex
+	// mu упорядочивает только публикацию снимков, читатели его не берут.
+	mu sync.Mutex
 
-	store DataStore
-	index wordCollection
+	store ReadOnlyStore
+	// index - текущий снимок индексов всех языков. Опубликованный снимок не меняется:
+	// SetLangIndex собирает новый и подменяет указатель, а читатели работают с тем снимком, который успели получить.
+	index atomic.Pointer[wordCollection]
 
 	opt *options.Options
 }
@@ -56,7 +68,7 @@ type Service struct {
 func NewService(
 	opt *options.Options,
 	langs langDetector,
-	store DataStore,
+	store ReadOnlyStore,
 	lgr *logrus.Entry,
 ) (*Service, error) {
 	if len(opt.Langs) == 0 {
@@ -68,7 +80,6 @@ func NewService(
 		langs:  langs,
 
 		store: store,
-		index: make(wordCollection),
 
 		opt: opt,
 	}
@@ -81,48 +92,90 @@ func NewService(
 }
 
 // Weight рабочий метод индекса, используемый спеллером.
+// Блокировок не берет: на один трудный запрос приходятся сотни тысяч вызовов,
+// и общий счетчик читателей RWMutex под нагрузкой становится узким местом.
 func (s *Service) Weight(w string) uint32 {
-	s.mu.RLock()
-	defer s.mu.RUnlock()
+	idx, weight, found := s.langIndex(s.snapshot(), s.langs.LangByWord(w))
+	if !found {
+		return weight
+	}
+
+	return idx[w]
+}
+
+// WeightBytes - то же, что Weight, для слова в виде байтов. Не выделяет память:
+// спеллер проверяет по индексу кандидатов прямо в буфере генератора.
+func (s *Service) WeightBytes(w []byte) uint32 {
+	idx, weight, found := s.langIndex(s.snapshot(), s.langs.LangByBytes(w))
+	if !found {
+		return weight
+	}
+
+	// Преобразование в строку прямо в индексе map компилятор выполняет без копирования.
+	return idx[string(w)]
+}
 
-	lang := s.langs.LangByWord(w)
+// snapshot отдает текущий снимок индексов. До первой публикации он пуст.
+func (s *Service) snapshot() wordCollection {
+	if p := s.index.Load(); p != nil {
+		return *p
+	}
+
+	return nil
+}
+
+// publish делает коллекцию текущим снимком. После этого менять ее нельзя.
+func (s *Service) publish(wc wordCollection) {
+	s.index.Store(&wc)
+}
+
+// langIndex отдает индекс языка lang из снимка wc. Если искать в индексе не нужно (число, язык не распознан),
+// found == false, а weight - вес слова.
+func (s *Service) langIndex(wc wordCollection, lang string) (idx map[string]uint32, weight uint32, found bool) {
 	if lang == unknownLangCode {
 		s.logger.Debug("getting weight: language not detected")
 
-		return 0
+		return nil, 0, false
 	}
 
 	if lang == numLangCode {
-		return numWeight
+		return nil, numWeight, false
 	}
 
-	var (
-		idx   map[string]uint32
-		found bool
-	)
-	if idx, found = s.index[lang]; !found {
+	if idx, found = wc[lang]; !found {
 		s.logger.Error("getting weight: no index for such language: " + lang)
 
-		return 0
+		return nil, 0, false
 	}
 
-	return idx[w]
+	return idx, 0, true
 }
 
 // SetLangIndex записывает новые данные в индекс переданного языка.
 // Используется для загрузки индекса после запуска приложения.
+// Индексы остальных языков переходят в новый снимок без копирования, idx после вызова менять нельзя.
 func (s *Service) SetLangIndex(lang string, idx map[word]frequency) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	s.index[lang] = idx
+	current := s.snapshot()
+	next := make(wordCollection, len(current)+1)
+	for l, li := range current {
+		next[l] = li
+	}
+	next[lang] = idx
+
+	s.publish(next)
 }
 
 // DeletesEstimated - используется для расчета bitmap bloom-фильтра.
-func (s *Service) DeletesEstimated() (uint, error) {
+// Если заданы langs, считает только по словам этих языков.
+func (s *Service) DeletesEstimated(langs ...string) (uint, error) {
+	wc := s.snapshot()
+
 	var res uint
-	for lang := range s.index {
-		for w := range s.index[lang] {
+	for _, lang := range selectLangs(wc, langs) {
+		for w := range wc[lang] {
 			wrl := runeLen(w)
 			if wrl < 2 {
 				continue
@@ -137,13 +190,61 @@ func (s *Service) DeletesEstimated() (uint, error) {
 	return res, nil
 }
 
+// WordsCount отдает количество слов в индексе каждого языка.
+func (s *Service) WordsCount() map[string]int {
+	wc := s.snapshot()
+
+	res := make(map[string]int, len(wc))
+	for lang, idx := range wc {
+		res[lang] = len(idx)
+	}
+
+	return res
+}
+
+// WordList отдает все слова индекса (или только слова языков langs, если они заданы) одним срезом.
+// В отличие от Words, его удобно делить между несколькими горутинами.
+func (s *Service) WordList(langs ...string) []string {
+	wc := s.snapshot()
+	langs = selectLangs(wc, langs)
+
+	var total int
+	for _, lang := range langs {
+		total += len(wc[lang])
+	}
+
+	res := make([]string, 0, total)
+	for _, lang := range langs {
+		for w := range wc[lang] {
+			res = append(res, w)
+		}
+	}
+
+	return res
+}
+
+// selectLangs отдает langs, а если они не заданы - все языки снимка wc.
+func selectLangs(wc wordCollection, langs []string) []string {
+	if len(langs) > 0 {
+		return langs
+	}
+
+	res := make([]string, 0, len(wc))
+	for lang := range wc {
+		res = append(res, lang)
+	}
+
+	return res
+}
+
 // Words - используется для расчета bitmap bloom-фильтра.
 func (s *Service) Words() (<-chan string, error) {
 	res := make(chan string)
+	wc := s.snapshot()
 
 	go func() {
-		for lang := range s.index {
-			for w := range s.index[lang] {
+		for lang := range wc {
+			for w := range wc[lang] {
 				res <- w
 			}
 		}
@@ -161,19 +262,25 @@ func runeLen(w string) uint {
 	return uint(len([]rune(w)))
 }
 
-// load загружает индексы из store в конструкторе индекса.
+// load загружает индексы из store в конструкторе индекса и публикует их одним снимком.
 func (s *Service) load() error {
+	wc := make(wordCollection, len(s.opt.Langs))
 	for _, lang := range s.opt.Langs {
-		err := s.parseData(lang)
+		err := s.parseData(wc, lang)
 		if err != nil {
 			return err
 		}
 	}
 
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.publish(wc)
+
 	return nil
 }
 
-func (s *Service) parseData(l string) error {
+func (s *Service) parseData(wc wordCollection, l string) error {
 	dh, err := s.store.DataReader(langCodeIndexKey(l))
 	if err != nil {
 		return err
@@ -187,7 +294,7 @@ func (s *Service) parseData(l string) error {
 	lineScan.Split(bufio.ScanLines)
 
 	var idx map[string]uint32
-	if idx = s.index[l]; idx == nil {
+	if idx = wc[l]; idx == nil {
 		idx = make(map[string]uint32)
 	}
 
@@ -202,8 +309,12 @@ func (s *Service) parseData(l string) error {
 		idx[w] = idx[w] + f
 	}
 
+	if err = lineScan.Err(); err != nil {
+		return errors.Wrap(err, "reading index: "+l)
+	}
+
 	if len(idx) > 0 {
-		s.index[l] = idx
+		wc[l] = idx
 	}
 
 	return nil
@@ -34,22 +34,20 @@ func TestService_All(t *testing.T) {
 		},
 		langs: langdetect.New(),
 		store: store,
-		index: make(wordCollection),
-		mu:    sync.RWMutex{},
 	}
 
 	err := s.load()
 	require.NoError(t, err)
 
 	t.Run("CheckLoad", func(t *testing.T) {
-		idx, ok := s.index[ruLangCode]
+		idx, ok := s.snapshot()[ruLangCode]
 		require.True(t, ok)
 		require.Len(t, idx, 30)
 		require.Equal(t, uint32(1703405), idx["цвет"])
 		require.Equal(t, uint32(528614), idx["рост"])
 		require.Equal(t, uint32(245425), idx["рост цвет"])
 
-		idx, ok = s.index[enLangCode]
+		idx, ok = s.snapshot()[enLangCode]
 		require.True(t, ok)
 		require.Len(t, idx, 30)
 		require.Equal(t, uint32(159700), idx["in"])
@@ -62,6 +60,16 @@ func TestService_All(t *testing.T) {
 		require.Equal(t, uint32(1703405), s.Weight("цвет"))
 		require.Equal(t, uint32(245425), s.Weight("рост цвет"))
 	})
+	t.Run("CheckWeightBytes", func(t *testing.T) {
+		for _, w := range []string{"german edition", "edition", "цвет", "рост цвет", "нет такого", "12.5"} {
+			require.Equal(t, s.Weight(w), s.WeightBytes([]byte(w)), w)
+		}
+
+		b := []byte("рост цвет")
+		require.Zero(t, testing.AllocsPerRun(100, func() {
+			_ = s.WeightBytes(b)
+		}))
+	})
 	t.Run("CheckWords", func(t *testing.T) {
 		wChan, err := s.Words()
 		require.NoError(t, err)
@@ -86,6 +94,108 @@ func TestService_All(t *testing.T) {
 	})
 }
 
+func TestService_SetLangIndex(t *testing.T) {
+	s := &Service{langs: langdetect.New()}
+	s.SetLangIndex(ruLangCode, map[word]frequency{"цвет": 10})
+
+	before := s.snapshot()
+	s.SetLangIndex(enLangCode, map[word]frequency{"color": 20})
+	s.SetLangIndex(ruLangCode, map[word]frequency{"рост": 30})
+
+	// Опубликованный снимок не меняется.
+	require.Len(t, before, 1)
+	require.Equal(t, frequency(10), before[ruLangCode]["цвет"])
+
+	require.Zero(t, s.Weight("цвет"))
+	require.Equal(t, uint32(30), s.Weight("рост"))
+	require.Equal(t, uint32(20), s.Weight("color"))
+	require.Equal(t, map[string]int{ruLangCode: 1, enLangCode: 1}, s.WordsCount())
+
+	t.Run("ConcurrentReaders", func(t *testing.T) {
+		wg := sync.WaitGroup{}
+		wg.Add(4)
+
+		for i := 0; i < 4; i++ {
+			go func() {
+				defer wg.Done()
+
+				for j := 0; j < 1000; j++ {
+					// Любой снимок содержит "color": его не подменяет ни один писатель.
+					if weight := s.Weight("color"); weight != 20 {
+						t.Errorf("color weight: %d", weight)
+
+						return
+					}
+					_ = s.WeightBytes([]byte("рост"))
+				}
+			}()
+		}
+
+		for i := 0; i < 100; i++ {
+			s.SetLangIndex(ruLangCode, map[word]frequency{"рост": frequency(i + 1)})
+		}
+
+		wg.Wait()
+	})
+}
+
+// rwMutexIndex - чтение индекса под общим RWMutex, как было до перехода на снимки. Нужен только для сравнения в бенчмарке.
+type rwMutexIndex struct {
+	mu    sync.RWMutex
+	langs langDetector
+	index wordCollection
+}
+
+func (s *rwMutexIndex) Weight(w string) uint32 {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	return s.index[s.langs.LangByWord(w)][w]
+}
+
+// BenchmarkService_Weight сравнивает параллельное чтение индекса через снимок и под RWMutex.
+//
+//	go test -run xxx -bench Weight -cpu 1,4,16 ./components/index
+func BenchmarkService_Weight(b *testing.B) {
+	alphabet := []rune("абвгдежзиклмнопрстуфхцчшщыэюя")
+	idx := make(map[word]frequency, 10000)
+	words := make([]string, 0, 10000)
+	for i := 0; i < 10000; i++ {
+		w := []rune("слово")
+		for n := i; n > 0; n /= len(alphabet) {
+			w = append(w, alphabet[n%len(alphabet)])
+		}
+
+		idx[string(w)] = frequency(i + 1)
+		words = append(words, string(w))
+	}
+
+	snapshot := &Service{langs: langdetect.New()}
+	snapshot.SetLangIndex(ruLangCode, idx)
+
+	locked := &rwMutexIndex{langs: langdetect.New(), index: wordCollection{ruLangCode: idx}}
+
+	for _, bc := range []struct {
+		name   string
+		weight func(w string) uint32
+	}{
+		{name: "read=snapshot", weight: snapshot.Weight},
+		{name: "read=rwmutex", weight: locked.Weight},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.RunParallel(func(pb *testing.PB) {
+				i := 0
+				for pb.Next() {
+					if bc.weight(words[i%len(words)]) == 0 {
+						b.Error("word not found")
+					}
+					i++
+				}
+			})
+		})
+	}
+}
+
 func goldenRuData() io.ReadCloser {
 	return io.NopCloser(
 		bytes.NewBufferString(`и	2959334
@@ -3,6 +3,7 @@ package langdetect
 import (
 	"strings"
 	"unicode"
+	"unicode/utf8"
 )
 
 const (
@@ -19,70 +20,70 @@ func New() *Component {
 }
 
 func (c *Component) LangByWord(w string) string {
-	runeWord := []rune(w)
-	switch {
-	case isNumber(runeWord):
-		return numLangCode
-	case isRus(runeWord):
-		return ruLangCode
-	case isEn(runeWord):
-		return enLangCode
+	var st runeStats
+	for _, r := range w {
+		st.add(r)
 	}
 
-	return unknownLangCode
+	return st.lang()
 }
 
-func isNumber(rw []rune) bool {
-	var pointFound bool
-	for _, r := range rw {
-		if r == '.' || r == ',' {
-			if !pointFound {
-				pointFound = true
+// LangByBytes - то же, что LangByWord, для слова в виде байтов UTF-8. Не выделяет память.
+func (c *Component) LangByBytes(w []byte) string {
+	var st runeStats
+	for len(w) > 0 {
+		r, size := utf8.DecodeRune(w)
+		st.add(r)
+		w = w[size:]
+	}
+
+	return st.lang()
+}
 
-				continue
-			}
+// runeStats - счетчики рун слова, по которым определяется его язык.
+type runeStats struct {
+	total uint
+	// points - десятичные разделители, notNumeric - руны, которые не цифры и не разделители.
+	points, notNumeric uint
+	ru, en             uint
+}
 
-			return false
-		}
+func (st *runeStats) add(r rune) {
+	st.total++
 
-		if !unicode.IsDigit(r) {
-			return false
-		}
+	switch {
+	case r == '.' || r == ',':
+		st.points++
+	case !unicode.IsDigit(r):
+		st.notNumeric++
 	}
 
-	return true
+	if unicode.Is(unicode.Cyrillic, r) || r == '-' {
+		st.ru++
+	}
+
+	if r <= unicode.MaxASCII && (unicode.IsLetter(r) || r == '-' || r == '`' || r == '\u0027') {
+		st.en++
+	}
 }
 
-func isRus(rw []rune) bool {
-	var (
-		legal   uint
-		illegal uint
-	)
-
-	for _, r := range rw {
-		if unicode.Is(unicode.Cyrillic, r) || r == '-' {
-			legal++
-		} else {
-			illegal++
-		}
+func (st *runeStats) lang() string {
+	switch {
+	case st.notNumeric == 0 && st.points <= 1:
+		return numLangCode
+	case isLegal(st.ru, st.total):
+		return ruLangCode
+	case isLegal(st.en, st.total):
+		return enLangCode
 	}
 
-	return legal > illegal && illegal <= 2
+	return unknownLangCode
 }
 
-func isEn(rw []rune) bool {
-	var (
-		legal   uint
-		illegal uint
-	)
-
-	for _, r := range rw {
-		if r <= unicode.MaxASCII && (unicode.IsLetter(r) || r == '-' || r == '`' || r == '\u0027') {
-			legal++
-		} else {
-			illegal++
-		}
-	}
+// isLegal сообщает, что слово из total рун, legal из которых относятся к алфавиту языка, написано на этом языке:
+// рун алфавита больше, чем прочих, и прочих не больше двух.
+func isLegal(legal, total uint) bool {
+	illegal := total - legal
 
 	return legal > illegal && illegal <= 2
 }
@@ -55,6 +55,19 @@ func TestComponent_LangByWord(t *testing.T) {
 	})
 }
 
+func TestComponent_LangByBytes(t *testing.T) {
+	c := New()
+
+	for _, w := range []string{"", "12056", "12056,223", "12056.22.3", "военный", "вfенк1", "thпру", "игрушка для", "motorola", "д1я", "1thф"} {
+		require.Equal(t, c.LangByWord(w), c.LangByBytes([]byte(w)), w)
+	}
+
+	b := []byte("организация")
+	require.Zero(t, testing.AllocsPerRun(100, func() {
+		_ = c.LangByBytes(b)
+	}))
+}
+
 func TestComponent_ParseWordPair(t *testing.T) {
 	c := New()
 
@@ -0,0 +1,186 @@
+// Package manifest описывает состав одного поколения (generation) артефактов wordspell:
+// языковых индексов, индекса трейдмарок и bloom-фильтра.
+//
+// Builder пишет манифест последним, когда все артефакты уже сохранены,
+// а сервис перед началом работы сверяет с ним поколение, контрольные суммы и размеры артефактов.
+// Так под не стартует с новым индексом и устаревшим bloom-фильтром.
+package manifest
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"sort"
+	"time"
+
+	"github.com/pkg/errors"
+)
+
+// StoreKey ключ манифеста в DataStore.
+const StoreKey = "manifest.json"
+
+const generationTimeLayout = "20060102-150405"
+
+// ReadOnlyStore - хранилище, из которого сервис читает манифест и артефакты.
+type ReadOnlyStore interface {
+	DataReader(key string) (io.ReadCloser, error)
+	IsExist(key string) (bool, error)
+}
+
+// DataStore - хранилище, в которое билдер пишет артефакты и манифест.
+type DataStore interface {
+	ReadOnlyStore
+	Save(key string, content io.Reader) error
+}
+
+// Artifact описывает один сохраненный артефакт.
+// Sources заполняется для производных артефактов (bloom-фильтр строится по языковым индексам):
+// это контрольные суммы исходных артефактов на момент построения производного.
+type Artifact struct {
+	Key     string            `json:"key"`
+	SHA256  string            `json:"sha256"`
+	Size    int64             `json:"size"`
+	Sources map[string]string `json:"sources,omitempty"`
+}
+
+// BuildOptions настройки, с которыми строилось поколение.
+type BuildOptions struct {
+	Langs             []string          `json:"langs"`
+	FalsePositiveRate float64           `json:"false_positive_rate"`
+	MemoryBudget      uint64            `json:"memory_budget,omitempty"`
+	Filter            string            `json:"filter,omitempty"`
+	Thresholds        map[string]uint32 `json:"thresholds"`
+	// Weight - формула веса слов в индексах ("tf", "df", "df_sources"), MinItems - сколько разных товаров и категорий
+	// должно содержать слово, чтобы попасть в индекс.
+	Weight   string `json:"weight,omitempty"`
+	MinItems uint32 `json:"min_items,omitempty"`
+	// Distances - максимальные расстояния редактирования по длине слова ("1:0,24:2"), с которыми построены фильтры удалений.
+	Distances string `json:"distances,omitempty"`
+	// Compression - алгоритм сжатия артефактов в хранилище, контрольные суммы считаются по несжатым данным.
+	Compression string `json:"compression,omitempty"`
+}
+
+// FilterStats - параметры построенного фильтра удалений.
+// EstimatedDeletes - оценка сверху (без учета повторов), по которой фильтр строился раньше,
+// DistinctDeletes - оценка числа уникальных удалений (HyperLogLog), по ней фильтр строится сейчас.
+// MeasuredFalsePositiveRate - доля ложноположительных ответов на случайные строки, которых в фильтре нет.
+type FilterStats struct {
+	Type                      string  `json:"type"`
+	Bits                      uint64  `json:"bits"`
+	MemoryBudget              uint64  `json:"memory_budget,omitempty"`
+	EstimatedDeletes          uint64  `json:"estimated_deletes"`
+	DistinctDeletes           uint64  `json:"distinct_deletes"`
+	FalsePositiveRate         float64 `json:"false_positive_rate"`
+	MeasuredFalsePositiveRate float64 `json:"measured_false_positive_rate"`
+}
+
+type Manifest struct {
+	Generation string                  `json:"generation"`
+	CreatedAt  time.Time               `json:"created_at"`
+	Options    BuildOptions            `json:"options"`
+	Artifacts  map[string]*Artifact    `json:"artifacts"`
+	Filters    map[string]*FilterStats `json:"filters,omitempty"`
+	Words      map[string]int          `json:"words"`
+}
+
+// NewGeneration отдает идентификатор нового поколения.
+// Идентификаторы сортируются лексикографически в порядке создания.
+func NewGeneration() string {
+	suffix := make([]byte, 3)
+	_, _ = rand.Read(suffix)
+
+	return time.Now().UTC().Format(generationTimeLayout) + "-" + hex.EncodeToString(suffix)
+}
+
+// Load читает манифест из DataStore.
+func Load(store ReadOnlyStore) (*Manifest, error) {
+	exists, err := store.IsExist(StoreKey)
+	if err != nil {
+		return nil, err
+	}
+	if !exists {
+		return nil, errors.New("no manifest found")
+	}
+
+	dr, err := store.DataReader(StoreKey)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = dr.Close()
+	}()
+
+	res := &Manifest{}
+	if err = json.NewDecoder(dr).Decode(res); err != nil {
+		return nil, errors.Wrap(err, "decoding manifest")
+	}
+
+	return res, nil
+}
+
+// Save записывает манифест в DataStore.
+func (m *Manifest) Save(store DataStore) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return store.Save(StoreKey, bytes.NewReader(data))
+}
+
+// Keys отдает отсортированный список ключей артефактов.
+func (m *Manifest) Keys() []string {
+	res := make([]string, 0, len(m.Artifacts))
+	for k := range m.Artifacts {
+		res = append(res, k)
+	}
+	sort.Strings(res)
+
+	return res
+}
+
+// Verify проверяет согласованность манифеста:
+// поколение совпадает с ожидаемым (если оно задано),
+// а все производные артефакты построены по тем же исходным артефактам, что перечислены в манифесте.
+func (m *Manifest) Verify(generation string) error {
+	if m.Generation == "" {
+		return errors.New("manifest has no generation")
+	}
+
+	if generation != "" && generation != m.Generation {
+		return errors.Errorf("manifest generation mismatch: expected %s, found %s", generation, m.Generation)
+	}
+
+	for _, k := range m.Keys() {
+		art := m.Artifacts[k]
+		for src, sum := range art.Sources {
+			srcArt, found := m.Artifacts[src]
+			if !found {
+				return errors.Errorf("artifact %s: source %s not found in manifest", k, src)
+			}
+			if srcArt.SHA256 != sum {
+				return errors.Errorf("artifact %s was built from another version of %s", k, src)
+			}
+		}
+	}
+
+	return nil
+}
+
+// RequireDerived проверяет, что артефакт key построен в том числе по каждому из sources.
+func (m *Manifest) RequireDerived(key string, sources ...string) error {
+	art, found := m.Artifacts[key]
+	if !found {
+		return errors.Errorf("artifact %s not found in manifest", key)
+	}
+
+	for _, src := range sources {
+		if _, found = art.Sources[src]; !found {
+			return errors.Errorf("artifact %s was not built from %s", key, src)
+		}
+	}
+
+	return nil
+}
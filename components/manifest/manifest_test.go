@@ -0,0 +1,101 @@
+package manifest
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cannonflesh/wordspell/repo/file"
+)
+
+func goldenGeneration(t *testing.T) (*file.Store, *Manifest) {
+	store := file.New(&file.Options{DataDir: t.TempDir()})
+	rec := NewRecorder()
+	recStore := rec.Wrap(store)
+
+	require.NoError(t, recStore.Save("ru.index", bytes.NewBufferString("цвет\t10\n")))
+	require.NoError(t, recStore.Save("en.index", bytes.NewBufferString("the\t10\n")))
+	require.NoError(t, recStore.Save("bloom.dat", bytes.NewBufferString("bloom")))
+	rec.Derive("bloom.dat", "ru.index", "en.index")
+	rec.Filter("bloom.dat", FilterStats{Type: "bloom", Bits: 40, DistinctDeletes: 4, MeasuredFalsePositiveRate: 0.01})
+
+	m := rec.Manifest(NewGeneration(), BuildOptions{Langs: []string{"ru", "en"}}, map[string]int{"ru": 1, "en": 1})
+	require.NoError(t, m.Save(store))
+
+	return store, m
+}
+
+func TestManifest_SaveLoad(t *testing.T) {
+	store, m := goldenGeneration(t)
+
+	loaded, err := Load(store)
+	require.NoError(t, err)
+	require.Equal(t, m.Generation, loaded.Generation)
+	require.Equal(t, []string{"bloom.dat", "en.index", "ru.index"}, loaded.Keys())
+	require.Equal(t, int64(5), loaded.Artifacts["bloom.dat"].Size)
+	require.Equal(t, map[string]int{"ru": 1, "en": 1}, loaded.Words)
+	require.Equal(t, m.Filters, loaded.Filters)
+	require.Equal(t, uint64(4), loaded.Filters["bloom.dat"].DistinctDeletes)
+
+	t.Run("NoManifest", func(t *testing.T) {
+		_, err := Load(file.New(&file.Options{DataDir: t.TempDir()}))
+		require.EqualError(t, err, "no manifest found")
+	})
+}
+
+func TestManifest_Verify(t *testing.T) {
+	_, m := goldenGeneration(t)
+
+	require.NoError(t, m.Verify(""))
+	require.NoError(t, m.Verify(m.Generation))
+	require.NoError(t, m.RequireDerived("bloom.dat", "ru.index", "en.index"))
+
+	t.Run("GenerationMismatch", func(t *testing.T) {
+		require.ErrorContains(t, m.Verify("19700101-000000-000000"), "manifest generation mismatch")
+	})
+	t.Run("NotDerived", func(t *testing.T) {
+		require.EqualError(t, m.RequireDerived("bloom.dat", "de.index"), "artifact bloom.dat was not built from de.index")
+	})
+	t.Run("StaleSource", func(t *testing.T) {
+		m.Artifacts["ru.index"].SHA256 = "stale"
+		require.EqualError(t, m.Verify(""), "artifact bloom.dat was built from another version of ru.index")
+	})
+}
+
+func TestManifest_Verified(t *testing.T) {
+	store, m := goldenGeneration(t)
+	vStore := m.Verified(store)
+
+	t.Run("Intact", func(t *testing.T) {
+		dr, err := vStore.DataReader("ru.index")
+		require.NoError(t, err)
+		data, err := io.ReadAll(dr)
+		require.NoError(t, err)
+		require.Equal(t, "цвет\t10\n", string(data))
+		require.NoError(t, dr.Close())
+	})
+	t.Run("Corrupted", func(t *testing.T) {
+		require.NoError(t, store.Save("bloom.dat", bytes.NewBufferString("blOom")))
+
+		dr, err := vStore.DataReader("bloom.dat")
+		require.NoError(t, err)
+		_, err = io.ReadAll(dr)
+		require.EqualError(t, err, "artifact bloom.dat: checksum mismatch")
+		require.NoError(t, dr.Close())
+	})
+	t.Run("Truncated", func(t *testing.T) {
+		require.NoError(t, store.Save("en.index", bytes.NewBufferString("the")))
+
+		dr, err := vStore.DataReader("en.index")
+		require.NoError(t, err)
+		_, err = io.ReadAll(dr)
+		require.EqualError(t, err, "artifact en.index: size mismatch, expected 7, read 3")
+		require.NoError(t, dr.Close())
+	})
+	t.Run("NotInManifest", func(t *testing.T) {
+		_, err := vStore.DataReader("trademark.index")
+		require.ErrorContains(t, err, "artifact trademark.index not found in manifest")
+	})
+}
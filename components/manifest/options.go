@@ -0,0 +1,8 @@
+package manifest
+
+type Options struct {
+	// Generation, если задано, - поколение, с которым обязан работать сервис.
+	Generation string
+	// SkipVerify отключает проверку манифеста при старте сервиса.
+	SkipVerify bool
+}
@@ -0,0 +1,147 @@
+package manifest
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"hash"
+	"io"
+	"sync"
+	"time"
+)
+
+// Recorder собирает сведения об артефактах, сохраненных в процессе построения поколения.
+// Хранилища, через которые сохраняются артефакты, оборачиваются методом Wrap.
+type Recorder struct {
+	artifacts map[string]*Artifact
+	filters   map[string]*FilterStats
+
+	mu sync.Mutex
+}
+
+func NewRecorder() *Recorder {
+	return &Recorder{
+		artifacts: make(map[string]*Artifact),
+		filters:   make(map[string]*FilterStats),
+	}
+}
+
+// Wrap отдает DataStore, который при сохранении считает контрольную сумму и размер артефакта.
+func (r *Recorder) Wrap(store DataStore) DataStore {
+	return &recordingStore{
+		DataStore: store,
+		recorder:  r,
+	}
+}
+
+// Derive отмечает, что артефакт key построен по артефактам sources,
+// и запоминает их текущие контрольные суммы.
+func (r *Recorder) Derive(key string, sources ...string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	art, found := r.artifacts[key]
+	if !found {
+		return
+	}
+
+	art.Sources = make(map[string]string, len(sources))
+	for _, src := range sources {
+		if srcArt, ok := r.artifacts[src]; ok {
+			art.Sources[src] = srcArt.SHA256
+		}
+	}
+}
+
+// Filter запоминает параметры фильтра удалений, сохраненного под ключом key.
+func (r *Recorder) Filter(key string, stats FilterStats) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.filters[key] = &stats
+}
+
+// Manifest отдает манифест по всем записанным артефактам.
+func (r *Recorder) Manifest(generation string, opt BuildOptions, words map[string]int) *Manifest {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	arts := make(map[string]*Artifact, len(r.artifacts))
+	for k, v := range r.artifacts {
+		art := *v
+		arts[k] = &art
+	}
+
+	var filters map[string]*FilterStats
+	if len(r.filters) > 0 {
+		filters = make(map[string]*FilterStats, len(r.filters))
+		for k, v := range r.filters {
+			fs := *v
+			filters[k] = &fs
+		}
+	}
+
+	return &Manifest{
+		Generation: generation,
+		CreatedAt:  time.Now().UTC(),
+		Options:    opt,
+		Artifacts:  arts,
+		Filters:    filters,
+		Words:      words,
+	}
+}
+
+func (r *Recorder) record(art *Artifact) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.artifacts[art.Key] = art
+}
+
+type recordingStore struct {
+	DataStore
+	recorder *Recorder
+}
+
+func (s *recordingStore) Save(key string, content io.Reader) error {
+	hr := newHashingReader(content)
+
+	if err := s.DataStore.Save(key, hr); err != nil {
+		return err
+	}
+
+	s.recorder.record(&Artifact{
+		Key:    key,
+		SHA256: hr.sum(),
+		Size:   hr.size,
+	})
+
+	return nil
+}
+
+// hashingReader считает контрольную сумму и размер прочитанных через него данных.
+type hashingReader struct {
+	r    io.Reader
+	h    hash.Hash
+	size int64
+}
+
+func newHashingReader(r io.Reader) *hashingReader {
+	return &hashingReader{
+		r: r,
+		h: sha256.New(),
+	}
+}
+
+func (hr *hashingReader) Read(p []byte) (int, error) {
+	n, err := hr.r.Read(p)
+	if n > 0 {
+		_, _ = hr.h.Write(p[:n])
+		hr.size += int64(n)
+	}
+
+	return n, err
+}
+
+func (hr *hashingReader) sum() string {
+	return hex.EncodeToString(hr.h.Sum(nil))
+}
@@ -0,0 +1,79 @@
+package manifest
+
+import (
+	"io"
+
+	"github.com/pkg/errors"
+)
+
+// Verified отдает ReadOnlyStore, который сверяет читаемые артефакты с манифестом.
+// Контрольная сумма и размер проверяются по достижении конца данных:
+// вместо io.EOF читающая сторона получит ошибку, если артефакт поврежден или подменен.
+// Артефакты, не перечисленные в манифесте, прочитать через такой ReadOnlyStore нельзя.
+func (m *Manifest) Verified(store ReadOnlyStore) ReadOnlyStore {
+	return &verifiedStore{
+		ReadOnlyStore: store,
+		manifest:      m,
+	}
+}
+
+type verifiedStore struct {
+	ReadOnlyStore
+	manifest *Manifest
+}
+
+func (s *verifiedStore) DataReader(key string) (io.ReadCloser, error) {
+	art, found := s.manifest.Artifacts[key]
+	if !found {
+		return nil, errors.Errorf("artifact %s not found in manifest %s", key, s.manifest.Generation)
+	}
+
+	rc, err := s.ReadOnlyStore.DataReader(key)
+	if err != nil {
+		return nil, err
+	}
+
+	return &verifyingReader{
+		hashingReader: newHashingReader(rc),
+		closer:        rc,
+		artifact:      art,
+	}, nil
+}
+
+type verifyingReader struct {
+	*hashingReader
+	closer   io.Closer
+	artifact *Artifact
+}
+
+func (vr *verifyingReader) Read(p []byte) (int, error) {
+	n, err := vr.hashingReader.Read(p)
+	if errors.Is(err, io.EOF) {
+		if verr := vr.check(); verr != nil {
+			return n, verr
+		}
+	}
+
+	return n, err
+}
+
+func (vr *verifyingReader) Close() error {
+	return vr.closer.Close()
+}
+
+func (vr *verifyingReader) check() error {
+	if vr.size != vr.artifact.Size {
+		return errors.Errorf(
+			"artifact %s: size mismatch, expected %d, read %d",
+			vr.artifact.Key,
+			vr.artifact.Size,
+			vr.size,
+		)
+	}
+
+	if sum := vr.sum(); sum != vr.artifact.SHA256 {
+		return errors.Errorf("artifact %s: checksum mismatch", vr.artifact.Key)
+	}
+
+	return nil
+}
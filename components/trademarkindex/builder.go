@@ -1,13 +1,15 @@
 package trademarkindex
 
 import (
-	"bytes"
+	"io"
 	"strings"
 	"time"
 
+	"github.com/pkg/errors"
 	"github.com/sirupsen/logrus"
 
 	"github.com/cannonflesh/wordspell/domain"
+	"github.com/cannonflesh/wordspell/internal/stream"
 )
 
 const sourceBatchLen = 5000
@@ -37,31 +39,46 @@ func NewBuilder(source DataSource, store DataStore, l *logrus.Entry) *Builder {
 	}
 }
 
+// LoadIndexData читает трейдмарки из источника порциями и сразу же пишет их в хранилище,
+// так что в памяти одновременно находится не более одной порции.
 func (b *Builder) LoadIndexData() error {
-	var res []string
-
 	b.logger.Info("[TRADEMARK INDEX BUILD] start building")
 	startBuild := time.Now()
-	for start, read := 0, sourceBatchLen; read == sourceBatchLen; {
-		names, err := b.source.TradeMarkNames(start, sourceBatchLen)
-		if err != nil {
-			return err
-		}
 
-		cleanupNames(names)
-		res = append(res, names...)
+	err := stream.Save(b.store, storeKey, func(w io.Writer) error {
+		total := 0
+		for start, read := 0, sourceBatchLen; read == sourceBatchLen; {
+			names, err := b.source.TradeMarkNames(start, sourceBatchLen)
+			if err != nil {
+				return err
+			}
+
+			cleanupNames(names)
+			for _, n := range names {
+				if total > 0 {
+					if _, err = io.WriteString(w, "\n"); err != nil {
+						return errors.WithStack(err)
+					}
+				}
+				if _, err = io.WriteString(w, n); err != nil {
+					return errors.WithStack(err)
+				}
+				total++
+			}
+
+			read = len(names)
+			start += sourceBatchLen
+		}
+		b.logger.Infof("[TRADEMARK INDEX BUILD] trademarks total: %d, built in %v", total, time.Since(startBuild))
 
-		read = len(names)
-		start += sourceBatchLen
+		return nil
+	})
+	if err != nil {
+		return err
 	}
-	b.logger.Infof("[TRADEMARK INDEX BUILD] trademarks total: %d, built in %v", len(res), time.Since(startBuild))
-
-	startSave := time.Now()
-	b.logger.Info("[TRADEMARK INDEX SAVE] start saving")
-	err := b.store.Save(storeKey, bytes.NewBuffer([]byte(strings.Join(res, "\n"))))
-	b.logger.Infof("[TRADEMARK INDEX SAVE] saved in %v", time.Since(startSave))
+	b.logger.Infof("[TRADEMARK INDEX SAVE] saved in %v", time.Since(startBuild))
 
-	return err
+	return nil
 }
 
 func cleanupNames(names []string) {
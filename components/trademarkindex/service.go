@@ -28,23 +28,30 @@ import (
 // зависит только от индексов, строится относительно быстро, и его можно создавать "на лету".
 //
 // Таким образом памяти будет использовано ровно столько, сколько нужно.
-type DataStore interface {
+//
+// Сервису для работы достаточно ReadOnlyStore: под, обслуживающий запросы, ничего в хранилище не пишет.
+// Писать может только билдер, ему нужен DataStore.
+type ReadOnlyStore interface {
 	DataReader(key string) (io.ReadCloser, error)
 	IsExist(key string) (bool, error)
+}
+
+type DataStore interface {
+	ReadOnlyStore
 	Save(key string, content io.Reader) error
 }
 
 const storeKey = "trademark.index"
 
 type Service struct {
-	store  DataStore
+	store  ReadOnlyStore
 	index  tradeMarkIndex
 	logger *logrus.Entry
 
 	mu sync.RWMutex
 }
 
-func NewService(store DataStore, lgr *logrus.Entry) (*Service, error) {
+func NewService(store ReadOnlyStore, lgr *logrus.Entry) (*Service, error) {
 	res := &Service{
 		store:  store,
 		logger: lgr.WithField(domain.CategoryFieldName, "component.trademarks_index_service"),
@@ -145,6 +152,10 @@ func (s *Service) loadIndex() (tradeMarkIndex, error) {
 		parseName(line, res)
 	}
 
+	if err = scan.Err(); err != nil {
+		return nil, errors.Wrap(err, "reading trademark index")
+	}
+
 	return res, nil
 }
 
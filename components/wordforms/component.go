@@ -0,0 +1,84 @@
+/* Формы слов.
+ *
+ * Правильное, но редкое слово может не попасть в индекс: билдер отбрасывает слова с частотой ниже порога.
+ * Зато в индексе часто есть другая форма того же слова: "организациями" редко, "организация" и "организации" - часто.
+ * Полноценный морфологический анализ здесь не нужен: достаточно отрезать от слова известное окончание
+ * и поискать в индексе основу с другими окончаниями. Ошибка в основе при этом не маскируется:
+ * "организцаиями" формы в индексе не найдет.
+ */
+
+package wordforms
+
+import (
+	"sort"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cannonflesh/wordspell/domain"
+)
+
+// minStemLen - основа короче не проверяется: у коротких основ слишком много случайных "форм".
+const minStemLen = 3
+
+type Component struct {
+	// endings - окончания по языкам, от длинных к коротким. Пустое окончание - сама основа.
+	endings map[string][]string
+}
+
+func New() *Component {
+	return &Component{
+		endings: map[string][]string{
+			domain.RuLangCode: sortEndings(
+				"", "а", "я", "ы", "и", "у", "ю", "е", "о", "ь", "й",
+				"ой", "ей", "ом", "ем", "ам", "ям", "ах", "ях", "ов", "ев", "ью", "ия", "ие", "ии", "ию", "ье", "ья",
+				"ами", "ями", "ого", "его", "ому", "ему", "ым", "им", "ых", "их", "ый", "ий", "ая", "яя", "ое", "ее",
+				"ые", "ие", "ую", "юю", "ть", "ти", "л", "ла", "ло", "ли", "ет", "ит", "ут", "ют", "ат", "ят",
+				"ешь", "ишь", "ем", "им", "ете", "ите", "ться", "тся", "ция", "ции", "цию", "цией", "циями", "циях",
+			),
+			domain.EnLangCode: sortEndings("", "s", "es", "ed", "d", "ing", "er", "ers", "est", "ly", "'s"),
+		},
+	}
+}
+
+// HasForm проверяет, есть ли в индексе другая форма слова word языка lang: та же основа с другим окончанием.
+// exists проверяет наличие слова в индексе.
+func (c *Component) HasForm(lang, word string, exists func(w string) bool) bool {
+	endings := c.endings[lang]
+
+	for _, ending := range endings {
+		if !strings.HasSuffix(word, ending) {
+			continue
+		}
+
+		stem := word[:len(word)-len(ending)]
+		if utf8.RuneCountInString(stem) < minStemLen {
+			continue
+		}
+
+		for _, other := range endings {
+			if other != ending && exists(stem+other) {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// sortEndings убирает повторы и упорядочивает окончания от длинных к коротким.
+func sortEndings(endings ...string) []string {
+	seen := make(map[string]bool, len(endings))
+	res := make([]string, 0, len(endings))
+	for _, e := range endings {
+		if !seen[e] {
+			seen[e] = true
+			res = append(res, e)
+		}
+	}
+
+	sort.SliceStable(res, func(i, j int) bool {
+		return utf8.RuneCountInString(res[i]) > utf8.RuneCountInString(res[j])
+	})
+
+	return res
+}
@@ -0,0 +1,47 @@
+package wordforms
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cannonflesh/wordspell/domain"
+)
+
+func TestComponent_HasForm(t *testing.T) {
+	c := New()
+	idx := map[string]bool{
+		"организация": true,
+		"организации": true,
+		"стол":        true,
+		"color":       true,
+		"ёж":          true,
+	}
+	exists := func(w string) bool { return idx[w] }
+
+	for _, tc := range []struct {
+		lang, word string
+		expected   bool
+	}{
+		{lang: domain.RuLangCode, word: "организациями", expected: true},
+		{lang: domain.RuLangCode, word: "организацией", expected: true},
+		{lang: domain.RuLangCode, word: "столами", expected: true},
+		{lang: domain.RuLangCode, word: "стола", expected: true},
+		{lang: domain.EnLangCode, word: "colors", expected: true},
+		{lang: domain.EnLangCode, word: "colored", expected: true},
+		// Ошибка в основе формой не считается.
+		{lang: domain.RuLangCode, word: "организцаиями"},
+		{lang: domain.RuLangCode, word: "сталами"},
+		// Слишком короткая основа.
+		{lang: domain.RuLangCode, word: "ежа"},
+		// Окончания другого языка.
+		{lang: domain.EnLangCode, word: "столами"},
+		{lang: domain.UnknownLangCode, word: "столами"},
+	} {
+		require.Equal(t, tc.expected, c.HasForm(tc.lang, tc.word, exists), tc.word)
+	}
+}
+
+func TestSortEndings(t *testing.T) {
+	require.Equal(t, []string{"ами", "ой", "а", ""}, sortEndings("а", "", "ой", "ами", "а"))
+}
@@ -1,6 +1,6 @@
 /* Емкость мутатора.
  *
- * Берем максимальное растояние мутаций от оригинала 2 редактирования.
+ * По умолчанию берем максимальное растояние мутаций от оригинала 2 редактирования (см. Options.Distances).
  * Если мы ограничим максимальную длину слова 24 рунами, то мутаций на удаление будет
  * не более 24*24 (+1, добавляем само слово) = 577.
  * А вот мутаций по вставке для русского алфавита (33 руны + дефис = 34)
@@ -9,59 +9,127 @@
  * может оказаться неприемлемым. Согласно же частоте распределения русских слов по длине,
  * очень мало слов длинее 24 рун.
  * А в английском языке еще меньше.
+ *
+ * Длинные слова можно исправлять на расстояние 1 (Options.LongWords): количество удалений и вставок тогда
+ * растет линейно с длиной слова, а длина ограничена MaxLongWordLen.
  */
 
 package wordmutate
 
-import "github.com/cannonflesh/wordspell/domain"
+import (
+	"strconv"
+	"strings"
+
+	"github.com/pkg/errors"
+
+	"github.com/cannonflesh/wordspell/domain"
+)
 
 type Component struct {
 	ruAlphabet []rune
 	enAlphabet []rune
+
+	distances []DistanceBucket
+	longWords string
 }
 
+// New создает мутатор с расстояниями редактирования по умолчанию (DefaultDistances).
 func New() *Component {
 	return &Component{
 		ruAlphabet: []rune(`абвгдеёжзийклмнопрстуфхцчшщъыьэюя-`),
 		enAlphabet: []rune("abcdefghijklmnopqrstuvwxyz-`'"),
+		distances:  DefaultDistances,
+		longWords:  LongWordsSkip,
 	}
 }
 
-func (s *Component) Deletes(w string) []string {
-	runeWord := []rune(w)
-	if len(runeWord) == 1 || len(runeWord) > 24 {
-		return nil
+// NewWithOptions создает мутатор с расстояниями редактирования из opt.
+func NewWithOptions(opt *Options) (*Component, error) {
+	res := New()
+
+	switch opt.LongWords {
+	case "":
+	case LongWordsSkip, LongWordsDistance1, LongWordsSegment:
+		res.longWords = opt.LongWords
+	default:
+		return nil, errors.New("unknown long words policy: " + opt.LongWords)
 	}
 
-	if len(runeWord) == 2 {
-		return []string{
-			w,
-			string(runeWord[0]),
-			string(runeWord[1]),
+	if len(opt.Distances) == 0 {
+		return res, nil
+	}
+
+	prevLen := 0
+	for _, b := range opt.Distances {
+		if b.MaxLen <= prevLen {
+			return nil, errors.Errorf("distance buckets must be sorted by max length, got %d after %d", b.MaxLen, prevLen)
 		}
+		if b.MaxDistance < 0 || b.MaxDistance > MaxDistanceLimit {
+			return nil, errors.Errorf("max edit distance must be between 0 and %d, got %d", MaxDistanceLimit, b.MaxDistance)
+		}
+		prevLen = b.MaxLen
 	}
+	res.distances = opt.Distances
 
-	res := make([]string, 0, len(runeWord)*len(runeWord)+1)
-	res = append(res, w)
+	return res, nil
+}
 
-	deletedOne := deleteRune(w)
-	res = append(res, deletedOne...)
+// MaxDistance отдает максимальное расстояние редактирования для слова длиной runeLen рун.
+// Оно не больше runeLen-1: удаления никогда не доходят до пустой строки.
+func (s *Component) MaxDistance(runeLen int) int {
+	for _, b := range s.distances {
+		if runeLen <= b.MaxLen {
+			return max(min(b.MaxDistance, runeLen-1), 0)
+		}
+	}
 
-	for _, v := range deletedOne {
-		deleted := deleteRune(v)
-		res = append(res, deleted...)
+	if s.longWords == LongWordsDistance1 && runeLen <= MaxLongWordLen {
+		return 1
 	}
 
-	return res
+	return 0
 }
 
-func deleteRune(w string) []string {
-	runeWord := []rune(w)
-	res := make([]string, 0, len(runeWord))
-	for i := 0; i < len(runeWord); i++ {
-		res = append(res, string(runeWord[:i])+string(runeWord[i+1:]))
+// MaxLen отдает длину самого длинного слова, на которое распространяются правила Distances.
+func (s *Component) MaxLen() int {
+	return s.distances[len(s.distances)-1].MaxLen
+}
+
+// IsLong сообщает, что к слову длиной runeLen рун применяется политика LongWords.
+func (s *Component) IsLong(runeLen int) bool {
+	return runeLen > s.MaxLen() && runeLen <= MaxLongWordLen
+}
+
+// LongWords отдает политику исправления длинных слов.
+func (s *Component) LongWords() string {
+	return s.longWords
+}
+
+// Policy отдает расстояния редактирования в виде строки "1:0,24:2" - для манифеста и логов.
+// Если длинные слова исправляются на расстояние 1, добавляется "*:1".
+func (s *Component) Policy() string {
+	parts := make([]string, 0, len(s.distances)+1)
+	for _, b := range s.distances {
+		parts = append(parts, strconv.Itoa(b.MaxLen)+":"+strconv.Itoa(b.MaxDistance))
 	}
 
+	if s.longWords == LongWordsDistance1 {
+		parts = append(parts, "*:1")
+	}
+
+	return strings.Join(parts, ",")
+}
+
+// Deletes отдает удаления w до расстояния MaxDistance: само слово, удаления одной руны, двух и т.д.
+// Повторы не отбрасываются. Порядок тот же, что и у EachDelete.
+func (s *Component) Deletes(w string) []string {
+	var res []string
+	s.EachDelete(w, func(d []byte) bool {
+		res = append(res, string(d))
+
+		return true
+	})
+
 	return res
 }
 
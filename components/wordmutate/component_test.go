@@ -121,3 +121,96 @@ func TestService_InsertSpace(t *testing.T) {
 	require.Equal(t, addSpace[0], "п роверка")
 	require.Equal(t, addSpace[6], "проверк а")
 }
+
+func TestComponent_MaxDistance(t *testing.T) {
+	t.Run("Default", func(t *testing.T) {
+		s := New()
+		require.Equal(t, "1:0,24:2", s.Policy())
+
+		for runeLen, distance := range map[int]int{0: 0, 1: 0, 2: 1, 3: 2, 24: 2, 25: 0} {
+			require.Equal(t, distance, s.MaxDistance(runeLen), "length: %d", runeLen)
+		}
+
+		same, err := NewWithOptions(&Options{})
+		require.NoError(t, err)
+		require.Equal(t, s.Policy(), same.Policy())
+	})
+
+	s, err := NewWithOptions(&Options{Distances: []DistanceBucket{
+		{MaxLen: 4, MaxDistance: 1},
+		{MaxLen: 12, MaxDistance: 2},
+		{MaxLen: 30, MaxDistance: 3},
+	}})
+	require.NoError(t, err)
+	require.Equal(t, "4:1,12:2,30:3", s.Policy())
+
+	t.Run("ShortBucket", func(t *testing.T) {
+		require.Equal(t, []string{"для", "ля", "дя", "дл"}, s.Deletes("для"))
+		require.Len(t, s.Deletes("ящик"), 1+4)
+		require.Equal(t, 1, s.MaxDistance(2))
+	})
+
+	t.Run("MiddleBucket", func(t *testing.T) {
+		require.Len(t, s.Deletes("ящики"), 1+5+5*4)
+		require.Len(t, s.Deletes("преображение"), 145)
+	})
+
+	t.Run("LongBucket", func(t *testing.T) {
+		dels := s.Deletes("интернационализация")
+		require.Len(t, dels, 1+19+19*18+19*18*17)
+		require.Contains(t, dels, "интернационлзаця")
+		require.NotContains(t, dels, "интернационлзця")
+	})
+
+	t.Run("TooLong", func(t *testing.T) {
+		require.Nil(t, s.Deletes("интернационализацияинтернациона"))
+	})
+
+	t.Run("Invalid", func(t *testing.T) {
+		_, err := NewWithOptions(&Options{Distances: []DistanceBucket{{MaxLen: 12, MaxDistance: 2}, {MaxLen: 4, MaxDistance: 1}}})
+		require.EqualError(t, err, "distance buckets must be sorted by max length, got 4 after 12")
+
+		_, err = NewWithOptions(&Options{Distances: []DistanceBucket{{MaxLen: 12, MaxDistance: 4}}})
+		require.EqualError(t, err, "max edit distance must be between 0 and 3, got 4")
+	})
+}
+
+func TestComponent_LongWords(t *testing.T) {
+	const long = "частнопредпринимательский"
+
+	t.Run("Skip", func(t *testing.T) {
+		s, err := NewWithOptions(&Options{LongWords: LongWordsSkip})
+		require.NoError(t, err)
+		require.Nil(t, s.Deletes(long))
+		require.True(t, s.IsLong(25))
+		require.Equal(t, "1:0,24:2", s.Policy())
+	})
+
+	t.Run("Distance1", func(t *testing.T) {
+		s, err := NewWithOptions(&Options{LongWords: LongWordsDistance1})
+		require.NoError(t, err)
+		require.Equal(t, "1:0,24:2,*:1", s.Policy())
+
+		require.Equal(t, 2, s.MaxDistance(24))
+		require.Equal(t, 1, s.MaxDistance(25))
+		require.Equal(t, 1, s.MaxDistance(MaxLongWordLen))
+		require.Zero(t, s.MaxDistance(MaxLongWordLen+1))
+
+		dels := s.Deletes(long)
+		require.Len(t, dels, 1+25)
+		require.Contains(t, dels, "частнопредпринимательски")
+	})
+
+	t.Run("Segment", func(t *testing.T) {
+		s, err := NewWithOptions(&Options{LongWords: LongWordsSegment})
+		require.NoError(t, err)
+		require.Equal(t, LongWordsSegment, s.LongWords())
+		require.Nil(t, s.Deletes(long))
+		require.Equal(t, 24, s.MaxLen())
+		require.False(t, s.IsLong(24))
+		require.False(t, s.IsLong(MaxLongWordLen+1))
+	})
+
+	_, err := NewWithOptions(&Options{LongWords: "windowed"})
+	require.EqualError(t, err, "unknown long words policy: windowed")
+}
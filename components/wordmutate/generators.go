@@ -0,0 +1,150 @@
+package wordmutate
+
+import (
+	"sync"
+	"unicode/utf8"
+
+	"github.com/cannonflesh/wordspell/domain"
+)
+
+// Генераторы кандидатов без аллокаций.
+//
+// Методы Each* перебирают те же кандидаты и в том же порядке, что Deletes, InsertRuneRu, InsertRuneEn и InsertSpace
+// (Deletes и построен на EachDelete),
+// но не создают ни строк, ни слайсов рун: кандидат собирается в переиспользуемом байтовом буфере и передается в fn.
+// Буфер действителен только до возврата из fn - чтобы сохранить кандидата, его нужно скопировать (например, string(c)).
+// Если fn отдает false, перебор прекращается, а метод отдает false.
+
+// buffers - буферы одного вызова генератора. Берутся из пула, так что в установившемся режиме память не выделяется.
+// levels[l] - удаление l+1 руны.
+type buffers struct {
+	one    []byte
+	levels [MaxDistanceLimit][]byte
+}
+
+var buffersPool = sync.Pool{
+	New: func() any {
+		return &buffers{}
+	},
+}
+
+// EachDelete перебирает удаления w до расстояния MaxDistance: само слово, удаления одной руны, двух и т.д.
+// Удаления каждого следующего уровня строятся из удалений предыдущего в том же порядке, повторы не отбрасываются.
+func (s *Component) EachDelete(w string, fn func(d []byte) bool) bool {
+	return s.EachDeleteN(w, MaxDistanceLimit, fn)
+}
+
+// EachDeleteN - то же, что EachDelete, но удаления перебираются только до расстояния distance,
+// если оно меньше MaxDistance для длины w.
+func (s *Component) EachDeleteN(w string, distance int, fn func(d []byte) bool) bool {
+	n := utf8.RuneCountInString(w)
+	distance = min(distance, s.MaxDistance(n))
+	if distance <= 0 && n != 0 {
+		return true
+	}
+
+	b := buffersPool.Get().(*buffers)
+	defer buffersPool.Put(b)
+
+	b.one = append(b.one[:0], w...)
+	if !fn(b.one) {
+		return false
+	}
+
+	if n == 2 {
+		// Для двух рун сначала остается первая руна, затем вторая.
+		_, size := utf8.DecodeRuneInString(w)
+
+		return fn(append(b.one[:0], w[:size]...)) && fn(append(b.one[:0], w[size:]...))
+	}
+
+	for depth := 1; depth <= distance; depth++ {
+		if !eachDeleteAt(b.one, depth, b.levels[:depth], fn) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// eachDeleteAt перебирает удаления ровно depth рун из w, собирая удаление каждого уровня в своем буфере levels.
+func eachDeleteAt(w []byte, depth int, levels [][]byte, fn func(d []byte) bool) bool {
+	buf := levels[0]
+	defer func() {
+		// Буфер мог вырасти - сохраняем его для следующих вызовов.
+		levels[0] = buf
+	}()
+
+	for i := 0; i < len(w); {
+		_, size := utf8.DecodeRune(w[i:])
+		buf = append(append(buf[:0], w[:i]...), w[i+size:]...)
+		i += size
+
+		var ok bool
+		if depth == 1 {
+			ok = fn(buf)
+		} else {
+			ok = eachDeleteAt(buf, depth-1, levels[1:], fn)
+		}
+		if !ok {
+			return false
+		}
+	}
+
+	return true
+}
+
+// EachInsertRuneRu перебирает вставки одной руны русского алфавита в w.
+func (s *Component) EachInsertRuneRu(w []byte, fn func(c []byte) bool) bool {
+	return eachInsertRune(w, s.ruAlphabet, fn)
+}
+
+// EachInsertRuneEn перебирает вставки одной руны английского алфавита в w.
+func (s *Component) EachInsertRuneEn(w []byte, fn func(c []byte) bool) bool {
+	return eachInsertRune(w, s.enAlphabet, fn)
+}
+
+// EachInsertSpace перебирает разбиения w пробелом на два слова.
+func (s *Component) EachInsertSpace(w string, fn func(c []byte) bool) bool {
+	b := buffersPool.Get().(*buffers)
+	defer buffersPool.Put(b)
+
+	if w == "" {
+		return true
+	}
+
+	_, first := utf8.DecodeRuneInString(w)
+	for i := first; i < len(w); {
+		b.one = append(append(append(b.one[:0], w[:i]...), domain.SpaceSeparator...), w[i:]...)
+		if !fn(b.one) {
+			return false
+		}
+
+		_, size := utf8.DecodeRuneInString(w[i:])
+		i += size
+	}
+
+	return true
+}
+
+func eachInsertRune(w []byte, alphabet []rune, fn func(c []byte) bool) bool {
+	b := buffersPool.Get().(*buffers)
+	defer buffersPool.Put(b)
+
+	for i := 0; ; {
+		for _, r := range alphabet {
+			b.one = utf8.AppendRune(append(b.one[:0], w[:i]...), r)
+			b.one = append(b.one, w[i:]...)
+			if !fn(b.one) {
+				return false
+			}
+		}
+
+		if i == len(w) {
+			return true
+		}
+
+		_, size := utf8.DecodeRune(w[i:])
+		i += size
+	}
+}
@@ -0,0 +1,127 @@
+package wordmutate
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func collect(each func(fn func(c []byte) bool) bool) []string {
+	var res []string
+	each(func(c []byte) bool {
+		res = append(res, string(c))
+
+		return true
+	})
+
+	return res
+}
+
+func TestComponent_Each(t *testing.T) {
+	s := New()
+
+	for _, w := range []string{"", "я", "ящ", "для", "преображение", "internaionaization", "д1я", "ёжик-ёж"} {
+		require.Equal(t, s.Deletes(w), collect(func(fn func(c []byte) bool) bool {
+			return s.EachDelete(w, fn)
+		}), "deletes: %s", w)
+
+		require.Equal(t, s.InsertRuneRu(w), collect(func(fn func(c []byte) bool) bool {
+			return s.EachInsertRuneRu([]byte(w), fn)
+		}), "inserts ru: %s", w)
+
+		require.Equal(t, s.InsertRuneEn(w), collect(func(fn func(c []byte) bool) bool {
+			return s.EachInsertRuneEn([]byte(w), fn)
+		}), "inserts en: %s", w)
+
+		require.ElementsMatch(t, s.InsertSpace(w), collect(func(fn func(c []byte) bool) bool {
+			return s.EachInsertSpace(w, fn)
+		}), "insert space: %s", w)
+	}
+
+	t.Run("Stop", func(t *testing.T) {
+		var seen []string
+		completed := s.EachDelete("преображение", func(d []byte) bool {
+			seen = append(seen, string(d))
+
+			return len(seen) < 3
+		})
+		require.False(t, completed)
+		require.Equal(t, []string{"преображение", "реображение", "пеображение"}, seen)
+	})
+
+	t.Run("Distance", func(t *testing.T) {
+		require.Equal(t, []string{"пукса", "укса", "пкса", "пуса", "пука", "пукс"}, collect(func(fn func(c []byte) bool) bool {
+			return s.EachDeleteN("пукса", 1, fn)
+		}))
+		require.Equal(t, s.Deletes("пукса"), collect(func(fn func(c []byte) bool) bool {
+			return s.EachDeleteN("пукса", MaxDistanceLimit, fn)
+		}))
+		require.Empty(t, collect(func(fn func(c []byte) bool) bool {
+			return s.EachDeleteN("пукса", 0, fn)
+		}))
+	})
+
+	t.Run("ZeroAllocations", func(t *testing.T) {
+		var count int
+		countFn := func(c []byte) bool {
+			count++
+
+			return true
+		}
+
+		allocs := testing.AllocsPerRun(100, func() {
+			s.EachDelete("преображение", func(d []byte) bool {
+				return s.EachInsertRuneRu(d, countFn)
+			})
+			s.EachInsertRuneEn([]byte("internaionaization"), countFn)
+			s.EachInsertSpace("игрушкадля", countFn)
+		})
+		require.Zero(t, allocs)
+		require.NotZero(t, count)
+	})
+}
+
+func BenchmarkDeletes(b *testing.B) {
+	s := New()
+
+	b.Run("Deletes", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			_ = s.Deletes("преображение")
+		}
+	})
+
+	b.Run("EachDelete", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			s.EachDelete("преображение", func(d []byte) bool {
+				return true
+			})
+		}
+	})
+}
+
+func BenchmarkInsertRune(b *testing.B) {
+	s := New()
+
+	b.Run("InsertRuneRu", func(b *testing.B) {
+		b.ReportAllocs()
+		for i := 0; i < b.N; i++ {
+			for _, w := range s.InsertRuneRu("преображени") {
+				_ = s.InsertRuneRu(w)
+			}
+		}
+	})
+
+	b.Run("EachInsertRuneRu", func(b *testing.B) {
+		b.ReportAllocs()
+		w := []byte("преображени")
+		for i := 0; i < b.N; i++ {
+			s.EachInsertRuneRu(w, func(c []byte) bool {
+				return s.EachInsertRuneRu(c, func(c []byte) bool {
+					return true
+				})
+			})
+		}
+	})
+}
@@ -0,0 +1,49 @@
+package wordmutate
+
+// MaxDistanceLimit - наибольшее допустимое расстояние редактирования.
+// Каждый следующий уровень умножает количество удалений на длину слова, а вставок - еще и на размер алфавита.
+const MaxDistanceLimit = 3
+
+// MaxLongWordLen - наибольшая длина слова в рунах, к которому применяется политика LongWords.
+// Более длинные токены - это уже не слова, а склеенные фразы или мусор, они не исправляются.
+const MaxLongWordLen = 64
+
+// Политики исправления длинных слов - длиннее последнего правила Distances.
+const (
+	// LongWordsSkip - длинные слова не исправляются (по умолчанию).
+	LongWordsSkip = "skip"
+	// LongWordsDistance1 - длинные слова исправляются на расстояние 1. Удалений у слова всего n+1,
+	// вставок - по размеру алфавита на каждую позицию, так что затраты растут с длиной слова линейно, а не квадратично.
+	// Удаления длинных слов индекса попадают и в bloom-фильтр, и в таблицу удалений.
+	LongWordsDistance1 = "distance1"
+	// LongWordsSegment - длинное слово делится на как можно меньшее количество слов индекса (склеенный запрос).
+	// Фильтр и таблица удалений при этом не меняются.
+	LongWordsSegment = "segment"
+)
+
+// DistanceBucket - максимальное расстояние редактирования для слов длиной до MaxLen рун включительно.
+type DistanceBucket struct {
+	MaxLen      int
+	MaxDistance int
+}
+
+// DefaultDistances - расстояния по умолчанию: односимвольные слова не исправляются,
+// слова до 24 рун - на расстояние 2, более длинные не исправляются.
+var DefaultDistances = []DistanceBucket{
+	{MaxLen: 1, MaxDistance: 0},
+	{MaxLen: 24, MaxDistance: 2},
+}
+
+type Options struct {
+	// Distances - максимальное расстояние редактирования в зависимости от длины слова, по возрастанию MaxLen.
+	// Слово подпадает под первое правило, MaxLen которого не меньше длины слова,
+	// слова длиннее последнего правила не исправляются. По умолчанию - DefaultDistances.
+	//
+	// Расстояние определяет глубину удалений (и для bloom-фильтра, и для таблицы удалений) и глубину поиска вставок.
+	// Поиск вставок за bloom-фильтром на расстоянии 3 очень дорог, такие расстояния имеет смысл задавать
+	// только вместе с DeleteTable.Enabled.
+	Distances []DistanceBucket
+	// LongWords - политика исправления слов длиннее последнего правила Distances: LongWordsSkip (по умолчанию),
+	// LongWordsDistance1 или LongWordsSegment.
+	LongWords string
+}
@@ -0,0 +1,64 @@
+package wordspell
+
+import (
+	"context"
+
+	"github.com/cannonflesh/wordspell/components/index"
+)
+
+// Correction - исправленный запрос и уверенность в исправлении.
+type Correction struct {
+	Corrected string
+	// Confidence - уверенность в исправлении опечаток, от 0 до 1: наименьшая из уверенностей
+	// в исправлениях отдельных слов. Для слова с весом w она равна w / (w + Confidence.RareWeight):
+	// чем чаще исправление по сравнению с редким словом, которого нет в индексе, тем ближе к 1.
+	// Если ни одна опечатка не исправлена - 1.
+	Confidence float64
+}
+
+// CorrectWithConfidence - то же, что CorrectWithOptions, но отдает еще и уверенность в исправлении.
+func (s *Service) CorrectWithConfidence(request string, opt CorrectOptions) Correction {
+	// Без отмены и таймаута correct ошибок не возвращает.
+	res, _ := s.correct(context.Background(), request, &opt)
+
+	return res
+}
+
+// plausible - слова word языка lang нет в индексе, но оно, скорее всего, правильное, и исправлять его не нужно:
+// оно есть в каталоге, но реже порогов частоты (фильтр редких слов), или в индексе есть другая его форма.
+func (s *Service) plausible(lang, word string) bool {
+	if s.rare != nil && s.rare.Test(word) {
+		return true
+	}
+
+	return s.confidence.Morphology && s.forms.HasForm(lang, word, func(w string) bool {
+		return s.index.Weight(w) > 0
+	})
+}
+
+// correctionConfidence отдает уверенность в исправлении слова языка lang на слово с весом weight
+// и признак того, что она проходит пороги Confidence.
+func (s *Service) correctionConfidence(lang string, weight uint32) (float64, bool) {
+	rare := s.rareWeight(lang)
+
+	confidence := 1.0
+	if rare > 0 {
+		confidence = float64(weight) / (float64(weight) + float64(rare))
+	}
+
+	return confidence, weight >= s.confidence.MinWeight && float64(weight) >= s.confidence.MinRatio*float64(rare)
+}
+
+// rareWeight отдает вес, который может быть у правильного слова языка lang, не попавшего в индекс:
+// Confidence.RareWeight, если он задан, иначе - порог частоты, с которым построен индекс языка.
+func (s *Service) rareWeight(lang string) uint32 {
+	if s.confidence.RareWeight > 0 {
+		return s.confidence.RareWeight
+	}
+
+	if t, found := s.thresholds[index.ThresholdKey(lang)]; found {
+		return t
+	}
+
+	return index.WordThreshold(lang)
+}
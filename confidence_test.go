@@ -0,0 +1,95 @@
+package wordspell
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cannonflesh/wordspell/components/wordforms"
+	"github.com/cannonflesh/wordspell/domain"
+	"github.com/cannonflesh/wordspell/options"
+)
+
+func TestService_CorrectWithConfidence(t *testing.T) {
+	s, _ := goldenSpeller(t)
+	s.forms = wordforms.New()
+
+	s.index.SetLangIndex(domain.EnLangCode, map[string]uint32{
+		"crux": 1000,
+	})
+	s.index.SetLangIndex(domain.RuLangCode, map[string]uint32{
+		"пукс":        100,
+		"организация": 1000,
+		"организации": 900,
+	})
+
+	_, err := fillBloomFilter(s.bloom.shared, s.index, s.mutate, 4)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name       string
+		request    string
+		confidence options.Confidence
+		expected   Correction
+	}{
+		{
+			name:     "Default",
+			request:  "crax",
+			expected: Correction{Corrected: "crux", Confidence: 1000.0 / 1010},
+		},
+		{
+			name:     "LeastConfidentWord",
+			request:  "crax пакс",
+			expected: Correction{Corrected: "crux пукс", Confidence: 100.0 / 123},
+		},
+		{
+			name:     "NothingCorrected",
+			request:  "crux организация",
+			expected: Correction{Corrected: "crux организация", Confidence: 1},
+		},
+		{
+			name:       "MinWeight",
+			request:    "crax пакс",
+			confidence: options.Confidence{MinWeight: 500},
+			expected:   Correction{Corrected: "crux пакс", Confidence: 1000.0 / 1010},
+		},
+		{
+			name:       "MinRatio",
+			request:    "crax пакс",
+			confidence: options.Confidence{MinRatio: 50},
+			expected:   Correction{Corrected: "crux пакс", Confidence: 1000.0 / 1010},
+		},
+		{
+			name:       "RareWeight",
+			request:    "crax пакс",
+			confidence: options.Confidence{RareWeight: 1000, MinRatio: 0.1},
+			expected:   Correction{Corrected: "crux пукс", Confidence: 100.0 / 1100},
+		},
+		{
+			name:     "OtherFormCorrected",
+			request:  "организациями",
+			expected: Correction{Corrected: "организации", Confidence: 900.0 / 923},
+		},
+		{
+			name:       "OtherFormPlausible",
+			request:    "организациями",
+			confidence: options.Confidence{Morphology: true},
+			expected:   Correction{Corrected: "организациями", Confidence: 1},
+		},
+		{
+			name:       "TypoInStem",
+			request:    "организцаия",
+			confidence: options.Confidence{Morphology: true},
+			expected:   Correction{Corrected: "организация", Confidence: 1000.0 / 1023},
+		},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			cs := *s
+			cs.confidence = tc.confidence
+
+			res := cs.CorrectWithConfidence(tc.request, CorrectOptions{})
+			require.Equal(t, tc.expected.Corrected, res.Corrected)
+			require.InDelta(t, tc.expected.Confidence, res.Confidence, 1e-9)
+		})
+	}
+}
@@ -0,0 +1,67 @@
+package wordspell
+
+// CorrectOptions - настройки исправления отдельного запроса (CorrectWithOptions).
+// Нулевое значение - исправление со всеми настройками сервиса, как в Correct.
+type CorrectOptions struct {
+	// SkipPairs - не склеивать соседние слова в слово индекса ("органи зация" -> "организация").
+	SkipPairs bool
+	// SkipSplit - не разбивать слово на два слова индекса ("игрушкадля" -> "игрушка для").
+	SkipSplit bool
+	// SkipEdit - не исправлять опечатки, в том числе не делить длинные слова.
+	SkipEdit bool
+	// DisabledProcessors - имена пре- и постобработчиков, которые не применяются: trademarks.Name, dupremove.Name и т.д.
+	DisabledProcessors []string
+	// MaxDistance - максимальное расстояние редактирования. 0 - по настройкам сервиса,
+	// больше них оно не бывает: удалений длиннее в bloom-фильтре и таблице удалений нет.
+	// Ограничивает и удаления из слова, и вставки в удаление.
+	MaxDistance int
+	// Langs - языки, слова которых исправляются. Слова остальных языков отдаются как есть. Пусто - все языки.
+	Langs []string
+	// MinWeightRatio - во сколько раз склейка или разбиение должны быть чаще исходного слова, чтобы заменить его.
+	// Слово, которого нет в индексе, заменяется всегда, а слово индекса опечаткой не считается при любых настройках.
+	// 0 - без ограничений.
+	MinWeightRatio float64
+}
+
+// defaultCorrectOptions - настройки Correct.
+var defaultCorrectOptions = &CorrectOptions{}
+
+// processorEnabled - применяется ли к запросу обработчик name.
+func (o *CorrectOptions) processorEnabled(name string) bool {
+	for _, disabled := range o.DisabledProcessors {
+		if disabled == name {
+			return false
+		}
+	}
+
+	return true
+}
+
+// langAllowed - исправляются ли слова языка lang.
+func (o *CorrectOptions) langAllowed(lang string) bool {
+	if len(o.Langs) == 0 {
+		return true
+	}
+
+	for _, allowed := range o.Langs {
+		if allowed == lang {
+			return true
+		}
+	}
+
+	return false
+}
+
+// maxDistance ограничивает расстояние редактирования distance из настроек сервиса.
+func (o *CorrectOptions) maxDistance(distance int) int {
+	if o.MaxDistance > 0 && o.MaxDistance < distance {
+		return o.MaxDistance
+	}
+
+	return distance
+}
+
+// outweighs - достаточно ли кандидат с весом candidate чаще исходного слова с весом original.
+func (o *CorrectOptions) outweighs(candidate, original uint32) bool {
+	return o.MinWeightRatio <= 0 || float64(candidate) >= o.MinWeightRatio*float64(original)
+}
@@ -0,0 +1,89 @@
+package wordspell
+
+import (
+	"context"
+	"time"
+	"unicode/utf8"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/cannonflesh/wordspell/components/deletetable"
+	"github.com/cannonflesh/wordspell/components/index"
+	"github.com/cannonflesh/wordspell/components/wordmutate"
+)
+
+// loadDeleteTable строит таблицу удалений по словам всех языков индекса.
+func loadDeleteTable(
+	opt *deletetable.Options,
+	idx *index.Service,
+	mutate *wordmutate.Component,
+	l *logrus.Entry,
+) (*deletetable.Component, error) {
+	start := time.Now()
+
+	res := deletetable.New(l)
+	if err := res.Build(idx.WordList(), mutate.EachDelete, opt.Workers); err != nil {
+		return nil, err
+	}
+
+	l.Infof(
+		"[DELETE TABLE BUILD] built in %v, deletes: %d, size: %d MB",
+		time.Since(start),
+		res.Len(),
+		res.Bytes()/bytesInMB,
+	)
+
+	return res, nil
+}
+
+// correctByDeleteTable ищет исправление слова по таблице удалений.
+// Порядок тот же, что и при поиске через bloom-фильтр: удаления слова перебираются по очереди,
+// для каждого сначала ищется самое частое слово индекса на одну руну длиннее удаления, затем - на две и так далее,
+// до максимального расстояния редактирования distance.
+// Только кандидаты берутся прямо из таблицы, без перебора вставок.
+// Отдает пустую строку, если исправление не найдено или ctx отменен.
+func (s *Service) correctByDeleteTable(ctx context.Context, word string, distance int) string {
+	var res string
+	s.mutate.EachDeleteN(word, distance, func(d []byte) bool {
+		if ctx.Err() != nil {
+			return false
+		}
+
+		if s.index.WeightBytes(d) > 0 {
+			res = string(d)
+
+			return false
+		}
+
+		dLen := utf8.RuneCount(d)
+
+		var (
+			best       [wordmutate.MaxDistanceLimit]string
+			bestWeight [wordmutate.MaxDistanceLimit]uint32
+		)
+		s.deleteTable.Candidates(d, func(w string) bool {
+			i := utf8.RuneCountInString(w) - dLen - 1
+			if i < 0 || i >= distance {
+				return true
+			}
+
+			if weight := s.index.Weight(w); weight > bestWeight[i] {
+				best[i], bestWeight[i] = w, weight
+			}
+
+			return true
+		})
+
+		for _, w := range best {
+			if w != "" {
+				res = w
+
+				return false
+			}
+		}
+
+		return true
+	})
+
+	return res
+}
@@ -0,0 +1,129 @@
+package wordspell
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cannonflesh/wordspell/components/bloomfilter"
+	"github.com/cannonflesh/wordspell/components/deletetable"
+	"github.com/cannonflesh/wordspell/components/manifest"
+	"github.com/cannonflesh/wordspell/domain"
+	"github.com/cannonflesh/wordspell/options"
+	"github.com/cannonflesh/wordspell/repo/embedded"
+	"github.com/cannonflesh/wordspell/testdata"
+)
+
+// hardWords - слова с ошибками из testdata и их исправления, одинаковые для bloom-фильтра и таблицы удалений.
+var hardWords = map[string]domain.DigestElement{
+	"1thф":                   domain.NewDigestReady("the"),
+	"internati-nalizфtion":   domain.NewDigestReady("internationalization"),
+	"ящиг":                   domain.NewDigestReady("ящик"),
+	"длf":                    domain.NewDigestReady("для"),
+	"д1я":                    domain.NewDigestReady("для"),
+	"безупасност2":           domain.NewDigestReady("безопасности"),
+	"internationallization":  domain.NewDigestReady("internationalization"),
+	"interniationallization": domain.NewDigestReady("internationalization"),
+	"организацияя":           domain.NewDigestReady("организация"),
+	"организзацияя":          domain.NewDigestReady("организация"),
+	"really-not-found":       domain.NewDigestRaw("really-not-found"),
+}
+
+func testdataSpeller(tb testing.TB, opt *options.Options) *Service {
+	lgr, _ := testdata.NewTestLogger()
+
+	opt.Langs = []string{domain.EnLangCode, domain.RuLangCode}
+	opt.Bloom.Startup = bloomfilter.StartupBuild
+	opt.Manifest = manifest.Options{SkipVerify: true}
+
+	s, err := newService(opt, embedded.New(testdata.Indexes), lgr)
+	require.NoError(tb, err)
+
+	return s
+}
+
+func TestService_DeleteTable(t *testing.T) {
+	bloom := testdataSpeller(t, &options.Options{})
+	table := testdataSpeller(t, &options.Options{DeleteTable: deletetable.Options{Enabled: true}})
+
+	require.Nil(t, bloom.deleteTable)
+	require.Nil(t, table.bloom)
+	require.NotZero(t, table.deleteTable.Len())
+
+	for word, expected := range hardWords {
+		byBloom, _ := bloom.correctWordWith(context.Background(), domain.NewDigestRaw(word), defaultCorrectOptions)
+		require.Equal(t, expected, byBloom, "bloom: %s", word)
+		byTable, _ := table.correctWordWith(context.Background(), domain.NewDigestRaw(word), defaultCorrectOptions)
+		require.Equal(t, expected, byTable, "delete table: %s", word)
+	}
+}
+
+func TestService_DeleteTable_MaxDistance(t *testing.T) {
+	bloom := testdataSpeller(t, &options.Options{})
+	table := testdataSpeller(t, &options.Options{DeleteTable: deletetable.Options{Enabled: true}})
+
+	for _, tc := range []struct {
+		name     string
+		request  string
+		opt      CorrectOptions
+		expected string
+	}{
+		{name: "Deletes", request: "организзацияя", expected: "организация"},
+		{name: "DeletesMaxDistance", request: "организзацияя", opt: CorrectOptions{MaxDistance: 1}, expected: "организзацияя"},
+		{name: "OneDeleteMaxDistance", request: "организацияя", opt: CorrectOptions{MaxDistance: 1}, expected: "организация"},
+		{name: "Inserts", request: "оганизаця", expected: "организация"},
+		{name: "InsertsMaxDistance", request: "оганизаця", opt: CorrectOptions{MaxDistance: 1}, expected: "оганизаця"},
+		{name: "OneInsertMaxDistance", request: "организаця", opt: CorrectOptions{MaxDistance: 1}, expected: "организация"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, bloom.CorrectWithOptions(tc.request, tc.opt), "bloom")
+			require.Equal(t, tc.expected, table.CorrectWithOptions(tc.request, tc.opt), "delete table")
+		})
+	}
+}
+
+func TestService_DeleteTable_Canceled(t *testing.T) {
+	bloom := testdataSpeller(t, &options.Options{})
+	table := testdataSpeller(t, &options.Options{DeleteTable: deletetable.Options{Enabled: true}})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	// Поиск исправления прерывается на первом же удалении, слово остается как есть.
+	typo := domain.NewDigestRaw("организзацияя")
+	for name, s := range map[string]*Service{"bloom": bloom, "delete table": table} {
+		res, confidence := s.correctWordWith(ctx, typo, defaultCorrectOptions)
+		require.Equal(t, domain.DigestElement(typo), res, name)
+		require.Equal(t, 1.0, confidence, name)
+	}
+}
+
+// BenchmarkCorrectWord сравнивает поиск исправлений через bloom-фильтр с перебором вставок и через таблицу удалений.
+// lookup-bytes - объем памяти bloom-фильтра или массивов таблицы.
+//
+//	go test -run xxx -bench CorrectWord .
+func BenchmarkCorrectWord(b *testing.B) {
+	bloom := testdataSpeller(b, &options.Options{})
+	table := testdataSpeller(b, &options.Options{DeleteTable: deletetable.Options{Enabled: true}})
+
+	for _, bc := range []struct {
+		name  string
+		s     *Service
+		bytes uint64
+	}{
+		{name: "lookup=bloom", s: bloom, bytes: uint64(bloom.bloom.shared.BitsCount() / 8)},
+		{name: "lookup=delete-table", s: table, bytes: table.deleteTable.Bytes()},
+	} {
+		b.Run(bc.name, func(b *testing.B) {
+			b.ReportAllocs()
+			b.ReportMetric(float64(bc.bytes), "lookup-bytes")
+
+			for i := 0; i < b.N; i++ {
+				for word := range hardWords {
+					bc.s.correctWordWith(context.Background(), domain.NewDigestRaw(word), defaultCorrectOptions)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,52 @@
+package domain
+
+import (
+	"github.com/pkg/errors"
+)
+
+// TransientError - временная ошибка хранилища (таймаут, недоступность, троттлинг).
+// Операцию, завершившуюся такой ошибкой, имеет смысл повторить.
+// Все прочие ошибки считаются постоянными: повтор не поможет.
+type TransientError struct {
+	err error
+}
+
+// NewTransientError помечает ошибку как временную. Для nil отдает nil.
+func NewTransientError(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	return &TransientError{err: err}
+}
+
+func (e *TransientError) Error() string {
+	return e.err.Error()
+}
+
+func (e *TransientError) Unwrap() error {
+	return e.err
+}
+
+// IsTransient сообщает, есть ли в цепочке ошибок временная.
+func IsTransient(err error) bool {
+	var te *TransientError
+
+	return errors.As(err, &te)
+}
+
+// ReadOnlyError - попытка записи в хранилище, предназначенное только для чтения.
+type ReadOnlyError struct {
+	Key string
+}
+
+func (e *ReadOnlyError) Error() string {
+	return "store is read-only, can not save " + e.Key
+}
+
+// IsReadOnly сообщает, есть ли в цепочке ошибок отказ хранилища в записи.
+func IsReadOnly(err error) bool {
+	var re *ReadOnlyError
+
+	return errors.As(err, &re)
+}
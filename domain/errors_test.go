@@ -0,0 +1,26 @@
+package domain
+
+import (
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIsTransient(t *testing.T) {
+	require.False(t, IsTransient(nil))
+	require.False(t, IsTransient(errors.New("permanent")))
+	require.Nil(t, NewTransientError(nil))
+
+	err := errors.Wrap(NewTransientError(errors.New("timeout")), "reading index")
+	require.True(t, IsTransient(err))
+	require.EqualError(t, err, "reading index: timeout")
+}
+
+func TestIsReadOnly(t *testing.T) {
+	require.False(t, IsReadOnly(errors.New("permanent")))
+
+	err := errors.Wrap(&ReadOnlyError{Key: "bloom.dat"}, "saving bloom filter")
+	require.True(t, IsReadOnly(err))
+	require.EqualError(t, err, "saving bloom filter: store is read-only, can not save bloom.dat")
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	"flag"
+
+	"github.com/sirupsen/logrus"
+
+	s3client "github.com/cannonflesh/wordspell/internal/s3"
+	s3source "github.com/cannonflesh/wordspell/repo/s3"
+)
+
+// Откатывает указатель текущего поколения индексов на одно из ранее опубликованных.
+// После отката поды приложений, использующих wordspell, нужно перезапустить.
+func main() {
+	to := flag.String("to", "", "generation to roll back to, the previous one if empty")
+	list := flag.Bool("list", false, "list stored generations and exit")
+	flag.Parse()
+
+	l := logrus.NewEntry(logrus.New())
+
+	cli, err := s3client.NewClient(s3client.Options{
+		Endpoint:        "minio:9000",
+		AccessKeyID:     "minio",
+		SecretAccessKey: "miniosecret",
+	})
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	store, err := s3source.NewStore(cli, s3source.Options{
+		Bucket: "wordspell-index",
+		Name:   "wordspell",
+	})
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	if *list {
+		gens, err := store.Generations()
+		if err != nil {
+			l.Fatal(err)
+		}
+
+		cur, err := store.Current()
+		if err != nil {
+			l.Fatal(err)
+		}
+
+		for _, g := range gens {
+			if g == cur.Generation() {
+				l.Infof("%s (current)", g)
+
+				continue
+			}
+			l.Info(g)
+		}
+
+		return
+	}
+
+	g, err := store.Rollback(*to)
+	if err != nil {
+		l.Fatal(err)
+	}
+
+	l.Infof("rolled back to generation %s", g)
+}
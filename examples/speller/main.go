@@ -18,7 +18,11 @@ import (
 	"github.com/cannonflesh/wordspell/testdata"
 )
 
-const batchSize = 1000
+const (
+	batchSize = 1000
+	// correctTimeout - сколько может исправляться один запрос, запросы дольше пропускаются.
+	correctTimeout = time.Second
+)
 
 func main() {
 	lgr := logrus.NewEntry(logrus.New())
@@ -57,7 +61,6 @@ func main() {
 
 	total := 0
 	startCorrect := time.Now()
-	startBatchCorrect := time.Now()
 	uniq := make(map[string]bool)
 
 	reqSrc, err := testdata.SearchRequests()
@@ -65,53 +68,61 @@ func main() {
 		lgr.Fatal(err)
 	}
 
-	var maxCorrectTime time.Duration
-	corrected := make(map[string]string, batchSize)
+	// Запросы исправляются пакетами: пакет исправляет пул горутин, а сохраняется он уже в фоне.
+	correctBatch := func(batch []string) map[string]string {
+		startBatchCorrect := time.Now()
+
+		corrected := make(map[string]string, len(batch))
+		for _, r := range ws.CorrectBatch(context.Background(), batch, options.Batch{Timeout: correctTimeout}) {
+			if r.Err != nil {
+				lgr.WithError(r.Err).Warnf("correcting request %q", r.Request)
+
+				continue
+			}
+
+			corrected[r.Request] = r.Corrected
+		}
+
+		total += len(batch)
+		lgr.Infof("corrected %d requests in %v, total: %d", len(batch), time.Since(startBatchCorrect), total)
+
+		return corrected
+	}
+
+	batch := make([]string, 0, batchSize)
 	for sr := range reqSrc {
 		if uniq[sr] {
 			continue
 		}
 
 		uniq[sr] = true
+		batch = append(batch, sr)
 
-		if len(corrected) == batchSize {
+		if len(batch) == batchSize {
 			wg.Add(1)
-			go func(batch map[string]string) {
+			go func(corrected map[string]string) {
 				defer wg.Done()
-				err := saveBatch(conn, batch)
+				err := saveBatch(conn, corrected)
 				if err != nil {
 					lgr.WithError(err).Error("saving batch")
 				}
-			}(corrected)
-			corrected = make(map[string]string, batchSize)
-
-			lgr.Infof("corrected %d requests in %v, total: %d", batchSize, time.Since(startBatchCorrect), total)
-			startBatchCorrect = time.Now()
+			}(correctBatch(batch))
+			batch = make([]string, 0, batchSize)
 		}
-
-		startReqCorrect := time.Now()
-		corrected[sr] = ws.Correct(sr)
-		reqCorrectElapsed := time.Since(startReqCorrect)
-		if reqCorrectElapsed > maxCorrectTime {
-			maxCorrectTime = reqCorrectElapsed
-		}
-
-		total++
-
 	}
 
 	microprof.PrintProfilingInfo(lgr, microprof.UnitsMb, false)
 
 	wg.Wait()
 
-	if len(corrected) > 0 {
-		err = saveBatch(conn, corrected)
+	if len(batch) > 0 {
+		err = saveBatch(conn, correctBatch(batch))
 		if err != nil {
 			lgr.Fatal(err)
 		}
 	}
 
-	lgr.Infof("corrected total of %d requests in %v, max correction time per request: %v", total, time.Since(startCorrect), maxCorrectTime)
+	lgr.Infof("corrected total of %d requests in %v", total, time.Since(startCorrect))
 }
 
 const saveCorrectionsSQL = "INSERT INTO search_req_correct (src_req, corrected) VALUES ($1, $2)"
@@ -0,0 +1,126 @@
+// Package hll - HyperLogLog, оценка количества уникальных элементов в фиксированном объеме памяти.
+//
+// Скетч с точностью p занимает 2^p байт, стандартная ошибка оценки - 1.04/sqrt(2^p):
+// при p = 14 это 16 КБ и ~0.8%. Скетчи с одной точностью сливаются без потери точности,
+// так что их удобно заполнять в нескольких горутинах, а затем объединять.
+package hll
+
+import (
+	"math"
+	"math/bits"
+
+	"github.com/pkg/errors"
+)
+
+const (
+	minPrecision = 4
+	maxPrecision = 18
+	// DefaultPrecision - точность по умолчанию, ~0.8% ошибки.
+	DefaultPrecision = 14
+)
+
+type Sketch struct {
+	p         uint8
+	registers []uint8
+}
+
+// New создает пустой скетч с точностью p, от 4 до 18.
+func New(p uint8) (*Sketch, error) {
+	if p < minPrecision || p > maxPrecision {
+		return nil, errors.Errorf("hyperloglog precision must be between %d and %d, got %d", minPrecision, maxPrecision, p)
+	}
+
+	return &Sketch{
+		p:         p,
+		registers: make([]uint8, 1<<p),
+	}, nil
+}
+
+// AddString добавляет строку, без аллокаций.
+func (s *Sketch) AddString(w string) {
+	s.AddHash(hashString(w))
+}
+
+// Add добавляет элемент в виде байтов, без аллокаций. Оценка та же, что у AddString(string(w)).
+func (s *Sketch) Add(w []byte) {
+	s.AddHash(hashString(w))
+}
+
+// AddHash добавляет элемент по его 64-битному хешу. Хеш должен быть хорошо перемешан.
+func (s *Sketch) AddHash(h uint64) {
+	idx := h >> (64 - s.p)
+	// Сдвигаем индекс регистра за пределы и ставим сторожевой бит, чтобы ранг не превысил 64-p+1.
+	rank := uint8(bits.LeadingZeros64(h<<s.p|1<<(s.p-1))) + 1
+	if rank > s.registers[idx] {
+		s.registers[idx] = rank
+	}
+}
+
+// Merge добавляет в скетч все элементы other.
+func (s *Sketch) Merge(other *Sketch) error {
+	if s.p != other.p {
+		return errors.Errorf("can not merge hyperloglog sketches with precision %d and %d", s.p, other.p)
+	}
+
+	for i, r := range other.registers {
+		if r > s.registers[i] {
+			s.registers[i] = r
+		}
+	}
+
+	return nil
+}
+
+// Count отдает оценку количества уникальных добавленных элементов.
+func (s *Sketch) Count() uint64 {
+	m := float64(len(s.registers))
+
+	var (
+		sum   float64
+		zeros int
+	)
+	for _, r := range s.registers {
+		sum += math.Ldexp(1, -int(r))
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	estimate := alpha(len(s.registers)) * m * m / sum
+	if estimate <= 2.5*m && zeros > 0 {
+		// На малых количествах точнее линейный подсчет по пустым регистрам.
+		estimate = m * math.Log(m/float64(zeros))
+	}
+
+	return uint64(estimate + 0.5)
+}
+
+func alpha(m int) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	}
+
+	return 0.7213 / (1 + 1.079/float64(m))
+}
+
+// hashString - FNV-1a с финальным перемешиванием murmur3: сам по себе FNV плохо распределяет старшие биты.
+func hashString[S string | []byte](w S) uint64 {
+	h := uint64(14695981039346656037)
+	for i := 0; i < len(w); i++ {
+		h ^= uint64(w[i])
+		h *= 1099511628211
+	}
+
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+
+	return h
+}
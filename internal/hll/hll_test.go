@@ -0,0 +1,63 @@
+package hll
+
+import (
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSketch_Count(t *testing.T) {
+	for _, n := range []int{0, 10, 1000, 50000, 1000000} {
+		s, err := New(DefaultPrecision)
+		require.NoError(t, err)
+
+		for i := 0; i < n; i++ {
+			w := "удаление-" + strconv.Itoa(i)
+			s.AddString(w)
+			// Повторы не учитываются.
+			s.AddString(w)
+		}
+
+		require.InEpsilon(t, float64(n)+1, float64(s.Count())+1, 0.03, "n: %d", n)
+	}
+}
+
+func TestSketch_Merge(t *testing.T) {
+	a, err := New(DefaultPrecision)
+	require.NoError(t, err)
+	b, err := New(DefaultPrecision)
+	require.NoError(t, err)
+
+	for i := 0; i < 100000; i++ {
+		a.AddString(strconv.Itoa(i))
+		b.AddString(strconv.Itoa(i + 50000))
+	}
+
+	require.NoError(t, a.Merge(b))
+	require.InEpsilon(t, 150000, float64(a.Count()), 0.03)
+
+	c, err := New(10)
+	require.NoError(t, err)
+	require.EqualError(t, a.Merge(c), "can not merge hyperloglog sketches with precision 14 and 10")
+}
+
+func TestSketch_Add(t *testing.T) {
+	a, err := New(DefaultPrecision)
+	require.NoError(t, err)
+	b, err := New(DefaultPrecision)
+	require.NoError(t, err)
+
+	for i := 0; i < 10000; i++ {
+		w := "удаление-" + strconv.Itoa(i)
+		a.AddString(w)
+		b.Add([]byte(w))
+	}
+
+	require.Equal(t, a.registers, b.registers)
+}
+
+func TestNew_Precision(t *testing.T) {
+	_, err := New(3)
+	require.EqualError(t, err, "hyperloglog precision must be between 4 and 18, got 3")
+}
@@ -2,8 +2,10 @@ package s3
 
 import (
 	"context"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/config"
 	"github.com/aws/aws-sdk-go-v2/credentials"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
@@ -39,6 +41,9 @@ func NewClient(opts Options) (*s3.Client, error) {
 		context.Background(),
 		config.WithCredentialsProvider(staticProvider),
 		config.WithRegion(region),
+		config.WithRetryer(func() aws.Retryer {
+			return NewRetryer(opts)
+		}),
 	)
 	if err != nil {
 		return nil, errors.WithStack(err)
@@ -54,3 +59,18 @@ func NewClient(opts Options) (*s3.Client, error) {
 
 	return cli, nil
 }
+
+// NewRetryer отдает стратегию повторов запросов согласно RetriesCount и RetryTimeout.
+// Повторяются лишь запросы, завершившиеся временными ошибками, паузы растут экспоненциально, с джиттером.
+func NewRetryer(opts Options) aws.Retryer {
+	return retry.NewStandard(func(o *retry.StandardOptions) {
+		if opts.RetriesCount > 0 {
+			o.MaxAttempts = opts.RetriesCount + 1
+		}
+
+		if opts.RetryTimeout > 0 {
+			o.MaxBackoff = time.Duration(opts.RetryTimeout) * time.Second
+			o.Backoff = retry.NewExponentialJitterBackoff(o.MaxBackoff)
+		}
+	})
+}
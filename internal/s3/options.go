@@ -7,6 +7,8 @@ type Options struct {
 	SecretAccessKey string
 	Region          string
 	Secure          bool
-	RetriesCount    int
-	RetryTimeout    int
+	// RetriesCount - сколько раз повторять запрос, завершившийся временной ошибкой.
+	RetriesCount int
+	// RetryTimeout - максимальная пауза между повторами, в секундах.
+	RetryTimeout int
 }
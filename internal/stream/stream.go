@@ -0,0 +1,41 @@
+// Package stream позволяет сохранять артефакты в DataStore по мере их формирования,
+// не накапливая сериализованные данные в памяти целиком.
+package stream
+
+import (
+	"bufio"
+	"io"
+)
+
+type saver interface {
+	Save(key string, content io.Reader) error
+}
+
+// Save передает в store.Save ридер, данные в который пишет write, запущенная в отдельной горутине.
+// Ошибка write возвращается читающей стороне вместо данных, и Save вернет ее.
+// Если store.Save вернулась, не дочитав данные, write получит ошибку записи и завершится.
+func Save(store saver, key string, write func(w io.Writer) error) error {
+	pr, pw := io.Pipe()
+
+	writeErr := make(chan error, 1)
+	go func() {
+		bw := bufio.NewWriter(pw)
+
+		err := write(bw)
+		if err == nil {
+			err = bw.Flush()
+		}
+
+		_ = pw.CloseWithError(err)
+		writeErr <- err
+	}()
+
+	err := store.Save(key, pr)
+	_ = pr.CloseWithError(io.ErrClosedPipe)
+
+	if wErr := <-writeErr; err == nil && wErr != nil {
+		err = wErr
+	}
+
+	return err
+}
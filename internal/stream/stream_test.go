@@ -0,0 +1,63 @@
+package stream
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+type bufferStore struct {
+	data  map[string]string
+	limit int64
+	err   error
+}
+
+func (s *bufferStore) Save(key string, content io.Reader) error {
+	if s.limit > 0 {
+		content = io.LimitReader(content, s.limit)
+	}
+
+	buf := new(bytes.Buffer)
+	if _, err := io.Copy(buf, content); err != nil {
+		return err
+	}
+	s.data[key] = buf.String()
+
+	return s.err
+}
+
+func TestSave(t *testing.T) {
+	t.Run("Success", func(t *testing.T) {
+		store := &bufferStore{data: make(map[string]string)}
+		err := Save(store, "key", func(w io.Writer) error {
+			_, err := io.WriteString(w, "streamed content")
+
+			return err
+		})
+		require.NoError(t, err)
+		require.Equal(t, "streamed content", store.data["key"])
+	})
+	t.Run("WriteError", func(t *testing.T) {
+		store := &bufferStore{data: make(map[string]string)}
+		err := Save(store, "key", func(w io.Writer) error {
+			return errors.New("source failed")
+		})
+		require.EqualError(t, err, "source failed")
+	})
+	t.Run("StoreStopsReading", func(t *testing.T) {
+		store := &bufferStore{data: make(map[string]string), limit: 10, err: errors.New("store failed")}
+		err := Save(store, "key", func(w io.Writer) error {
+			for i := 0; i < 100000; i++ {
+				if _, err := io.WriteString(w, "endless content "); err != nil {
+					return err
+				}
+			}
+
+			return nil
+		})
+		require.EqualError(t, err, "store failed")
+	})
+}
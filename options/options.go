@@ -1,16 +1,107 @@
 package options
 
 import (
+	"time"
+
 	"github.com/cannonflesh/wordspell/components/bloomfilter"
+	"github.com/cannonflesh/wordspell/components/deletetable"
+	"github.com/cannonflesh/wordspell/components/manifest"
+	"github.com/cannonflesh/wordspell/components/wordmutate"
 	"github.com/cannonflesh/wordspell/internal/postgres"
 	s3client "github.com/cannonflesh/wordspell/internal/s3"
+	"github.com/cannonflesh/wordspell/repo/cache"
+	"github.com/cannonflesh/wordspell/repo/compress"
 	s3repo "github.com/cannonflesh/wordspell/repo/s3"
 )
 
 type Options struct {
-	Bloom    bloomfilter.Options
-	SiteDB   postgres.Options
-	S3Client s3client.Options
-	S3Data   s3repo.Options
-	Langs    []string
+	Bloom       bloomfilter.Options
+	DeleteTable deletetable.Options
+	Mutate      wordmutate.Options
+	SiteDB      postgres.Options
+	S3Client    s3client.Options
+	S3Data      s3repo.Options
+	Cache       cache.Options
+	Compression compress.Options
+	Manifest    manifest.Options
+	Parallel    Parallel
+	Batch       Batch
+	Confidence  Confidence
+	Index       IndexBuilder
+	Langs       []string
+}
+
+// Parallel - параллельное исправление токенов одного запроса.
+type Parallel struct {
+	// Workers - сколько токенов одного запроса исправляются одновременно.
+	// 0 и 1 - токены исправляются по очереди.
+	Workers int
+}
+
+// Batch - настройки пакетного и потокового исправления (CorrectBatch, CorrectStream).
+type Batch struct {
+	// Workers - сколько запросов исправляются одновременно. По умолчанию - по числу ядер.
+	Workers int
+	// Timeout - сколько может исправляться один запрос. 0 - без ограничения.
+	Timeout time.Duration
+}
+
+// Confidence - когда исправление опечатки считается надежным. Нулевое значение - любое найденное исправление.
+type Confidence struct {
+	// MinWeight - исправление с меньшим весом не применяется.
+	MinWeight uint32
+	// MinRatio - во сколько раз вес исправления должен превышать RareWeight.
+	MinRatio float64
+	// RareWeight - вес, который может быть у правильного слова, не попавшего в индекс.
+	// По умолчанию - порог частоты, с которым построен индекс языка слова (из манифеста, а без него - index.WordThreshold).
+	RareWeight uint32
+	// Morphology - не исправлять слово, если в индексе есть другая его форма ("организациями" при "организация").
+	Morphology bool
+}
+
+// Источники слов индекса, ключи IndexBuilder.SourceWeights.
+const (
+	SourceItemName    = "item_name"
+	SourceDescription = "description"
+	SourceCategory    = "category"
+)
+
+// Формулы веса слова в индексе, значения IndexBuilder.Weight.
+const (
+	// WeightTF - вхождения слова с весами источников (по умолчанию).
+	WeightTF = "tf"
+	// WeightDF - товары и категории со словом: каждый учитывается один раз, с наибольшим весом источника,
+	// в котором встретилось слово. Описание, повторяющее слово много раз, весит как одно вхождение.
+	WeightDF = "df"
+	// WeightDFSources - WeightDF, умноженный на число разных источников слова:
+	// слово из названий, описаний и категорий надежнее слова только из описаний.
+	WeightDFSources = "df_sources"
+)
+
+// IndexBuilder - настройки построения индексов по каталогу. Незаданные поля - значения по умолчанию.
+type IndexBuilder struct {
+	// Thresholds - пороги частоты слов по языкам: слова реже не попадают в индекс. По умолчанию - ru: 23, en: 10.
+	Thresholds map[string]uint32
+	// PairThreshold - порог частоты пар слов. По умолчанию - 50.
+	PairThreshold uint32
+	// ItemBatchSize и CategoryBatchSize - сколько товаров и категорий читается из БД за раз.
+	// По умолчанию - 100000 и 10000.
+	ItemBatchSize     int
+	CategoryBatchSize int
+	// SourceWeights - сколько весит одно вхождение слова в источнике SourceItemName, SourceDescription или SourceCategory.
+	// По умолчанию - 1 у каждого. Источник с весом 0 не учитывается.
+	SourceWeights map[string]uint32
+	// MaxItemFrequency - наибольший вклад одного товара (названия с описанием) или одной категории в частоту слова:
+	// одно заспамленное описание не должно решать, попадет ли слово в индекс. 0 - без ограничения.
+	MaxItemFrequency uint32
+	// Weight - формула веса слова в индексе: WeightTF (по умолчанию), WeightDF или WeightDFSources.
+	// Пороги Thresholds и PairThreshold сравниваются с весом по этой формуле.
+	Weight string
+	// MinItems - слова и пары, встретившиеся меньше чем в MinItems разных товарах и категориях,
+	// не попадают в индекс при любом весе. 0 - без ограничения.
+	MinItems uint32
+	// RareMinItems - слово, не попавшее в индекс, считается редким (см. RareWords и фильтр редких слов),
+	// только если встретилось хотя бы в RareMinItems разных товарах и категориях. Опечатка, попавшая в каталог однажды,
+	// редким словом не считается и исправляется. По умолчанию - 2, 1 - редкие все слова ниже порогов.
+	RareMinItems uint32
 }
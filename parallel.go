@@ -0,0 +1,90 @@
+package wordspell
+
+import (
+	"context"
+	"sync"
+
+	"github.com/cannonflesh/wordspell/domain"
+)
+
+// correctDigestParallel исправляет сырые токены дайджеста не более чем workers горутинами.
+// Токены независимы друг от друга, поэтому каждый результат пишется в свою позицию,
+// и порядок слов совпадает с последовательным исправлением.
+// После отмены ctx еще не начатые токены не исправляются, и возвращается ошибка ctx.
+func (s *Service) correctDigestParallel(
+	ctx context.Context,
+	digest domain.Digest,
+	workers int,
+	opt *CorrectOptions,
+) ([]string, float64, error) {
+	res := make([]string, len(digest))
+	confidences := make([]float64, len(digest))
+	raw := make([]int, 0, len(digest))
+
+	for i, v := range digest {
+		if _, ok := v.(domain.DigestRaw); ok {
+			raw = append(raw, i)
+		} else {
+			res[i], confidences[i] = v.String(), 1
+		}
+	}
+
+	if workers > len(raw) {
+		workers = len(raw)
+	}
+
+	// Один сырой токен нет смысла отдавать в отдельную горутину.
+	if workers < 2 {
+		for _, i := range raw {
+			if err := ctx.Err(); err != nil {
+				return nil, 0, err
+			}
+
+			res[i], confidences[i] = s.correctToken(ctx, digest[i], opt)
+		}
+		if err := ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+
+		return res, minConfidence(confidences), nil
+	}
+
+	jobs := make(chan int)
+	wg := sync.WaitGroup{}
+	wg.Add(workers)
+
+	for w := 0; w < workers; w++ {
+		go func() {
+			defer wg.Done()
+
+			for i := range jobs {
+				if ctx.Err() == nil {
+					res[i], confidences[i] = s.correctToken(ctx, digest[i], opt)
+				}
+			}
+		}()
+	}
+
+	for _, i := range raw {
+		jobs <- i
+	}
+	close(jobs)
+
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return res, minConfidence(confidences), nil
+}
+
+// minConfidence отдает наименьшую из уверенностей, 1 - для пустого запроса.
+func minConfidence(confidences []float64) float64 {
+	res := 1.0
+	for _, c := range confidences {
+		res = min(res, c)
+	}
+
+	return res
+}
@@ -0,0 +1,106 @@
+package wordspell
+
+import (
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cannonflesh/wordspell/components/deletetable"
+	"github.com/cannonflesh/wordspell/options"
+)
+
+// randomRequest собирает запрос из слов индекса, слов с ошибками, чисел, размеров и склеенных слов.
+func randomRequest(rnd *rand.Rand, words, typos []string) string {
+	tokens := make([]string, 1+rnd.Intn(8))
+	for i := range tokens {
+		switch rnd.Intn(6) {
+		case 0:
+			tokens[i] = typos[rnd.Intn(len(typos))]
+		case 1:
+			tokens[i] = typo(rnd, words[rnd.Intn(len(words))])
+		case 2:
+			tokens[i] = words[rnd.Intn(len(words))] + words[rnd.Intn(len(words))]
+		case 3:
+			tokens[i] = []string{"10x20", "56cm", "a4", "5", "d56ft"}[rnd.Intn(5)]
+		default:
+			tokens[i] = words[rnd.Intn(len(words))]
+		}
+	}
+
+	return strings.Join(tokens, " ")
+}
+
+// typo портит в слове одну руну: удаляет ее, удваивает или меняет с соседней.
+func typo(rnd *rand.Rand, w string) string {
+	r := []rune(w)
+	if len(r) < 2 {
+		return w
+	}
+
+	i := rnd.Intn(len(r) - 1)
+	switch rnd.Intn(3) {
+	case 0:
+		r = append(r[:i], r[i+1:]...)
+	case 1:
+		r = append(r[:i+1], r[i:]...)
+	default:
+		r[i], r[i+1] = r[i+1], r[i]
+	}
+
+	return string(r)
+}
+
+func TestService_CorrectParallel(t *testing.T) {
+	typos := make([]string, 0, len(hardWords))
+	for w := range hardWords {
+		typos = append(typos, w)
+	}
+	sort.Strings(typos)
+
+	for name, opt := range map[string]*options.Options{
+		"lookup=bloom":        {},
+		"lookup=delete-table": {DeleteTable: deletetable.Options{Enabled: true}},
+	} {
+		t.Run(name, func(t *testing.T) {
+			seq := testdataSpeller(t, opt)
+			words := seq.index.WordList()
+			sort.Strings(words)
+
+			rnd := rand.New(rand.NewSource(1))
+			for i := 0; i < 50; i++ {
+				request := randomRequest(rnd, words, typos)
+				expected := seq.Correct(request)
+
+				for _, workers := range []int{2, 3, 8} {
+					par := *seq
+					par.workers = workers
+
+					require.Equal(t, expected, par.Correct(request), "workers: %d, request: %q", workers, request)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkCorrect сравнивает последовательное и параллельное исправление запроса с двумя трудными опечатками.
+//
+//	go test -run xxx -bench 'Correct$' .
+func BenchmarkCorrect(b *testing.B) {
+	s := testdataSpeller(b, &options.Options{})
+	request := "организзацияя для безупасност2 interniationallization the ящиг"
+
+	for _, workers := range []int{1, 4} {
+		par := *s
+		par.workers = workers
+
+		b.Run("workers="+strconv.Itoa(workers), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				par.Correct(request)
+			}
+		})
+	}
+}
@@ -8,6 +8,9 @@ import (
 	"github.com/cannonflesh/wordspell/processors"
 )
 
+// Name - имя обработчика, по которому его можно отключить для отдельного запроса.
+const Name = "dimensions"
+
 type Processor struct {
 	requestRe   *regexp.Regexp
 	separatorRe *regexp.Regexp
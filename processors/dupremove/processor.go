@@ -2,6 +2,9 @@ package dupremove
 
 import "strings"
 
+// Name - имя обработчика, по которому его можно отключить для отдельного запроса.
+const Name = "dupremove"
+
 type Processor struct{}
 
 func New() *Processor {
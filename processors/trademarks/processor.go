@@ -4,6 +4,9 @@ type finder interface {
 	Find(hayStack []string) (string, []string)
 }
 
+// Name - имя обработчика, по которому его можно отключить для отдельного запроса.
+const Name = "trademarks"
+
 type Processor struct {
 	tradeMarks finder
 }
@@ -0,0 +1,59 @@
+package wordspell
+
+import (
+	"time"
+
+	"github.com/sirupsen/logrus"
+
+	"github.com/cannonflesh/wordspell/components/bloomfilter"
+	"github.com/cannonflesh/wordspell/components/manifest"
+	"github.com/cannonflesh/wordspell/options"
+)
+
+// buildRareFilter строит фильтр редких слов: слов каталога, которые билдер индекса отбросил по порогам частоты.
+func buildRareFilter(rare *bloomfilter.Component, words []string, l *logrus.Entry) (*manifest.FilterStats, error) {
+	start := time.Now()
+
+	rare.Reset(uint(len(words)))
+	rare.Add(words...)
+	if err := rare.Seal(); err != nil {
+		return nil, err
+	}
+
+	stats := &manifest.FilterStats{
+		Type:                      rare.FilterType(),
+		Bits:                      uint64(rare.BitsCount()),
+		FalsePositiveRate:         rare.FalsePositiveRate(),
+		MeasuredFalsePositiveRate: rare.MeasureFalsePositiveRate(falsePositiveProbes),
+	}
+	l.Infof(
+		"[RARE FILTER BUILD] %s built in %v, words: %d, type: %s, filter size: %d bits, false positive rate: %g, measured: %g",
+		rare.Key(),
+		time.Since(start),
+		len(words),
+		stats.Type,
+		stats.Bits,
+		stats.FalsePositiveRate,
+		stats.MeasuredFalsePositiveRate,
+	)
+
+	return stats, nil
+}
+
+// loadRareFilter загружает фильтр редких слов, если он включен (Bloom.Rare.Enabled), иначе отдает nil.
+// Построить его по индексу нельзя: редких слов в индексе как раз и нет.
+func loadRareFilter(opt *options.Options, store bloomfilter.ReadOnlyStore, l *logrus.Entry) (*bloomfilter.Component, error) {
+	if !opt.Bloom.Rare.Enabled {
+		return nil, nil
+	}
+
+	start := time.Now()
+
+	res := bloomfilter.NewRare(&opt.Bloom, store, l)
+	if err := res.Load(); err != nil {
+		return nil, err
+	}
+	l.Infof("rare words filter loaded in %v, type: %s", time.Since(start), res.FilterType())
+
+	return res, nil
+}
@@ -0,0 +1,6 @@
+package cache
+
+type Options struct {
+	// Dir - каталог локального кеша артефактов. Если не задан, кеш не используется.
+	Dir string
+}
@@ -0,0 +1,441 @@
+package cache
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+
+	"github.com/pkg/errors"
+	"github.com/sirupsen/logrus"
+
+	"github.com/cannonflesh/wordspell/domain"
+)
+
+const (
+	metaSuffix = ".meta"
+	tmpPattern = ".download-*"
+	// generationsDir - каталог копий артефактов по поколениям: <Dir>/generations/<generation>/ru.index.
+	generationsDir = "generations"
+	// completeMarker - файл с последним поколением, все артефакты которого скачаны и проверены (см. Complete).
+	completeMarker = "COMPLETE"
+)
+
+// DataStore - удаленное хранилище, артефакты которого кешируются на локальном диске.
+type DataStore interface {
+	DataReader(key string) (io.ReadCloser, error)
+	IsExist(key string) (bool, error)
+	Save(key string, content io.Reader) error
+}
+
+// versioner позволяет дешево (без скачивания) узнать версию артефакта в удаленном хранилище.
+// Для s3 это ETag, для файлового хранилища - размер и время изменения файла.
+type versioner interface {
+	Version(key string) (string, error)
+}
+
+type generational interface {
+	Generation() string
+}
+
+// meta описывает локальную копию артефакта.
+type meta struct {
+	Version string `json:"version"`
+	SHA256  string `json:"sha256"`
+	Size    int64  `json:"size"`
+}
+
+// Store - декоратор DataStore, хранящий копии артефактов на локальном диске.
+//
+// На старте пода артефакт скачивается, только если его версия в удаленном хранилище
+// не совпадает с версией локальной копии. Копии каждого поколения лежат в собственном каталоге,
+// а поколение, все артефакты которого скачаны, отмечается вызовом Complete.
+// Если удаленное хранилище недоступно (Unavailable), артефакты читаются из последнего такого поколения:
+// сбой посреди скачивания нового поколения не смешивает его артефакты со старыми.
+// Если хранилище доступно, но отвечает временной ошибкой (domain.IsTransient), читается локальная копия,
+// постоянные ошибки (артефакт удален, доступ запрещен) отдаются как есть.
+// Контрольная сумма локальной копии проверяется при каждом чтении.
+type Store struct {
+	dir    string
+	inner  DataStore
+	logger *logrus.Entry
+	// generation - поколение, копии артефактов которого читает и пишет Store. Пустое - поколений нет.
+	generation string
+
+	mu sync.Mutex
+}
+
+// New конструктор кеширующего хранилища.
+func New(opt *Options, inner DataStore, l *logrus.Entry) (*Store, error) {
+	if err := os.MkdirAll(opt.Dir, 0755); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	res := &Store{
+		dir:    opt.Dir,
+		inner:  inner,
+		logger: l.WithField(domain.CategoryFieldName, "repo.cache"),
+	}
+
+	if gs, ok := inner.(generational); ok {
+		res.generation = gs.Generation()
+	}
+	if res.Offline() {
+		generation, err := res.completeGeneration()
+		if err != nil {
+			return nil, err
+		}
+		res.generation = generation
+	}
+
+	return res, nil
+}
+
+// Generation отдает поколение артефактов удаленного хранилища, если оно к нему привязано,
+// а если хранилище недоступно - последнее полностью скачанное поколение.
+func (s *Store) Generation() string {
+	return s.generation
+}
+
+// Offline - удаленное хранилище недоступно (Unavailable), артефакты читаются из последнего полностью скачанного поколения.
+func (s *Store) Offline() bool {
+	_, down := s.inner.(*unavailableStore)
+
+	return down
+}
+
+// Complete отмечает, что все артефакты текущего поколения скачаны и проверены: именно оно будет прочитано,
+// если удаленное хранилище окажется недоступно. Копии остальных поколений удаляются.
+func (s *Store) Complete() error {
+	if s.generation == "" || s.Offline() {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	fh, err := os.CreateTemp(s.dir, completeMarker+tmpPattern)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	_, err = fh.WriteString(s.generation)
+	closeErr := fh.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(fh.Name(), filepath.Join(s.dir, completeMarker))
+	}
+	if err != nil {
+		_ = os.Remove(fh.Name())
+
+		return errors.WithStack(err)
+	}
+
+	entries, err := os.ReadDir(filepath.Join(s.dir, generationsDir))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	for _, e := range entries {
+		if e.Name() != s.generation {
+			if err = os.RemoveAll(filepath.Join(s.dir, generationsDir, e.Name())); err != nil {
+				return errors.WithStack(err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// completeGeneration отдает поколение, отмеченное Complete, или пустую строку, если такого нет.
+func (s *Store) completeGeneration() (string, error) {
+	data, err := os.ReadFile(filepath.Join(s.dir, completeMarker))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", nil
+	}
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return string(data), nil
+}
+
+///// Имплементация интерфейса index.dataStore /////
+
+// DataReader отдает ридер локальной копии артефакта, при необходимости обновив ее.
+func (s *Store) DataReader(key string) (io.ReadCloser, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	local, localErr := s.readMeta(key)
+	if localErr != nil {
+		s.logger.WithError(localErr).Warnf("local copy of %s is not usable", key)
+		local = nil
+	}
+
+	version, err := s.remoteVersion(key)
+	switch {
+	case err != nil && local != nil && s.unreachable(err):
+		s.logger.WithError(err).Warnf("remote store is unavailable, using local copy of %s", key)
+
+		return s.openLocal(key, local)
+	case err != nil:
+		return nil, err
+	case local != nil && version != "" && local.Version == version:
+		rc, err := s.openLocal(key, local)
+		if err == nil {
+			return rc, nil
+		}
+
+		// Испорченная копия скачивается заново.
+		s.logger.WithError(err).Warnf("local copy of %s is not usable", key)
+		local = nil
+	}
+
+	if err = s.download(key, version); err != nil {
+		if local == nil || !s.unreachable(err) {
+			return nil, err
+		}
+
+		s.logger.WithError(err).Warnf("downloading %s failed, using local copy", key)
+
+		return s.openLocal(key, local)
+	}
+
+	return s.open(key)
+}
+
+// IsExist спрашивает удаленное хранилище, а если оно недоступно - проверяет наличие локальной копии.
+func (s *Store) IsExist(key string) (bool, error) {
+	exists, err := s.inner.IsExist(key)
+	if err == nil || !s.unreachable(err) {
+		return exists, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if local, metaErr := s.readMeta(key); metaErr == nil && local != nil {
+		s.logger.WithError(err).Warnf("remote store is unavailable, local copy of %s found", key)
+
+		return true, nil
+	}
+
+	return false, err
+}
+
+// Save сохраняет артефакт в удаленное хранилище и обновляет его локальную копию.
+func (s *Store) Save(key string, content io.Reader) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	tmpPath, m, err := s.writeTemp(key, content)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmpPath)
+	}()
+
+	fh, err := os.Open(tmpPath)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+	err = s.inner.Save(key, fh)
+	_ = fh.Close()
+	if err != nil {
+		return err
+	}
+
+	m.Version, err = s.remoteVersion(key)
+	if err != nil {
+		return err
+	}
+
+	return s.commit(key, tmpPath, m)
+}
+
+// unreachable сообщает, что ошибка err удаленного хранилища означает его недоступность, и можно читать локальную копию.
+// Постоянные ошибки (артефакт удален, доступ запрещен) отдаются как есть: копию удаленного артефакта отдавать нельзя.
+func (s *Store) unreachable(err error) bool {
+	return s.Offline() || domain.IsTransient(err)
+}
+
+func (s *Store) remoteVersion(key string) (string, error) {
+	if v, ok := s.inner.(versioner); ok {
+		return v.Version(key)
+	}
+
+	// Версию узнать нельзя, значит, артефакт придется скачать, но сначала убедимся, что хранилище доступно.
+	_, err := s.inner.IsExist(key)
+
+	return "", err
+}
+
+func (s *Store) download(key, version string) error {
+	rc, err := s.inner.DataReader(key)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = rc.Close()
+	}()
+
+	tmpPath, m, err := s.writeTemp(key, rc)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = os.Remove(tmpPath)
+	}()
+
+	m.Version = version
+
+	return s.commit(key, tmpPath, m)
+}
+
+// writeTemp пишет данные во временный файл рядом с локальной копией, считая контрольную сумму.
+func (s *Store) writeTemp(key string, content io.Reader) (string, *meta, error) {
+	dataPath := s.dataPath(key)
+	if err := os.MkdirAll(filepath.Dir(dataPath), 0755); err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+
+	fh, err := os.CreateTemp(filepath.Dir(dataPath), filepath.Base(dataPath)+tmpPattern)
+	if err != nil {
+		return "", nil, errors.WithStack(err)
+	}
+
+	h := sha256.New()
+	size, err := io.Copy(io.MultiWriter(fh, h), content)
+	closeErr := fh.Close()
+	if err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(fh.Name())
+
+		return "", nil, errors.WithStack(err)
+	}
+
+	return fh.Name(), &meta{SHA256: hex.EncodeToString(h.Sum(nil)), Size: size}, nil
+}
+
+// commit подменяет локальную копию полностью записанным временным файлом.
+// Описание копии пишется последним: копия без описания считается непригодной.
+func (s *Store) commit(key, tmpPath string, m *meta) error {
+	_ = os.Remove(s.metaPath(key))
+
+	if err := os.Rename(tmpPath, s.dataPath(key)); err != nil {
+		return errors.WithStack(err)
+	}
+
+	data, err := json.Marshal(m)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(os.WriteFile(s.metaPath(key), data, 0644))
+}
+
+// readMeta отдает описание локальной копии или nil, если копии нет.
+func (s *Store) readMeta(key string) (*meta, error) {
+	data, err := os.ReadFile(s.metaPath(key))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	res := &meta{}
+	if err = json.Unmarshal(data, res); err != nil {
+		return nil, errors.Wrap(err, "decoding local copy meta: "+key)
+	}
+
+	st, err := os.Stat(s.dataPath(key))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+	if st.Size() != res.Size {
+		return nil, errors.Errorf("local copy of %s is truncated: expected %d, found %d", key, res.Size, st.Size())
+	}
+
+	return res, nil
+}
+
+// openLocal проверяет контрольную сумму локальной копии и открывает ее.
+func (s *Store) openLocal(key string, m *meta) (io.ReadCloser, error) {
+	fh, err := os.Open(s.dataPath(key))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	h := sha256.New()
+	if _, err = io.Copy(h, fh); err == nil {
+		_, err = fh.Seek(0, io.SeekStart)
+	}
+	if err != nil {
+		_ = fh.Close()
+
+		return nil, errors.WithStack(err)
+	}
+
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != m.SHA256 {
+		_ = fh.Close()
+
+		return nil, errors.Errorf("local copy of %s is corrupted: expected sha256 %s, found %s", key, m.SHA256, sum)
+	}
+
+	return fh, nil
+}
+
+func (s *Store) open(key string) (io.ReadCloser, error) {
+	fh, err := os.Open(s.dataPath(key))
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return fh, nil
+}
+
+func (s *Store) dataPath(key string) string {
+	if s.generation == "" {
+		return filepath.Join(s.dir, filepath.FromSlash(key))
+	}
+
+	return filepath.Join(s.dir, generationsDir, s.generation, filepath.FromSlash(key))
+}
+
+func (s *Store) metaPath(key string) string {
+	return s.dataPath(key) + metaSuffix
+}
+
+// Unavailable отдает DataStore, все методы которого возвращают err.
+// Используется как удаленное хранилище, когда оно недоступно уже при старте:
+// тогда все артефакты будут прочитаны из локального кеша.
+func Unavailable(err error) DataStore {
+	return &unavailableStore{err: err}
+}
+
+type unavailableStore struct {
+	err error
+}
+
+func (u *unavailableStore) DataReader(string) (io.ReadCloser, error) {
+	return nil, u.err
+}
+
+func (u *unavailableStore) IsExist(string) (bool, error) {
+	return false, u.err
+}
+
+func (u *unavailableStore) Save(string, io.Reader) error {
+	return u.err
+}
@@ -0,0 +1,249 @@
+package cache
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+
+	"github.com/cannonflesh/wordspell/domain"
+	"github.com/cannonflesh/wordspell/repo/file"
+	"github.com/cannonflesh/wordspell/testdata"
+)
+
+const checkKey = "ru.index"
+
+// countingStore считает скачивания и умеет притворяться недоступным.
+type countingStore struct {
+	*file.Store
+	reads int
+	down  error
+	// readErr - ошибка только скачивания, версия при этом отдается.
+	readErr error
+}
+
+func (c *countingStore) DataReader(key string) (io.ReadCloser, error) {
+	if c.down != nil {
+		return nil, c.down
+	}
+	if c.readErr != nil {
+		return nil, c.readErr
+	}
+	c.reads++
+
+	return c.Store.DataReader(key)
+}
+
+func (c *countingStore) IsExist(key string) (bool, error) {
+	if c.down != nil {
+		return false, c.down
+	}
+
+	return c.Store.IsExist(key)
+}
+
+func (c *countingStore) Version(key string) (string, error) {
+	if c.down != nil {
+		return "", c.down
+	}
+
+	return c.Store.Version(key)
+}
+
+func readAll(t *testing.T, s *Store, key string) string {
+	dr, err := s.DataReader(key)
+	require.NoError(t, err)
+	data, err := io.ReadAll(dr)
+	require.NoError(t, err)
+	require.NoError(t, dr.Close())
+
+	return string(data)
+}
+
+func TestStore_DataReader(t *testing.T) {
+	lgr, lbuf := testdata.NewTestLogger()
+	remote := &countingStore{Store: file.New(&file.Options{DataDir: t.TempDir()})}
+	require.NoError(t, remote.Store.Save(checkKey, bytes.NewBufferString("initial")))
+
+	s, err := New(&Options{Dir: t.TempDir()}, remote, lgr)
+	require.NoError(t, err)
+
+	t.Run("Download", func(t *testing.T) {
+		require.Equal(t, "initial", readAll(t, s, checkKey))
+		require.Equal(t, 1, remote.reads)
+	})
+	t.Run("Revalidated", func(t *testing.T) {
+		require.Equal(t, "initial", readAll(t, s, checkKey))
+		require.Equal(t, 1, remote.reads)
+	})
+	t.Run("Changed", func(t *testing.T) {
+		require.NoError(t, remote.Store.Save(checkKey, bytes.NewBufferString("actual content")))
+
+		require.Equal(t, "actual content", readAll(t, s, checkKey))
+		require.Equal(t, 2, remote.reads)
+	})
+	t.Run("RemoteUnavailable", func(t *testing.T) {
+		remote.down = domain.NewTransientError(errors.New("connection refused"))
+		defer func() {
+			remote.down = nil
+		}()
+
+		exists, err := s.IsExist(checkKey)
+		require.NoError(t, err)
+		require.True(t, exists)
+
+		require.Equal(t, "actual content", readAll(t, s, checkKey))
+		require.Contains(t, lbuf.String(), "remote store is unavailable, using local copy of ru.index")
+
+		_, err = s.DataReader("en.index")
+		require.EqualError(t, err, "connection refused")
+	})
+	t.Run("RemotePermanentError", func(t *testing.T) {
+		remote.down = errors.New("access denied")
+		defer func() {
+			remote.down = nil
+		}()
+
+		// Локальная копия есть, но удаленный артефакт недоступен навсегда: копию не отдаем.
+		exists, err := s.IsExist(checkKey)
+		require.EqualError(t, err, "access denied")
+		require.False(t, exists)
+
+		_, err = s.DataReader(checkKey)
+		require.EqualError(t, err, "access denied")
+	})
+	t.Run("DownloadFailed", func(t *testing.T) {
+		require.NoError(t, remote.Store.Save(checkKey, bytes.NewBufferString("newer content")))
+		defer func() {
+			remote.readErr = nil
+		}()
+
+		remote.readErr = errors.New("no such key")
+		_, err := s.DataReader(checkKey)
+		require.EqualError(t, err, "no such key")
+
+		remote.readErr = domain.NewTransientError(errors.New("connection reset"))
+		require.Equal(t, "actual content", readAll(t, s, checkKey))
+		require.Contains(t, lbuf.String(), "downloading ru.index failed, using local copy")
+	})
+	t.Run("Save", func(t *testing.T) {
+		require.NoError(t, s.Save(checkKey, bytes.NewBufferString("saved")))
+
+		reads := remote.reads
+		require.Equal(t, "saved", readAll(t, s, checkKey))
+		require.Equal(t, reads, remote.reads)
+	})
+}
+
+func TestStore_Unavailable(t *testing.T) {
+	lgr, _ := testdata.NewTestLogger()
+	dir := t.TempDir()
+	remote := file.New(&file.Options{DataDir: t.TempDir()})
+	require.NoError(t, remote.Save(checkKey, bytes.NewBufferString("last known good")))
+
+	warm, err := New(&Options{Dir: dir}, remote, lgr)
+	require.NoError(t, err)
+	require.Equal(t, "last known good", readAll(t, warm, checkKey))
+
+	cold, err := New(&Options{Dir: dir}, Unavailable(errors.New("no route to host")), lgr)
+	require.NoError(t, err)
+	require.Equal(t, "last known good", readAll(t, cold, checkKey))
+	require.EqualError(t, cold.Save(checkKey, bytes.NewBufferString("x")), "no route to host")
+}
+
+// generationStore - удаленное хранилище одного поколения.
+type generationStore struct {
+	countingStore
+	generation string
+}
+
+func (g *generationStore) Generation() string {
+	return g.generation
+}
+
+func newGenerationStore(t *testing.T, generation string, artifacts map[string]string) *generationStore {
+	res := &generationStore{
+		countingStore: countingStore{Store: file.New(&file.Options{DataDir: t.TempDir()})},
+		generation:    generation,
+	}
+	for k, v := range artifacts {
+		require.NoError(t, res.Store.Save(k, bytes.NewBufferString(v)))
+	}
+
+	return res
+}
+
+func TestStore_Generations(t *testing.T) {
+	lgr, _ := testdata.NewTestLogger()
+	dir := t.TempDir()
+
+	first, err := New(&Options{Dir: dir}, newGenerationStore(t, "gen-1", map[string]string{
+		"ru.index": "ru-1",
+		"en.index": "en-1",
+	}), lgr)
+	require.NoError(t, err)
+	require.Equal(t, "ru-1", readAll(t, first, "ru.index"))
+	require.Equal(t, "en-1", readAll(t, first, "en.index"))
+	require.NoError(t, first.Complete())
+
+	// Хранилище отказало посреди скачивания второго поколения.
+	remote := newGenerationStore(t, "gen-2", map[string]string{
+		"ru.index": "ru-2",
+		"en.index": "en-2",
+	})
+	second, err := New(&Options{Dir: dir}, remote, lgr)
+	require.NoError(t, err)
+	require.Equal(t, "ru-2", readAll(t, second, "ru.index"))
+
+	remote.down = errors.New("connection reset")
+	_, err = second.DataReader("en.index")
+	require.EqualError(t, err, "connection reset")
+
+	// Без удаленного хранилища читается последнее полностью скачанное поколение, без примеси второго.
+	offline, err := New(&Options{Dir: dir}, Unavailable(errors.New("no route to host")), lgr)
+	require.NoError(t, err)
+	require.Equal(t, "gen-1", offline.Generation())
+	require.Equal(t, "ru-1", readAll(t, offline, "ru.index"))
+	require.Equal(t, "en-1", readAll(t, offline, "en.index"))
+	require.NoError(t, offline.Complete())
+
+	// Второе поколение докачано: оно становится последним полным, копии первого удаляются.
+	remote.down = nil
+	require.Equal(t, "en-2", readAll(t, second, "en.index"))
+	require.NoError(t, second.Complete())
+	require.NoDirExists(t, filepath.Join(dir, generationsDir, "gen-1"))
+
+	offline, err = New(&Options{Dir: dir}, Unavailable(errors.New("no route to host")), lgr)
+	require.NoError(t, err)
+	require.Equal(t, "gen-2", offline.Generation())
+	require.Equal(t, "en-2", readAll(t, offline, "en.index"))
+}
+
+func TestStore_DataReader_Corrupted(t *testing.T) {
+	lgr, _ := testdata.NewTestLogger()
+	dir := t.TempDir()
+	remote := &countingStore{Store: file.New(&file.Options{DataDir: t.TempDir()})}
+	require.NoError(t, remote.Store.Save(checkKey, bytes.NewBufferString("original")))
+
+	s, err := New(&Options{Dir: dir}, remote, lgr)
+	require.NoError(t, err)
+	require.Equal(t, "original", readAll(t, s, checkKey))
+
+	// Размер тот же, содержимое - другое.
+	require.NoError(t, os.WriteFile(filepath.Join(dir, checkKey), []byte("0riginal"), 0644))
+
+	// Испорченная копия скачивается заново, если хранилище доступно.
+	require.Equal(t, "original", readAll(t, s, checkKey))
+	require.Equal(t, 2, remote.reads)
+
+	require.NoError(t, os.WriteFile(filepath.Join(dir, checkKey), []byte("0riginal"), 0644))
+
+	offline, err := New(&Options{Dir: dir}, Unavailable(errors.New("no route to host")), lgr)
+	require.NoError(t, err)
+	_, err = offline.DataReader(checkKey)
+	require.ErrorContains(t, err, "local copy of ru.index is corrupted")
+}
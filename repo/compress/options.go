@@ -0,0 +1,6 @@
+package compress
+
+type Options struct {
+	// Codec - алгоритм сжатия сохраняемых артефактов: "zstd", "gzip" или "none" (по умолчанию).
+	Codec string
+}
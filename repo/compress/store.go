@@ -0,0 +1,252 @@
+package compress
+
+import (
+	"compress/gzip"
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/pkg/errors"
+
+	"github.com/cannonflesh/wordspell/internal/stream"
+)
+
+const (
+	CodecNone = "none"
+	CodecGzip = "gzip"
+	CodecZstd = "zstd"
+)
+
+type DataStore interface {
+	DataReader(key string) (io.ReadCloser, error)
+	IsExist(key string) (bool, error)
+	Save(key string, content io.Reader) error
+}
+
+type generational interface {
+	Generation() string
+}
+
+// codec описывает алгоритм сжатия и суффикс, который он добавляет к ключу артефакта.
+type codec struct {
+	name   string
+	suffix string
+	writer func(w io.Writer) (io.WriteCloser, error)
+	reader func(r io.Reader) (io.ReadCloser, error)
+}
+
+var codecs = []*codec{
+	{
+		name:   CodecZstd,
+		suffix: ".zst",
+		writer: func(w io.Writer) (io.WriteCloser, error) {
+			return zstd.NewWriter(w)
+		},
+		reader: func(r io.Reader) (io.ReadCloser, error) {
+			zr, err := zstd.NewReader(r)
+			if err != nil {
+				return nil, err
+			}
+
+			return zr.IOReadCloser(), nil
+		},
+	},
+	{
+		name:   CodecGzip,
+		suffix: ".gz",
+		writer: func(w io.Writer) (io.WriteCloser, error) {
+			return gzip.NewWriter(w), nil
+		},
+		reader: func(r io.Reader) (io.ReadCloser, error) {
+			return gzip.NewReader(r)
+		},
+	},
+	{
+		name: CodecNone,
+	},
+}
+
+// Store - декоратор DataStore, сжимающий артефакты при сохранении и распаковывающий при чтении.
+//
+// Алгоритм сжатия определяется суффиксом ключа: ru.index.zst, ru.index.gz.
+// Если алгоритм, которым сохранено поколение, известен (из манифеста, см. ForCodec), артефакт открывается сразу
+// под ключом с его суффиксом. Иначе артефакт ищется сначала в формате, выбранном в Options, затем без сжатия,
+// затем в остальных форматах (zstd, gzip), так что несжатые артефакты, записанные раньше, читаются как прежде.
+type Store struct {
+	inner DataStore
+	codec *codec
+	// exact - все артефакты сохранены алгоритмом codec, искать их в других форматах не нужно.
+	exact bool
+}
+
+func New(opt *Options, inner DataStore) (*Store, error) {
+	c, err := findCodec(opt.Codec)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		inner: inner,
+		codec: c,
+	}, nil
+}
+
+// ForCodec отдает Store, читающий артефакты, сохраненные алгоритмом name (manifest.BuildOptions.Compression),
+// без перебора суффиксов. Пустое name - артефакты не сжаты.
+func (s *Store) ForCodec(name string) (*Store, error) {
+	c, err := findCodec(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Store{
+		inner: s.inner,
+		codec: c,
+		exact: true,
+	}, nil
+}
+
+// Raw отдает хранилище, в котором артефакты лежат как есть, без сжатия.
+// Через него пишется и читается манифест: по манифесту определяется алгоритм сжатия остальных артефактов.
+func (s *Store) Raw() DataStore {
+	return s.inner
+}
+
+func findCodec(name string) (*codec, error) {
+	if name == "" {
+		name = CodecNone
+	}
+
+	for _, c := range codecs {
+		if c.name == name {
+			return c, nil
+		}
+	}
+
+	return nil, errors.New("unknown compression codec: " + name)
+}
+
+// Codec отдает алгоритм сжатия, которым сохраняются артефакты.
+func (s *Store) Codec() string {
+	return s.codec.name
+}
+
+// Generation отдает поколение артефактов хранилища, если оно к нему привязано.
+func (s *Store) Generation() string {
+	if gs, ok := s.inner.(generational); ok {
+		return gs.Generation()
+	}
+
+	return ""
+}
+
+///// Имплементация интерфейса index.dataStore /////
+
+// DataReader отдает распакованные данные артефакта.
+func (s *Store) DataReader(key string) (io.ReadCloser, error) {
+	c := s.codec
+	if !s.exact {
+		var err error
+		if c, err = s.find(key); err != nil {
+			return nil, err
+		}
+	}
+	if c == nil {
+		// Не нашлось ни в одном формате - пусть хранилище само сообщит об ошибке.
+		return s.inner.DataReader(key)
+	}
+
+	rc, err := s.inner.DataReader(key + c.suffix)
+	if err != nil || c.reader == nil {
+		return rc, err
+	}
+
+	dr, err := c.reader(rc)
+	if err != nil {
+		_ = rc.Close()
+
+		return nil, errors.Wrap(err, "decompressing "+key+c.suffix)
+	}
+
+	return &readCloser{
+		Reader: dr,
+		close: func() error {
+			_ = dr.Close()
+
+			return rc.Close()
+		},
+	}, nil
+}
+
+// IsExist сообщает, есть ли артефакт хоть в каком-нибудь формате (или в формате ForCodec).
+func (s *Store) IsExist(key string) (bool, error) {
+	c, err := s.find(key)
+
+	return c != nil, err
+}
+
+// Save сжимает данные по мере чтения и сохраняет их под ключом с суффиксом алгоритма.
+func (s *Store) Save(key string, content io.Reader) error {
+	if s.codec.writer == nil {
+		return s.inner.Save(key, content)
+	}
+
+	return stream.Save(s.inner, key+s.codec.suffix, func(w io.Writer) error {
+		cw, err := s.codec.writer(w)
+		if err != nil {
+			return errors.WithStack(err)
+		}
+
+		if _, err = io.Copy(cw, content); err != nil {
+			_ = cw.Close()
+
+			return errors.WithStack(err)
+		}
+
+		return errors.WithStack(cw.Close())
+	})
+}
+
+// find отдает формат, в котором хранится артефакт, или nil, если артефакта нет.
+func (s *Store) find(key string) (*codec, error) {
+	for _, c := range s.lookupOrder() {
+		exists, err := s.inner.IsExist(key + c.suffix)
+		if err != nil {
+			return nil, err
+		}
+		if exists {
+			return c, nil
+		}
+	}
+
+	return nil, nil
+}
+
+func (s *Store) lookupOrder() []*codec {
+	if s.exact {
+		return []*codec{s.codec}
+	}
+
+	res := make([]*codec, 0, len(codecs))
+	res = append(res, s.codec)
+
+	if s.codec.name != CodecNone {
+		res = append(res, codecs[len(codecs)-1])
+	}
+
+	for _, c := range codecs {
+		if c.name != s.codec.name && c.name != CodecNone {
+			res = append(res, c)
+		}
+	}
+
+	return res
+}
+
+type readCloser struct {
+	io.Reader
+	close func() error
+}
+
+func (rc *readCloser) Close() error {
+	return rc.close()
+}
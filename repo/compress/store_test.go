@@ -0,0 +1,132 @@
+package compress
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cannonflesh/wordspell/repo/file"
+)
+
+const checkKey = "ru.index"
+
+func readAll(t *testing.T, s DataStore, key string) string {
+	dr, err := s.DataReader(key)
+	require.NoError(t, err)
+	data, err := io.ReadAll(dr)
+	require.NoError(t, err)
+	require.NoError(t, dr.Close())
+
+	return string(data)
+}
+
+func TestStore(t *testing.T) {
+	content := strings.Repeat("слово\t12\n", 1000)
+
+	for _, tc := range []struct {
+		codec, storedKey string
+	}{
+		{codec: "", storedKey: "ru.index"},
+		{codec: CodecNone, storedKey: "ru.index"},
+		{codec: CodecGzip, storedKey: "ru.index.gz"},
+		{codec: CodecZstd, storedKey: "ru.index.zst"},
+	} {
+		t.Run("Codec_"+tc.codec, func(t *testing.T) {
+			inner := file.New(&file.Options{DataDir: t.TempDir()})
+			s, err := New(&Options{Codec: tc.codec}, inner)
+			require.NoError(t, err)
+
+			require.NoError(t, s.Save(checkKey, bytes.NewBufferString(content)))
+
+			exists, err := inner.IsExist(tc.storedKey)
+			require.NoError(t, err)
+			require.True(t, exists)
+
+			stored := readAll(t, inner, tc.storedKey)
+			if tc.storedKey != checkKey {
+				require.Less(t, len(stored), len(content)/10)
+			}
+
+			exists, err = s.IsExist(checkKey)
+			require.NoError(t, err)
+			require.True(t, exists)
+			require.Equal(t, content, readAll(t, s, checkKey))
+		})
+	}
+}
+
+func TestStore_Compatibility(t *testing.T) {
+	inner := file.New(&file.Options{DataDir: t.TempDir()})
+	require.NoError(t, inner.Save(checkKey, bytes.NewBufferString("uncompressed")))
+
+	zstdStore, err := New(&Options{Codec: CodecZstd}, inner)
+	require.NoError(t, err)
+	require.Equal(t, "uncompressed", readAll(t, zstdStore, checkKey))
+
+	gzipStore, err := New(&Options{Codec: CodecGzip}, inner)
+	require.NoError(t, err)
+	require.NoError(t, gzipStore.Save("en.index", bytes.NewBufferString("gzipped")))
+
+	// Формат определяется по суффиксу ключа, а не по настройкам читающей стороны.
+	require.Equal(t, "gzipped", readAll(t, zstdStore, "en.index"))
+
+	exists, err := zstdStore.IsExist("bloom.dat")
+	require.NoError(t, err)
+	require.False(t, exists)
+}
+
+func TestNew_UnknownCodec(t *testing.T) {
+	_, err := New(&Options{Codec: "lz4"}, nil)
+	require.EqualError(t, err, "unknown compression codec: lz4")
+}
+
+// probingStore запоминает ключи, которые проверялись через IsExist.
+type probingStore struct {
+	*file.Store
+	probed []string
+}
+
+func (p *probingStore) IsExist(key string) (bool, error) {
+	p.probed = append(p.probed, key)
+
+	return p.Store.IsExist(key)
+}
+
+func TestStore_ForCodec(t *testing.T) {
+	inner := &probingStore{Store: file.New(&file.Options{DataDir: t.TempDir()})}
+
+	zstdStore, err := New(&Options{Codec: CodecZstd}, inner)
+	require.NoError(t, err)
+	require.NoError(t, zstdStore.Save(checkKey, bytes.NewBufferString("compressed")))
+
+	// Читающая сторона настроена на gzip, но из манифеста известно, что поколение сжато zstd.
+	gzipStore, err := New(&Options{Codec: CodecGzip}, inner)
+	require.NoError(t, err)
+	s, err := gzipStore.ForCodec(CodecZstd)
+	require.NoError(t, err)
+
+	require.Equal(t, "compressed", readAll(t, s, checkKey))
+	require.Empty(t, inner.probed)
+
+	exists, err := s.IsExist(checkKey)
+	require.NoError(t, err)
+	require.True(t, exists)
+	require.Equal(t, []string{checkKey + ".zst"}, inner.probed)
+
+	// Других форматов поколение, сохраненное одним алгоритмом, не содержит.
+	require.NoError(t, inner.Save("en.index", bytes.NewBufferString("uncompressed")))
+	exists, err = s.IsExist("en.index")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	none, err := s.ForCodec("")
+	require.NoError(t, err)
+	require.Equal(t, "uncompressed", readAll(t, none, "en.index"))
+	require.Equal(t, inner, none.Raw())
+
+	_, err = s.ForCodec("lz4")
+	require.EqualError(t, err, "unknown compression codec: lz4")
+}
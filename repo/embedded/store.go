@@ -0,0 +1,55 @@
+package embedded
+
+import (
+	"io"
+	"io/fs"
+
+	"github.com/pkg/errors"
+
+	"github.com/cannonflesh/wordspell/domain"
+)
+
+// Store - хранилище данных только для чтения поверх fs.FS.
+// Позволяет вкомпилировать небольшой словарь в бинарник (embed.FS) для утилит и интеграционных тестов.
+type Store struct {
+	fsys fs.FS
+}
+
+// New конструктор хранилища поверх fsys. Ключи - пути внутри fsys,
+// для вложенного каталога достаточно передать fs.Sub(fsys, dir).
+func New(fsys fs.FS) *Store {
+	return &Store{
+		fsys: fsys,
+	}
+}
+
+///// Имплементация интерфейса index.dataStore /////
+
+func (s *Store) IsExist(key string) (bool, error) {
+	st, err := fs.Stat(s.fsys, key)
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, errors.WithStack(err)
+	}
+	if !st.Mode().IsRegular() {
+		return false, errors.New("this is not regular file")
+	}
+
+	return true, nil
+}
+
+func (s *Store) DataReader(key string) (io.ReadCloser, error) {
+	fh, err := s.fsys.Open(key)
+	if err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return fh, nil
+}
+
+// Save всегда отказывает: данные вкомпилированы в бинарник.
+func (s *Store) Save(key string, _ io.Reader) error {
+	return &domain.ReadOnlyError{Key: key}
+}
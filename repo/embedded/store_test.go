@@ -0,0 +1,52 @@
+package embedded
+
+import (
+	"bytes"
+	"io"
+	"testing"
+	"testing/fstest"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cannonflesh/wordspell/domain"
+	"github.com/cannonflesh/wordspell/testdata"
+)
+
+func TestStore(t *testing.T) {
+	store := New(testdata.Indexes)
+
+	exists, err := store.IsExist("ru.index")
+	require.NoError(t, err)
+	require.True(t, exists)
+
+	exists, err = store.IsExist("bloom.dat")
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	dr, err := store.DataReader("trademark.index")
+	require.NoError(t, err)
+	data, err := io.ReadAll(dr)
+	require.NoError(t, err)
+	require.NoError(t, dr.Close())
+	require.NotEmpty(t, data)
+
+	_, err = store.DataReader("bloom.dat")
+	require.Error(t, err)
+}
+
+func TestStore_IsExist_Dir(t *testing.T) {
+	store := New(fstest.MapFS{
+		"ru/index": &fstest.MapFile{Data: []byte("слово\t1")},
+	})
+
+	_, err := store.IsExist("ru")
+	require.EqualError(t, err, "this is not regular file")
+}
+
+func TestStore_Save(t *testing.T) {
+	store := New(testdata.Indexes)
+
+	err := store.Save("ru.index", bytes.NewBufferString("слово\t1"))
+	require.True(t, domain.IsReadOnly(err))
+	require.EqualError(t, err, "store is read-only, can not save ru.index")
+}
@@ -1,13 +1,19 @@
 package file
 
 import (
+	"context"
 	"io"
 	"os"
 	"path/filepath"
+	"strconv"
 
 	"github.com/pkg/errors"
 )
 
+// fileMode - права сохраненных файлов. os.CreateTemp создает файл с правами 0600,
+// а индексы читают и другие пользователи и процессы (например, сайдкар или пользователь деплоя).
+const fileMode os.FileMode = 0o644
+
 // Store - file-based хранилище данных.
 type Store struct {
 	dir string
@@ -56,23 +62,69 @@ func (s *Store) DataReader(key string) (io.ReadCloser, error) {
 	return fh, nil
 }
 
+// Version отдает версию файла, составленную из его размера и времени изменения.
+func (s *Store) Version(key string) (string, error) {
+	res, err := os.Stat(filepath.Join(s.dir, key))
+	if err != nil {
+		return "", errors.WithStack(err)
+	}
+
+	return strconv.FormatInt(res.Size(), 16) + "-" + strconv.FormatInt(res.ModTime().UnixNano(), 16), nil
+}
+
+// Save пишет данные во временный файл и подменяет им прежний, только если поток прочитан без ошибок.
+// Так content может быть потоком (например, io.PipeReader), который оборвется на середине.
 func (s *Store) Save(key string, content io.Reader) error {
 	wh, err := s.indexFileWriteHandler(key)
 	if err != nil {
 		return err
 	}
-	defer func() {
-		_ = wh.Close()
-	}()
 
 	_, err = io.Copy(wh, content)
+	if err == nil {
+		err = wh.Chmod(fileMode)
+	}
+	if closeErr := wh.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(wh.Name())
+
+		return errors.WithStack(err)
+	}
+
+	return errors.WithStack(os.Rename(wh.Name(), filepath.Join(s.dir, key)))
+}
+
+///// Варианты с контекстом /////
+
+func (s *Store) DataReaderContext(ctx context.Context, key string) (io.ReadCloser, error) {
+	if err := ctx.Err(); err != nil {
+		return nil, errors.WithStack(err)
+	}
+
+	return s.DataReader(key)
+}
+
+func (s *Store) IsExistContext(ctx context.Context, key string) (bool, error) {
+	if err := ctx.Err(); err != nil {
+		return false, errors.WithStack(err)
+	}
+
+	return s.IsExist(key)
+}
+
+func (s *Store) SaveContext(ctx context.Context, key string, content io.Reader) error {
+	if err := ctx.Err(); err != nil {
+		return errors.WithStack(err)
+	}
 
-	return errors.WithStack(err)
+	return s.Save(key, content)
 }
 
-func (s *Store) indexFileWriteHandler(key string) (io.WriteCloser, error) {
+func (s *Store) indexFileWriteHandler(key string) (*os.File, error) {
 	path := filepath.Join(s.dir, key)
-	fh, err := os.OpenFile(path, os.O_CREATE|os.O_TRUNC|os.O_WRONLY, 0666)
+	fh, err := os.CreateTemp(filepath.Dir(path), filepath.Base(path)+".tmp-*")
 	if err != nil {
 		return nil, errors.WithStack(err)
 	}
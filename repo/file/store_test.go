@@ -7,6 +7,7 @@ import (
 	"path/filepath"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 )
 
@@ -50,3 +51,37 @@ func TestStore_IsExists_Save_DataReader(t *testing.T) {
 	err = dStream.Close()
 	require.NoError(t, err)
 }
+
+func TestStore_Save_BrokenStream(t *testing.T) {
+	store := New(&Options{DataDir: t.TempDir()})
+
+	require.NoError(t, store.Save(checkDataFile, bytes.NewBufferString("initial content")))
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write([]byte("partial"))
+		_ = pw.CloseWithError(errors.New("source failed"))
+	}()
+
+	err := store.Save(checkDataFile, pr)
+	require.EqualError(t, err, "source failed")
+
+	// прежнее содержимое не повреждено.
+	dStream, err := store.DataReader(checkDataFile)
+	require.NoError(t, err)
+	data, err := io.ReadAll(dStream)
+	require.NoError(t, err)
+	require.Equal(t, "initial content", string(data))
+	require.NoError(t, dStream.Close())
+}
+
+func TestStore_Save_FileMode(t *testing.T) {
+	dir := t.TempDir()
+	store := New(&Options{DataDir: dir})
+
+	require.NoError(t, store.Save(checkDataFile, bytes.NewBufferString("content")))
+
+	st, err := os.Stat(filepath.Join(dir, checkDataFile))
+	require.NoError(t, err)
+	require.Equal(t, os.FileMode(0o644), st.Mode().Perm())
+}
@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"bytes"
+	"io"
+	"strconv"
+	"sync"
+
+	"github.com/pkg/errors"
+)
+
+// Store - хранилище данных в памяти процесса, безопасное для конкурентного использования.
+// Годится для тестов и для встраивания wordspell туда, где нет ни s3, ни диска.
+type Store struct {
+	mu       sync.RWMutex
+	data     map[string][]byte
+	versions map[string]uint64
+	counter  uint64
+}
+
+// New конструктор хранилища данных в памяти.
+func New() *Store {
+	return &Store{
+		data:     make(map[string][]byte),
+		versions: make(map[string]uint64),
+	}
+}
+
+///// Имплементация интерфейса index.dataStore /////
+
+func (s *Store) IsExist(key string) (bool, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	_, ok := s.data[key]
+
+	return ok, nil
+}
+
+// DataReader отдает ридер данных, сохраненных на момент вызова.
+// Последующие Save на уже выданный ридер не влияют.
+func (s *Store) DataReader(key string) (io.ReadCloser, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	data, ok := s.data[key]
+	if !ok {
+		return nil, errors.New("no data found: " + key)
+	}
+
+	return io.NopCloser(bytes.NewReader(data)), nil
+}
+
+// Save вычитывает content целиком и лишь затем подменяет данные:
+// если чтение оборвется с ошибкой, прежние данные останутся на месте.
+func (s *Store) Save(key string, content io.Reader) error {
+	data, err := io.ReadAll(content)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.counter++
+	s.data[key] = data
+	s.versions[key] = s.counter
+
+	return nil
+}
+
+// Version отдает номер сохранения данных, меняющийся при каждом Save.
+func (s *Store) Version(key string) (string, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	v, ok := s.versions[key]
+	if !ok {
+		return "", errors.New("no data found: " + key)
+	}
+
+	return strconv.FormatUint(v, 10), nil
+}
+
+// Keys отдает ключи всех сохраненных данных.
+func (s *Store) Keys() []string {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	res := make([]string, 0, len(s.data))
+	for k := range s.data {
+		res = append(res, k)
+	}
+
+	return res
+}
@@ -0,0 +1,96 @@
+package memory
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+
+	"github.com/pkg/errors"
+	"github.com/stretchr/testify/require"
+)
+
+const checkKey = "ru.index"
+
+func readAll(t *testing.T, s *Store, key string) string {
+	dr, err := s.DataReader(key)
+	require.NoError(t, err)
+	data, err := io.ReadAll(dr)
+	require.NoError(t, err)
+	require.NoError(t, dr.Close())
+
+	return string(data)
+}
+
+func TestStore_IsExists_Save_DataReader(t *testing.T) {
+	store := New()
+
+	exists, err := store.IsExist(checkKey)
+	require.NoError(t, err)
+	require.False(t, exists)
+
+	_, err = store.DataReader(checkKey)
+	require.EqualError(t, err, "no data found: ru.index")
+
+	require.NoError(t, store.Save(checkKey, bytes.NewBufferString("initial")))
+	v1, err := store.Version(checkKey)
+	require.NoError(t, err)
+
+	dr, err := store.DataReader(checkKey)
+	require.NoError(t, err)
+
+	require.NoError(t, store.Save(checkKey, bytes.NewBufferString("actual")))
+	v2, err := store.Version(checkKey)
+	require.NoError(t, err)
+	require.NotEqual(t, v1, v2)
+
+	// Ридер, выданный до Save, отдает прежние данные.
+	data, err := io.ReadAll(dr)
+	require.NoError(t, err)
+	require.Equal(t, "initial", string(data))
+
+	require.Equal(t, "actual", readAll(t, store, checkKey))
+	require.Equal(t, []string{checkKey}, store.Keys())
+}
+
+func TestStore_Save_BrokenStream(t *testing.T) {
+	store := New()
+	require.NoError(t, store.Save(checkKey, bytes.NewBufferString("initial")))
+
+	pr, pw := io.Pipe()
+	go func() {
+		_, _ = pw.Write([]byte("partial"))
+		_ = pw.CloseWithError(errors.New("connection reset"))
+	}()
+
+	require.ErrorContains(t, store.Save(checkKey, pr), "connection reset")
+	require.Equal(t, "initial", readAll(t, store, checkKey))
+}
+
+func TestStore_Concurrent(t *testing.T) {
+	store := New()
+
+	wg := sync.WaitGroup{}
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+
+			key := fmt.Sprintf("key-%d", i%4)
+			content := fmt.Sprintf("content-%d", i)
+			for j := 0; j < 100; j++ {
+				require.NoError(t, store.Save(key, bytes.NewBufferString(content)))
+
+				exists, err := store.IsExist(key)
+				require.NoError(t, err)
+				require.True(t, exists)
+
+				require.Contains(t, readAll(t, store, key), "content-")
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	require.Len(t, store.Keys(), 4)
+}
@@ -0,0 +1,405 @@
+package s3
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"path"
+	"sort"
+	"strings"
+
+	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/pkg/errors"
+
+	"github.com/cannonflesh/wordspell/domain"
+)
+
+/* Поколения артефактов.
+ *
+ * Если задан Options.Name, артефакты каждой сборки хранятся под собственным префиксом:
+ * <Name>/<generation>/ru.index, <Name>/<generation>/bloom.dat и т.д.
+ * Какое поколение сейчас актуально, сообщает маленький объект-указатель <Name>/CURRENT.
+ * Билдер пишет все артефакты в новое поколение и лишь потом переключает указатель (Publish),
+ * так что читающая сторона никогда не видит наполовину опубликованный набор.
+ *
+ * Указатель переписывается условной записью (If-Match по ETag прочитанной версии, If-None-Match для первой публикации):
+ * если между чтением и записью его изменил другой билдер или откат, запись не пройдет, и обновление не потеряется.
+ * Publish в этом случае перечитывает указатель и повторяет попытку, Rollback - возвращает ErrPointerChanged.
+ */
+
+const (
+	pointerKey       = "CURRENT"
+	defaultRetention = 3
+	deleteBatchLen   = 1000
+	// pointerUpdateAttempts - сколько раз Publish перечитывает указатель, измененный конкурентом.
+	pointerUpdateAttempts = 5
+)
+
+// ErrPointerChanged - указатель изменился между чтением и записью.
+var ErrPointerChanged = errors.New("generation pointer was changed concurrently")
+
+// pointer - содержимое объекта-указателя.
+// History - ранее опубликованные поколения, от новых к старым, они доступны для отката.
+type pointer struct {
+	Current string   `json:"current"`
+	History []string `json:"history,omitempty"`
+}
+
+// WithGeneration отдает Store, который читает и пишет артефакты поколения generation.
+func (r *Store) WithGeneration(generation string) *Store {
+	res := *r
+	res.generation = generation
+
+	return &res
+}
+
+// Generation отдает поколение, с которым работает Store.
+func (r *Store) Generation() string {
+	return r.generation
+}
+
+// Current отдает Store, работающий с текущим опубликованным поколением.
+// Если Options.Name не задан, поколений нет, и возвращается тот же Store.
+func (r *Store) Current() (*Store, error) {
+	if r.opts.Name == "" {
+		return r, nil
+	}
+
+	p, _, err := r.readPointer(context.Background())
+	if err != nil {
+		return nil, err
+	}
+	if p == nil {
+		return nil, errors.New("no published generation found: " + r.opts.Name)
+	}
+
+	return r.WithGeneration(p.Current), nil
+}
+
+// Publish переключает указатель на поколение generation.
+// Вызывать его следует только после того, как все артефакты поколения успешно сохранены.
+func (r *Store) Publish(generation string) error {
+	if r.opts.Name == "" {
+		return nil
+	}
+
+	var err error
+	for i := 0; i < pointerUpdateAttempts; i++ {
+		if err = r.publish(generation); !errors.Is(err, ErrPointerChanged) {
+			return err
+		}
+	}
+
+	return err
+}
+
+func (r *Store) publish(generation string) error {
+	p, etag, err := r.readPointer(context.Background())
+	if err != nil {
+		return err
+	}
+	if p == nil {
+		p = &pointer{}
+	}
+
+	if p.Current != "" && p.Current != generation {
+		p.History = append([]string{p.Current}, p.History...)
+	}
+	p.Current = generation
+
+	if keep := r.retention() - 1; len(p.History) > keep {
+		p.History = p.History[:keep]
+	}
+
+	return r.writePointer(context.Background(), p, etag)
+}
+
+// Rollback возвращает указатель на одно из ранее опубликованных поколений.
+// Если generation пуст, - на предыдущее. Отдает поколение, ставшее текущим.
+// Если указатель тем временем переключили, отдает ErrPointerChanged: откат на "предыдущее" поколение
+// после чужой публикации значил бы уже другое, так что повторять его решает вызывающая сторона.
+func (r *Store) Rollback(generation string) (string, error) {
+	if r.opts.Name == "" {
+		return "", errors.New("generations are not enabled, set the store name")
+	}
+
+	p, etag, err := r.readPointer(context.Background())
+	if err != nil {
+		return "", err
+	}
+	if p == nil || len(p.History) == 0 {
+		return "", errors.New("no previous generation to roll back to")
+	}
+
+	target := 0
+	if generation != "" {
+		target = -1
+		for i, g := range p.History {
+			if g == generation {
+				target = i
+
+				break
+			}
+		}
+		if target < 0 {
+			return "", errors.New("generation not found in the publication history: " + generation)
+		}
+	}
+
+	p.Current = p.History[target]
+	p.History = p.History[target+1:]
+
+	if err = r.writePointer(context.Background(), p, etag); err != nil {
+		return "", err
+	}
+
+	return p.Current, nil
+}
+
+// Generations отдает все поколения, найденные в бакете, в порядке создания.
+func (r *Store) Generations() ([]string, error) {
+	if r.opts.Name == "" {
+		return nil, nil
+	}
+
+	var res []string
+
+	pages := s3.NewListObjectsV2Paginator(r.cli, &s3.ListObjectsV2Input{
+		Bucket:    aws.String(r.opts.Bucket),
+		Prefix:    aws.String(r.opts.Name + "/"),
+		Delimiter: aws.String("/"),
+	})
+	for pages.HasMorePages() {
+		ctx, cancel := r.operationContext(context.Background())
+		page, err := pages.NextPage(ctx)
+		cancel()
+		if err != nil {
+			return nil, classify(errors.WithStack(err))
+		}
+
+		for _, cp := range page.CommonPrefixes {
+			g := strings.TrimSuffix(strings.TrimPrefix(aws.ToString(cp.Prefix), r.opts.Name+"/"), "/")
+			if g != "" {
+				res = append(res, g)
+			}
+		}
+	}
+
+	sort.Strings(res)
+
+	return res, nil
+}
+
+// Cleanup удаляет все поколения, кроме текущего и Options.Retention последних опубликованных,
+// независимо от их возраста: в том числе поколения, с которых откатились.
+// Поколение, которое в это время пишет другой билдер, тоже будет удалено,
+// поэтому сборки в одно хранилище не должны идти одновременно.
+func (r *Store) Cleanup() ([]string, error) {
+	if r.opts.Name == "" {
+		return nil, nil
+	}
+
+	p, _, err := r.readPointer(context.Background())
+	if err != nil || p == nil {
+		return nil, err
+	}
+
+	keep := make(map[string]bool, len(p.History)+1)
+	keep[p.Current] = true
+	for _, g := range p.History {
+		keep[g] = true
+	}
+
+	gens, err := r.Generations()
+	if err != nil {
+		return nil, err
+	}
+
+	var removed []string
+	for _, g := range gens {
+		if keep[g] {
+			continue
+		}
+
+		if err = r.deleteGeneration(g); err != nil {
+			return removed, err
+		}
+		removed = append(removed, g)
+	}
+
+	return removed, nil
+}
+
+func (r *Store) retention() int {
+	if r.opts.Retention > 0 {
+		return r.opts.Retention
+	}
+
+	return defaultRetention
+}
+
+func (r *Store) objectKey(key string) string {
+	if r.opts.Name == "" || r.generation == "" {
+		return key
+	}
+
+	return path.Join(r.opts.Name, r.generation, key)
+}
+
+func (r *Store) pointerObjectKey() string {
+	return path.Join(r.opts.Name, pointerKey)
+}
+
+// readPointer читает указатель и его ETag. Если указателя нет - nil и пустой ETag.
+func (r *Store) readPointer(ctx context.Context) (*pointer, string, error) {
+	key := r.pointerObjectKey()
+
+	ctx, cancel := r.operationContext(ctx)
+	defer cancel()
+
+	s3o, err := r.cli.GetObject(
+		ctx,
+		&s3.GetObjectInput{
+			Bucket: aws.String(r.opts.Bucket),
+			Key:    aws.String(key),
+		},
+	)
+	if err != nil {
+		var noKey *types.NoSuchKey
+		if errors.As(err, &noKey) {
+			return nil, "", nil
+		}
+
+		return nil, "", classify(errors.Wrap(err, "key: "+key))
+	}
+	defer func() {
+		_ = s3o.Body.Close()
+	}()
+
+	res := &pointer{}
+	if err = json.NewDecoder(s3o.Body).Decode(res); err != nil {
+		return nil, "", classify(errors.Wrap(err, "decoding generation pointer"))
+	}
+	if res.Current == "" {
+		return nil, "", errors.New("generation pointer is empty: " + key)
+	}
+
+	return res, aws.ToString(s3o.ETag), nil
+}
+
+// writePointer записывает указатель, только если он не изменился с тех пор, как был прочитан с ETag etag.
+// Пустой etag - указателя еще не было, и его не должно появиться.
+func (r *Store) writePointer(ctx context.Context, p *pointer, etag string) error {
+	data, err := json.Marshal(p)
+	if err != nil {
+		return errors.WithStack(err)
+	}
+
+	ctx, cancel := r.operationContext(ctx)
+	defer cancel()
+
+	in := &s3.PutObjectInput{
+		Bucket: aws.String(r.opts.Bucket),
+		Key:    aws.String(r.pointerObjectKey()),
+		Body:   bytes.NewReader(data),
+	}
+	if etag != "" {
+		in.IfMatch = aws.String(etag)
+	} else {
+		in.IfNoneMatch = aws.String("*")
+	}
+
+	_, err = r.cli.PutObject(ctx, in)
+	if isConditionFailed(err) {
+		return errors.WithStack(ErrPointerChanged)
+	}
+
+	return classify(errors.WithStack(err))
+}
+
+// isConditionFailed - условная запись не прошла: объект изменился или уже существует.
+func isConditionFailed(err error) bool {
+	var apiError smithy.APIError
+	if !errors.As(err, &apiError) {
+		return false
+	}
+
+	switch apiError.ErrorCode() {
+	case "PreconditionFailed", "ConditionalRequestConflict":
+		return true
+	}
+
+	return false
+}
+
+func (r *Store) deleteGeneration(generation string) error {
+	ctx, cancel := r.operationContext(context.Background())
+	defer cancel()
+
+	pages := s3.NewListObjectsV2Paginator(r.cli, &s3.ListObjectsV2Input{
+		Bucket: aws.String(r.opts.Bucket),
+		Prefix: aws.String(path.Join(r.opts.Name, generation) + "/"),
+	})
+
+	var ids []types.ObjectIdentifier
+	for pages.HasMorePages() {
+		page, err := pages.NextPage(ctx)
+		if err != nil {
+			return classify(errors.WithStack(err))
+		}
+
+		for _, obj := range page.Contents {
+			ids = append(ids, types.ObjectIdentifier{Key: obj.Key})
+		}
+	}
+
+	for start := 0; start < len(ids); start += deleteBatchLen {
+		end := start + deleteBatchLen
+		if end > len(ids) {
+			end = len(ids)
+		}
+
+		out, err := r.cli.DeleteObjects(ctx, &s3.DeleteObjectsInput{
+			Bucket: aws.String(r.opts.Bucket),
+			Delete: &types.Delete{
+				Objects: ids[start:end],
+				Quiet:   aws.Bool(true),
+			},
+		})
+		if err != nil {
+			return classify(errors.Wrap(err, "deleting generation "+generation))
+		}
+		// В режиме Quiet ответ перечисляет только неудаленные ключи, а сам запрос при этом успешен.
+		if err = deleteFailure(generation, out.Errors); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// deleteFailure отдает ошибку первого ключа, который DeleteObjects не удалил, или nil, если удалены все.
+// Коды ошибок по ключам классифицируются так же, как ошибки запросов, временной считается и внутренняя ошибка S3.
+func deleteFailure(generation string, failed []types.Error) error {
+	if len(failed) == 0 {
+		return nil
+	}
+
+	first := failed[0]
+	apiErr := &smithy.GenericAPIError{Code: aws.ToString(first.Code), Message: aws.ToString(first.Message)}
+	err := errors.Wrapf(
+		apiErr,
+		"deleting generation %s: %d objects not deleted, first: %s",
+		generation, len(failed), aws.ToString(first.Key),
+	)
+
+	switch apiErr.Code {
+	case "InternalError", "ServiceUnavailable":
+		return domain.NewTransientError(err)
+	}
+
+	return classify(err)
+}
@@ -1,7 +1,15 @@
 package s3
 
+import "time"
+
 // Options опции.
 type Options struct {
 	Bucket string
-	Name   string
+	// Name - префикс поколений индексов в бакете: <Name>/<generation>/ru.index.
+	// Если не задан, артефакты хранятся в корне бакета без поколений.
+	Name string
+	// Retention - сколько последних опубликованных поколений хранить в бакете.
+	Retention int
+	// OperationTimeout ограничивает время одной операции с хранилищем, включая чтение данных.
+	OperationTimeout time.Duration
 }
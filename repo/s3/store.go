@@ -3,37 +3,49 @@ package s3
 import (
 	"context"
 	"io"
+	"time"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/feature/s3/manager"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
 	"github.com/aws/aws-sdk-go-v2/service/s3/types"
 	"github.com/aws/smithy-go"
 	"github.com/pkg/errors"
+
+	"github.com/cannonflesh/wordspell/domain"
 )
 
+const defaultOperationTimeout = 10 * time.Minute
+
 // Store репозиторий s3 хранилища.
 type Store struct {
 	cli      *s3.Client
 	uploader *manager.Uploader
 	opts     Options
+
+	// generation - поколение, с артефактами которого работает Store, см. WithGeneration.
+	generation string
 }
 
 // NewStore вернет новый инстанс репозитория s3 хранилища.
 func NewStore(cli *s3.Client, opts Options) (*Store, error) {
-	_, err := cli.HeadBucket(context.Background(),
+	r := &Store{
+		cli:      cli,
+		uploader: manager.NewUploader(cli),
+		opts:     opts,
+	}
+
+	ctx, cancel := r.operationContext(context.Background())
+	defer cancel()
+
+	_, err := cli.HeadBucket(ctx,
 		&s3.HeadBucketInput{
 			Bucket: aws.String(opts.Bucket),
 		},
 	)
 	if err != nil {
-		return nil, errors.WithStack(err)
-	}
-
-	r := &Store{
-		cli:      cli,
-		uploader: manager.NewUploader(cli),
-		opts:     opts,
+		return nil, classify(errors.WithStack(err))
 	}
 
 	return r, nil
@@ -43,7 +55,62 @@ func NewStore(cli *s3.Client, opts Options) (*Store, error) {
 
 // DataReader отдает io.ReadCloser, ответственность за закрытие - на вызывающей стороне.
 func (r *Store) DataReader(key string) (io.ReadCloser, error) {
-	ctx := context.Background()
+	return r.DataReaderContext(context.Background(), key)
+}
+
+// IsExist возвращает true или false, когда файл существует или не существует соответственно.
+// В случае возникновения ошибки, не связанной с существованием файла, возвращается ошибка, в противном случае nil.
+func (r *Store) IsExist(key string) (bool, error) {
+	return r.IsExistContext(context.Background(), key)
+}
+
+// Save сохраняет файл в s3, возвращает ошибку при её возникновени, в противном случае nil.
+// Ответственность за закрытие переданного ридера - на вызывающей стороне, тут это просто ридер.
+// Длина данных заранее не нужна: uploader загружает поток частями (multipart upload),
+// и в памяти одновременно находятся лишь несколько частей.
+func (r *Store) Save(key string, content io.Reader) error {
+	return r.SaveContext(context.Background(), key, content)
+}
+
+///// Варианты с контекстом /////
+
+// DataReaderContext - DataReader с контекстом.
+// Options.OperationTimeout ограничивает и запрос, и чтение данных, вплоть до закрытия ридера.
+func (r *Store) DataReaderContext(ctx context.Context, key string) (io.ReadCloser, error) {
+	return r.objectReader(ctx, r.objectKey(key))
+}
+
+// IsExistContext - IsExist с контекстом.
+func (r *Store) IsExistContext(ctx context.Context, key string) (bool, error) {
+	return r.objectExists(ctx, r.objectKey(key))
+}
+
+// SaveContext - Save с контекстом.
+func (r *Store) SaveContext(ctx context.Context, key string, content io.Reader) error {
+	return r.saveObject(ctx, r.objectKey(key), content)
+}
+
+// Version отдает ETag артефакта, не скачивая его.
+func (r *Store) Version(key string) (string, error) {
+	ctx, cancel := r.operationContext(context.Background())
+	defer cancel()
+
+	s3o, err := r.cli.HeadObject(
+		ctx,
+		&s3.HeadObjectInput{
+			Bucket: aws.String(r.opts.Bucket),
+			Key:    aws.String(r.objectKey(key)),
+		},
+	)
+	if err != nil {
+		return "", classify(errors.Wrap(err, "key: "+key))
+	}
+
+	return aws.ToString(s3o.ETag), nil
+}
+
+func (r *Store) objectReader(ctx context.Context, key string) (io.ReadCloser, error) {
+	ctx, cancel := r.operationContext(ctx)
 
 	s3o, err := r.cli.GetObject(
 		ctx,
@@ -53,17 +120,23 @@ func (r *Store) DataReader(key string) (io.ReadCloser, error) {
 		},
 	)
 	if err != nil {
-		return nil, errors.WithStack(err)
+		cancel()
+
+		return nil, classify(errors.WithStack(err))
 	}
 
-	return s3o.Body, nil
+	return &objectBody{
+		ReadCloser: s3o.Body,
+		cancel:     cancel,
+	}, nil
 }
 
-// IsExist возвращает true или false, когда файл существует или не существует соответственно.
-// В случае возникновения ошибки, не связанной с существованием файла, возвращается ошибка, в противном случае nil.
-func (r *Store) IsExist(key string) (bool, error) {
+func (r *Store) objectExists(ctx context.Context, key string) (bool, error) {
+	ctx, cancel := r.operationContext(ctx)
+	defer cancel()
+
 	_, err := r.cli.HeadObject(
-		context.Background(),
+		ctx,
 		&s3.HeadObjectInput{
 			Bucket: aws.String(r.opts.Bucket),
 			Key:    aws.String(key),
@@ -78,17 +151,18 @@ func (r *Store) IsExist(key string) (bool, error) {
 			}
 		}
 
-		return false, errors.Wrap(err, "key: "+key)
+		return false, classify(errors.Wrap(err, "key: "+key))
 	}
 
 	return true, nil
 }
 
-// Save сохраняет файл в s3, возвращает ошибку при её возникновени, в противном случае nil.
-// Ответственность за закрытие переданного ридера - на вызывающей стороне, тут это просто ридер.
-func (r *Store) Save(key string, content io.Reader) error {
+func (r *Store) saveObject(ctx context.Context, key string, content io.Reader) error {
+	ctx, cancel := r.operationContext(ctx)
+	defer cancel()
+
 	_, err := r.uploader.Upload(
-		context.Background(),
+		ctx,
 		&s3.PutObjectInput{
 			Bucket: aws.String(r.opts.Bucket),
 			Key:    aws.String(key),
@@ -96,5 +170,58 @@ func (r *Store) Save(key string, content io.Reader) error {
 		},
 	)
 
-	return errors.WithStack(err)
+	return classify(errors.WithStack(err))
+}
+
+func (r *Store) operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	timeout := defaultOperationTimeout
+	if r.opts.OperationTimeout > 0 {
+		timeout = r.opts.OperationTimeout
+	}
+
+	return context.WithTimeout(ctx, timeout)
+}
+
+// objectBody освобождает контекст операции при закрытии ридера
+// и классифицирует ошибки чтения данных.
+type objectBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *objectBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err != nil && !errors.Is(err, io.EOF) {
+		err = classify(errors.WithStack(err))
+	}
+
+	return n, err
+}
+
+func (b *objectBody) Close() error {
+	defer b.cancel()
+
+	return b.ReadCloser.Close()
+}
+
+var retryables = retry.IsErrorRetryables(append(
+	[]retry.IsErrorRetryable{retry.RetryableConnectionError{}},
+	retry.DefaultRetryables...,
+))
+
+// classify помечает временные ошибки: таймауты, сетевые ошибки, троттлинг и 5xx,
+// а также ошибки, для которых клиент исчерпал все повторы.
+func classify(err error) error {
+	if err == nil {
+		return nil
+	}
+
+	var maxAttempts *retry.MaxAttemptsError
+	if errors.Is(err, context.DeadlineExceeded) ||
+		errors.As(err, &maxAttempts) ||
+		retryables.IsErrorRetryable(err) == aws.TrueTernary {
+		return domain.NewTransientError(err)
+	}
+
+	return err
 }
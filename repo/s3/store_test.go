@@ -7,13 +7,52 @@ import (
 	"testing"
 
 	"github.com/aws/aws-sdk-go-v2/aws"
+	"github.com/aws/aws-sdk-go-v2/aws/retry"
 	"github.com/aws/aws-sdk-go-v2/service/s3"
+	"github.com/aws/aws-sdk-go-v2/service/s3/types"
+	"github.com/aws/smithy-go"
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 
+	"github.com/cannonflesh/wordspell/domain"
 	s3client "github.com/cannonflesh/wordspell/internal/s3"
 	"github.com/cannonflesh/wordspell/testdata"
 )
 
+func TestStore_classify(t *testing.T) {
+	require.NoError(t, classify(nil))
+	require.True(t, domain.IsTransient(classify(errors.WithStack(context.DeadlineExceeded))))
+	require.True(t, domain.IsTransient(classify(&retry.MaxAttemptsError{Attempt: 3, Err: errors.New("slow down")})))
+	require.False(t, domain.IsTransient(classify(errors.New("access denied"))))
+}
+
+func TestIsConditionFailed(t *testing.T) {
+	require.True(t, isConditionFailed(errors.WithStack(&smithy.GenericAPIError{Code: "PreconditionFailed"})))
+	require.True(t, isConditionFailed(&smithy.GenericAPIError{Code: "ConditionalRequestConflict"}))
+	require.False(t, isConditionFailed(&smithy.GenericAPIError{Code: "AccessDenied"}))
+	require.False(t, isConditionFailed(errors.New("precondition failed")))
+	require.False(t, isConditionFailed(nil))
+}
+
+func TestDeleteFailure(t *testing.T) {
+	require.NoError(t, deleteFailure("g1", nil))
+
+	err := deleteFailure("g1", []types.Error{
+		{Key: aws.String("wordspell/g1/ru.index"), Code: aws.String("AccessDenied"), Message: aws.String("Access Denied")},
+		{Key: aws.String("wordspell/g1/en.index"), Code: aws.String("AccessDenied"), Message: aws.String("Access Denied")},
+	})
+	require.EqualError(
+		t, err,
+		"deleting generation g1: 2 objects not deleted, first: wordspell/g1/ru.index: api error AccessDenied: Access Denied",
+	)
+	require.False(t, domain.IsTransient(err))
+
+	for _, code := range []string{"InternalError", "SlowDown"} {
+		err = deleteFailure("g1", []types.Error{{Key: aws.String("wordspell/g1/ru.index"), Code: aws.String(code)}})
+		require.True(t, domain.IsTransient(err), code)
+	}
+}
+
 func TestStore_IsExists_DataReader(t *testing.T) {
 	testClient := testdata.NewTestS3(t)
 
@@ -72,3 +111,99 @@ func TestStore_IsExists_DataReader(t *testing.T) {
 	require.Equal(t, "indexdata", string(testBin))
 	require.NoError(t, res.Close())
 }
+
+func TestStore_Generations(t *testing.T) {
+	testClient := testdata.NewTestS3(t)
+
+	defer testClient.TermFn()
+
+	opts := Options{
+		Bucket:    "test-bucket",
+		Name:      "wordspell",
+		Retention: 2,
+	}
+
+	cli, err := s3client.NewClient(s3client.Options{
+		Endpoint:        testClient.Endpoint,
+		AccessKeyID:     testClient.Key,
+		SecretAccessKey: testClient.Secret,
+	})
+	require.NoError(t, err)
+
+	_, err = cli.CreateBucket(
+		context.Background(),
+		&s3.CreateBucketInput{
+			Bucket: aws.String(opts.Bucket),
+		},
+	)
+	require.NoError(t, err)
+
+	repo, err := NewStore(cli, opts)
+	require.NoError(t, err)
+
+	// пока ничего не опубликовано, текущего поколения нет.
+	_, err = repo.Current()
+	require.EqualError(t, err, "no published generation found: wordspell")
+
+	for _, g := range []string{"gen-1", "gen-2", "gen-3"} {
+		err = repo.WithGeneration(g).Save("ru.index", bytes.NewBufferString(g))
+		require.NoError(t, err)
+
+		// до публикации читающая сторона видит предыдущее поколение.
+		if g != "gen-1" {
+			cur, err := repo.Current()
+			require.NoError(t, err)
+			require.NotEqual(t, g, cur.Generation())
+		}
+
+		require.NoError(t, repo.Publish(g))
+	}
+
+	cur, err := repo.Current()
+	require.NoError(t, err)
+	require.Equal(t, "gen-3", cur.Generation())
+
+	res, err := cur.DataReader("ru.index")
+	require.NoError(t, err)
+	testBin, err := io.ReadAll(res)
+	require.NoError(t, err)
+	require.Equal(t, "gen-3", string(testBin))
+	require.NoError(t, res.Close())
+
+	// хранятся только два последних поколения.
+	removed, err := repo.Cleanup()
+	require.NoError(t, err)
+	require.Equal(t, []string{"gen-1"}, removed)
+
+	gens, err := repo.Generations()
+	require.NoError(t, err)
+	require.Equal(t, []string{"gen-2", "gen-3"}, gens)
+
+	// откат на предыдущее поколение.
+	g, err := repo.Rollback("")
+	require.NoError(t, err)
+	require.Equal(t, "gen-2", g)
+
+	cur, err = repo.Current()
+	require.NoError(t, err)
+	require.Equal(t, "gen-2", cur.Generation())
+
+	_, err = repo.Rollback("")
+	require.EqualError(t, err, "no previous generation to roll back to")
+
+	// поколение, с которого откатились, удаляется, хоть оно и новее текущего.
+	removed, err = repo.Cleanup()
+	require.NoError(t, err)
+	require.Equal(t, []string{"gen-3"}, removed)
+
+	// указатель, измененный между чтением и записью, не перезаписывается.
+	p, etag, err := repo.readPointer(context.Background())
+	require.NoError(t, err)
+	require.NoError(t, repo.Publish("gen-4"))
+	require.ErrorIs(t, repo.writePointer(context.Background(), p, etag), ErrPointerChanged)
+	require.ErrorIs(t, repo.writePointer(context.Background(), p, ""), ErrPointerChanged)
+
+	cur, err = repo.Current()
+	require.NoError(t, err)
+	require.Equal(t, "gen-4", cur.Generation())
+}
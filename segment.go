@@ -0,0 +1,90 @@
+package wordspell
+
+import (
+	"strings"
+	"unicode/utf8"
+
+	"github.com/cannonflesh/wordspell/components/wordmutate"
+	"github.com/cannonflesh/wordspell/domain"
+)
+
+// segmentWord делит длинное слово на как можно меньшее количество слов индекса (политика wordmutate.LongWordsSegment).
+// Из разбиений с одинаковым количеством слов выбирается то, у которого больше суммарный вес.
+// Части не длиннее правил расстояния редактирования и не короче двух рун.
+// weight - вес самой редкой части. Отдает пустую строку, если разбить слово не удалось.
+func (s *Service) segmentWord(word string) (res string, weight uint32) {
+	// offsets[i] - байтовое смещение i-й руны, части слова берутся подстроками без копирования.
+	offsets := make([]int, 0, len(word)+1)
+	for i := range word {
+		offsets = append(offsets, i)
+	}
+	offsets = append(offsets, len(word))
+	n := len(offsets) - 1
+
+	// Для префикса из i рун: parts - наименьшее количество слов, weights - их суммарный вес, starts - начало последнего.
+	parts := make([]int, n+1)
+	weights := make([]uint64, n+1)
+	starts := make([]int, n+1)
+	for i := 1; i <= n; i++ {
+		parts[i] = -1
+	}
+
+	maxPart := s.mutate.MaxLen()
+	for i := 2; i <= n; i++ {
+		for j := max(0, i-maxPart); j <= i-2; j++ {
+			if parts[j] < 0 {
+				continue
+			}
+
+			weight := s.index.Weight(word[offsets[j]:offsets[i]])
+			if weight == 0 {
+				continue
+			}
+
+			p, w := parts[j]+1, weights[j]+uint64(weight)
+			if parts[i] < 0 || p < parts[i] || p == parts[i] && w > weights[i] {
+				parts[i], weights[i], starts[i] = p, w, j
+			}
+		}
+	}
+
+	if parts[n] < 2 {
+		return "", 0
+	}
+
+	words := make([]string, parts[n])
+	for i, k := n, parts[n]-1; i > 0; i, k = starts[i], k-1 {
+		words[k] = word[offsets[starts[i]]:offsets[i]]
+
+		if w := s.index.Weight(words[k]); weight == 0 || w < weight {
+			weight = w
+		}
+	}
+
+	return strings.Join(words, domain.SpaceSeparator), weight
+}
+
+// correctLongWord исправляет длинное слово языка lang согласно политике wordmutate.LongWordsSegment.
+// found == false, если политика другая или слово не длинное: тогда оно исправляется как обычно.
+// Разбиение проходит те же пороги Confidence, что и любое исправление, по весу самой редкой части,
+// confidence - уверенность в нем, 1 - если слово осталось как есть.
+func (s *Service) correctLongWord(
+	el domain.DigestRaw,
+	lang, word string,
+) (res domain.DigestElement, confidence float64, found bool) {
+	if s.mutate.LongWords() != wordmutate.LongWordsSegment || !s.mutate.IsLong(utf8.RuneCountInString(word)) {
+		return nil, 0, false
+	}
+
+	segmented, weight := s.segmentWord(word)
+	if segmented == "" {
+		return el, 1, true
+	}
+
+	confidence, ok := s.correctionConfidence(lang, weight)
+	if !ok {
+		return el, 1, true
+	}
+
+	return domain.NewDigestReady(segmented), confidence, true
+}
@@ -0,0 +1,135 @@
+package wordspell
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	"github.com/cannonflesh/wordspell/components/bloomfilter"
+	"github.com/cannonflesh/wordspell/components/deletetable"
+	"github.com/cannonflesh/wordspell/components/index"
+	"github.com/cannonflesh/wordspell/components/manifest"
+	"github.com/cannonflesh/wordspell/components/wordmutate"
+	"github.com/cannonflesh/wordspell/domain"
+	"github.com/cannonflesh/wordspell/options"
+	"github.com/cannonflesh/wordspell/repo/embedded"
+	"github.com/cannonflesh/wordspell/repo/memory"
+	"github.com/cannonflesh/wordspell/testdata"
+)
+
+const longWord = "частнопредпринимательский"
+
+// longWordSpeller отдает спеллер по индексам testdata, в русский индекс которого добавлено длинное слово longWord.
+func longWordSpeller(t *testing.T, opt *options.Options) *Service {
+	lgr, _ := testdata.NewTestLogger()
+
+	src := embedded.New(testdata.Indexes)
+	store := memory.New()
+	for _, key := range []string{index.StoreKey(domain.RuLangCode), index.StoreKey(domain.EnLangCode), "trademark.index"} {
+		dr, err := src.DataReader(key)
+		require.NoError(t, err)
+		data, err := io.ReadAll(dr)
+		require.NoError(t, err)
+		require.NoError(t, dr.Close())
+
+		if key == index.StoreKey(domain.RuLangCode) {
+			data = append(data, []byte(longWord+"\t1000\n")...)
+		}
+		require.NoError(t, store.Save(key, bytes.NewReader(data)))
+	}
+
+	opt.Langs = []string{domain.EnLangCode, domain.RuLangCode}
+	opt.Bloom.Startup = bloomfilter.StartupBuild
+	opt.Manifest = manifest.Options{SkipVerify: true}
+
+	s, err := newService(opt, store, lgr)
+	require.NoError(t, err)
+
+	return s
+}
+
+func TestService_LongWords(t *testing.T) {
+	const misspelled = "частнопредпренимательский"
+
+	correct := func(s *Service, w string) string {
+		res, _ := s.correctWordWith(context.Background(), domain.NewDigestRaw(w), defaultCorrectOptions)
+
+		return res.String()
+	}
+
+	t.Run("Skip", func(t *testing.T) {
+		s := longWordSpeller(t, &options.Options{})
+		require.Equal(t, longWord, correct(s, longWord))
+		require.Equal(t, misspelled, correct(s, misspelled))
+	})
+
+	t.Run("Distance1", func(t *testing.T) {
+		long := wordmutate.Options{LongWords: wordmutate.LongWordsDistance1}
+
+		bloom := longWordSpeller(t, &options.Options{Mutate: long})
+		require.Equal(t, longWord, correct(bloom, misspelled))
+		require.Equal(t, longWord, correct(bloom, "частнопредприниимательский"))
+		// Две ошибки в длинном слове не исправляются.
+		require.Equal(t, "чстнопредпренимательский", correct(bloom, "чстнопредпренимательский"))
+		// Короткие слова исправляются как прежде.
+		require.Equal(t, "для", correct(bloom, "д1я"))
+
+		table := longWordSpeller(t, &options.Options{Mutate: long, DeleteTable: deletetable.Options{Enabled: true}})
+		require.Equal(t, longWord, correct(table, misspelled))
+		require.Equal(t, "чстнопредпренимательский", correct(table, "чстнопредпренимательский"))
+	})
+
+	t.Run("Segment", func(t *testing.T) {
+		s := longWordSpeller(t, &options.Options{Mutate: wordmutate.Options{LongWords: wordmutate.LongWordsSegment}})
+
+		require.Equal(t, "безопасности организация для", correct(s, "безопасностиорганизациядля"))
+		require.Equal(t, "для ящик безопасности организация", correct(s, "дляящикбезопасностиорганизация"))
+		require.Equal(t, "безопасностиорганизацияфыв", correct(s, "безопасностиорганизацияфыв"))
+		// Слова не длиннее 24 рун не делятся, а исправляются как обычно.
+		require.Equal(t, "безопасности", correct(s, "безупасност2"))
+		require.Equal(t, longWord, correct(s, longWord))
+
+		// Уверенность в разбиении - уверенность в исправлении на самую редкую его часть.
+		const joined = "безопасностиорганизациядля"
+		_, weight := s.segmentWord(joined)
+		expected, ok := s.correctionConfidence(domain.RuLangCode, weight)
+		require.True(t, ok)
+		res, confidence := s.correctWordWith(context.Background(), domain.NewDigestRaw(joined), defaultCorrectOptions)
+		require.Equal(t, "безопасности организация для", res.String())
+		require.Equal(t, expected, confidence)
+		require.Less(t, confidence, 1.0)
+
+		// Слова других языков не делятся.
+		res, confidence = s.correctWordWith(
+			context.Background(), domain.NewDigestRaw(joined), &CorrectOptions{Langs: []string{domain.EnLangCode}},
+		)
+		require.Equal(t, joined, res.String())
+		require.Equal(t, 1.0, confidence)
+	})
+
+	t.Run("SegmentConfidence", func(t *testing.T) {
+		s := longWordSpeller(t, &options.Options{
+			Mutate:     wordmutate.Options{LongWords: wordmutate.LongWordsSegment},
+			Confidence: options.Confidence{MinWeight: 1 << 30},
+		})
+
+		// Разбиение на слишком редкие части не проходит порог, как и любое исправление.
+		require.Equal(t, "безопасностиорганизациядля", correct(s, "безопасностиорганизациядля"))
+	})
+
+	t.Run("SegmentRare", func(t *testing.T) {
+		s := longWordSpeller(t, &options.Options{Mutate: wordmutate.Options{LongWords: wordmutate.LongWordsSegment}})
+
+		// Длинное слово из фильтра редких слов правдоподобно и не делится.
+		s.rare = bloomfilter.NewRare(&bloomfilter.Options{}, nil, s.logger)
+		s.rare.Reset(1)
+		s.rare.Add("безопасностиорганизациядля")
+		require.NoError(t, s.rare.Seal())
+
+		require.Equal(t, "безопасностиорганизациядля", correct(s, "безопасностиорганизациядля"))
+		require.Equal(t, "для ящик безопасности организация", correct(s, "дляящикбезопасностиорганизация"))
+	})
+}
@@ -1,15 +1,20 @@
 package wordspell
 
 import (
+	"context"
 	"strings"
 	"time"
+	"unicode/utf8"
 
 	"github.com/sirupsen/logrus"
 
 	"github.com/cannonflesh/wordspell/components/bloomfilter"
+	"github.com/cannonflesh/wordspell/components/deletetable"
 	"github.com/cannonflesh/wordspell/components/index"
 	"github.com/cannonflesh/wordspell/components/langdetect"
+	"github.com/cannonflesh/wordspell/components/manifest"
 	"github.com/cannonflesh/wordspell/components/trademarkindex"
+	"github.com/cannonflesh/wordspell/components/wordforms"
 	"github.com/cannonflesh/wordspell/components/wordmutate"
 	"github.com/cannonflesh/wordspell/domain"
 	s3client "github.com/cannonflesh/wordspell/internal/s3"
@@ -20,6 +25,8 @@ import (
 	"github.com/cannonflesh/wordspell/processors/papersizes"
 	"github.com/cannonflesh/wordspell/processors/trademarks"
 	"github.com/cannonflesh/wordspell/processors/units"
+	"github.com/cannonflesh/wordspell/repo/cache"
+	"github.com/cannonflesh/wordspell/repo/compress"
 	s3source "github.com/cannonflesh/wordspell/repo/s3"
 )
 
@@ -27,31 +34,220 @@ type processor interface {
 	Process(words []string) []string
 }
 
+// namedProcessor - обработчик с именем, по которому его можно отключить в CorrectOptions.
+type namedProcessor struct {
+	name string
+	processor
+}
+
+// generational - хранилище, привязанное к определенному поколению артефактов.
+type generational interface {
+	Generation() string
+}
+
 type Service struct {
 	langs  *langdetect.Component
 	index  *index.Service
 	mutate *wordmutate.Component
-	bloom  *bloomfilter.Component
-
-	preProcessors  []processor
-	postProcessors []processor
+	bloom  *bloomFilters
+	// deleteTable - таблица удалений, если она включена (DeleteTable.Enabled), иначе nil и используется bloom.
+	deleteTable *deletetable.Component
+	// rare - фильтр редких слов каталога, если он включен (Bloom.Rare.Enabled), иначе nil.
+	rare *bloomfilter.Component
+	// workers - сколько токенов запроса исправляются одновременно (Parallel.Workers).
+	workers int
+	// batch - настройки CorrectBatch и CorrectStream по умолчанию.
+	batch options.Batch
+	// confidence - пороги, при которых исправление опечатки применяется.
+	confidence options.Confidence
+	// thresholds - пороги частоты, с которыми построены индексы (из манифеста), nil - если манифест не проверялся.
+	thresholds map[string]uint32
+	forms      *wordforms.Component
+
+	preProcessors  []namedProcessor
+	postProcessors []namedProcessor
 
 	logger *logrus.Entry
 }
 
+// New загружает индексы текущего поколения из s3.
+// Временные ошибки повторяет клиент s3 (S3Client.RetriesCount, RetryTimeout) для каждого запроса,
+// загрузка целиком не повторяется: иначе запросы к недоступному бакету повторялись бы (R+1)² раз.
 func New(opt *options.Options, l *logrus.Entry) (*Service, error) {
-	langDetect := langdetect.New()
+	store, cached, err := serviceStore(opt, l)
+	if err != nil {
+		return nil, err
+	}
+
+	return startService(opt, store, cached, l)
+}
+
+// NewFromStore загружает индексы из store - любого хранилища с полным поколением артефактов (индексы, фильтры, манифест):
+// например, embedded.Store поверх вкомпилированного в бинарник embed.FS, чтобы спеллер работал без s3.
+// Сжатые артефакты читаются через compress.Store.
+func NewFromStore(opt *options.Options, store index.ReadOnlyStore, l *logrus.Entry) (*Service, error) {
+	return newService(opt, store, l)
+}
+
+// startService загружает сервис из store. Если настроен локальный кеш cached, а загрузка прервалась временной ошибкой
+// (например, s3 отказал посреди скачивания нового поколения), сервис загружается из последнего полностью скачанного
+// поколения в кеше. После успешной загрузки поколение отмечается в кеше как полностью скачанное.
+func startService(opt *options.Options, store index.ReadOnlyStore, cached *cache.Store, l *logrus.Entry) (*Service, error) {
+	res, err := newService(opt, store, l)
+	if cached == nil || cached.Offline() {
+		return res, err
+	}
+
+	if domain.IsTransient(err) {
+		l.WithError(err).Warn("loading indexes failed, serving the last complete generation from local cache")
+
+		offline, cerr := cache.New(&opt.Cache, cache.Unavailable(err), l)
+		if cerr != nil {
+			return nil, cerr
+		}
+		if store, cerr = compress.New(&opt.Compression, offline); cerr != nil {
+			return nil, cerr
+		}
+
+		return newService(opt, store, l)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// Все артефакты поколения скачаны и проверены: при недоступности s3 спеллер стартует именно с ними.
+	if err = cached.Complete(); err != nil {
+		l.WithError(err).Warn("marking cached generation as complete")
+	}
+
+	return res, nil
+}
+
+// serviceStore отдает хранилище текущего поколения артефактов,
+// обернутое в локальный кеш, если он настроен, и распаковывающее сжатые артефакты.
+// Второе значение - локальный кеш, nil - если он не настроен.
+func serviceStore(opt *options.Options, l *logrus.Entry) (index.ReadOnlyStore, *cache.Store, error) {
+	store, cached, err := cachedStore(opt, l)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := compress.New(&opt.Compression, store)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return res, cached, nil
+}
+
+// cachedStore отдает хранилище текущего поколения артефактов, обернутое в локальный кеш, если он настроен.
+// В кеше артефакты лежат в том виде, в каком хранятся в s3, то есть сжатыми.
+func cachedStore(opt *options.Options, l *logrus.Entry) (compress.DataStore, *cache.Store, error) {
+	store, err := currentS3Store(opt)
+	if opt.Cache.Dir == "" {
+		if err != nil {
+			return nil, nil, err
+		}
+
+		return store, nil, nil
+	}
 
+	var cached *cache.Store
+	if err != nil {
+		// Кеш спасает лишь от недоступности s3: ошибки настройки (бакет, доступы) не прячутся за старыми данными.
+		if !domain.IsTransient(err) {
+			return nil, nil, err
+		}
+
+		l.WithError(err).Warn("remote store is unavailable, serving from local cache")
+
+		cached, err = cache.New(&opt.Cache, cache.Unavailable(err), l)
+	} else {
+		cached, err = cache.New(&opt.Cache, store, l)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return cached, cached, nil
+}
+
+func currentS3Store(opt *options.Options) (*s3source.Store, error) {
 	s3cli, err := s3client.NewClient(opt.S3Client)
 	if err != nil {
 		return nil, err
 	}
 
-	store, err := s3source.NewStore(s3cli, opt.S3Data)
+	s3store, err := s3source.NewStore(s3cli, opt.S3Data)
 	if err != nil {
 		return nil, err
 	}
 
+	// Указатель на текущее поколение разрешается один раз, при старте:
+	// все артефакты будут прочитаны из одного и того же поколения.
+	return s3store.Current()
+}
+
+// loadManifest читает манифест поколения и отдает хранилище, читающее остальные артефакты
+// в формате сжатия из манифеста, без перебора суффиксов.
+// Поколения, собранные раньше, могли сохранить сжатым и сам манифест: его ищут во всех форматах.
+func loadManifest(store index.ReadOnlyStore) (*manifest.Manifest, index.ReadOnlyStore, error) {
+	cs, ok := store.(*compress.Store)
+	if !ok {
+		m, err := manifest.Load(store)
+
+		return m, store, err
+	}
+
+	exists, err := cs.Raw().IsExist(manifest.StoreKey)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !exists {
+		m, err := manifest.Load(cs)
+
+		return m, store, err
+	}
+
+	m, err := manifest.Load(cs.Raw())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	res, err := cs.ForCodec(m.Options.Compression)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return m, res, nil
+}
+
+func newService(opt *options.Options, store index.ReadOnlyStore, l *logrus.Entry) (*Service, error) {
+	langDetect := langdetect.New()
+
+	var (
+		m   *manifest.Manifest
+		err error
+	)
+	if !opt.Manifest.SkipVerify {
+		m, store, err = loadManifest(store)
+		if err != nil {
+			return nil, err
+		}
+
+		generation := opt.Manifest.Generation
+		if gs, ok := store.(generational); ok && generation == "" {
+			generation = gs.Generation()
+		}
+
+		if err = m.Verify(generation); err != nil {
+			return nil, err
+		}
+
+		store = m.Verified(store)
+		l.Infof("manifest verified, generation: %s", m.Generation)
+	}
+
 	startTmLoad := time.Now()
 	tm, err := trademarkindex.NewService(store, l)
 	if err != nil {
@@ -66,32 +262,61 @@ func New(opt *options.Options, l *logrus.Entry) (*Service, error) {
 	}
 	l.Infof("index loaded in %s", time.Since(startIdxLoad))
 
-	startLoadBloom := time.Now()
-	bloom := bloomfilter.New(&opt.Bloom, store, l)
-	err = bloom.Load()
+	mutate, err := wordmutate.NewWithOptions(&opt.Mutate)
 	if err != nil {
 		return nil, err
 	}
-	l.Infof("bloom loaded in %s", time.Since(startLoadBloom))
+	l.Infof("max edit distances: %s", mutate.Policy())
 
-	preProcessors := []processor{
-		trademarks.New(tm),
-		dimsuffix.New(),
-		dimensions.New(),
-		papersizes.New(),
-		units.New(),
+	var (
+		bloom       *bloomFilters
+		deleteTable *deletetable.Component
+	)
+	if opt.DeleteTable.Enabled {
+		deleteTable, err = loadDeleteTable(&opt.DeleteTable, idx, mutate, l)
+	} else {
+		bloom, err = loadBloomFilters(opt, store, idx, mutate, m, l)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	rare, err := loadRareFilter(opt, store, l)
+	if err != nil {
+		return nil, err
+	}
+
+	var thresholds map[string]uint32
+	if m != nil {
+		thresholds = m.Options.Thresholds
+	}
+
+	preProcessors := []namedProcessor{
+		{name: trademarks.Name, processor: trademarks.New(tm)},
+		{name: dimsuffix.Name, processor: dimsuffix.New()},
+		{name: dimensions.Name, processor: dimensions.New()},
+		{name: papersizes.Name, processor: papersizes.New()},
+		{name: units.Name, processor: units.New()},
 	}
 
-	postProcessors := []processor{
-		dupremove.New(),
+	postProcessors := []namedProcessor{
+		{name: dupremove.Name, processor: dupremove.New()},
 	}
 
 	return &Service{
 		langs:  langDetect,
 		index:  idx,
-		mutate: wordmutate.New(),
+		mutate: mutate,
 		bloom:  bloom,
 
+		deleteTable: deleteTable,
+		rare:        rare,
+		workers:     opt.Parallel.Workers,
+		batch:       opt.Batch,
+		confidence:  opt.Confidence,
+		thresholds:  thresholds,
+		forms:       wordforms.New(),
+
 		preProcessors:  preProcessors,
 		postProcessors: postProcessors,
 
@@ -100,38 +325,52 @@ func New(opt *options.Options, l *logrus.Entry) (*Service, error) {
 }
 
 func (s *Service) Correct(request string) string {
+	return s.CorrectWithOptions(request, CorrectOptions{})
+}
+
+// CorrectWithOptions исправляет запрос с настройками opt: отдельные этапы и обработчики можно отключить,
+// а расстояние редактирования и языки - ограничить.
+func (s *Service) CorrectWithOptions(request string, opt CorrectOptions) string {
+	return s.CorrectWithConfidence(request, opt).Corrected
+}
+
+// correct исправляет запрос. Отмена ctx проверяется между токенами запроса и между удалениями слова
+// при поиске исправления, так что длинный поиск одного токена тоже прерывается.
+func (s *Service) correct(ctx context.Context, request string, opt *CorrectOptions) (Correction, error) {
+	if err := ctx.Err(); err != nil {
+		return Correction{}, err
+	}
+
 	preProcessed := strings.Fields(domain.CleanTextRE.ReplaceAllString(request, domain.SpaceSeparator))
 	for _, wp := range s.preProcessors {
-		preProcessed = wp.Process(preProcessed)
+		if opt.processorEnabled(wp.name) {
+			preProcessed = wp.Process(preProcessed)
+		}
 	}
 
-	digest := s.checkWordPairs(domain.ParseDigest(preProcessed))
+	digest := domain.ParseDigest(preProcessed)
+	if !opt.SkipPairs {
+		digest = s.checkWordPairs(digest, opt)
+	}
 
-	res := make([]string, 0, len(digest))
-	for _, v := range digest {
-		switch vv := v.(type) {
-		case domain.DigestRaw:
-			if splitted, found := s.splittedWord(vv); found {
-				res = append(res, splitted)
-			} else {
-				res = append(res, s.correctWord(vv).String())
-			}
-		case domain.DigestReady:
-			res = append(res, vv.String())
-		}
+	res, confidence, err := s.correctDigest(ctx, digest, opt)
+	if err != nil {
+		return Correction{}, err
 	}
 
 	for _, wp := range s.postProcessors {
-		res = wp.Process(res)
+		if opt.processorEnabled(wp.name) {
+			res = wp.Process(res)
+		}
 	}
 
-	return strings.Join(res, domain.SpaceSeparator)
+	return Correction{Corrected: strings.Join(res, domain.SpaceSeparator), Confidence: confidence}, nil
 }
 
-func (s *Service) checkWordPairs(dig domain.Digest) domain.Digest {
+func (s *Service) checkWordPairs(dig domain.Digest, opt *CorrectOptions) domain.Digest {
 	res := domain.NewEmptyDigest()
 
-	for el, replaced := s.wordPair(dig); el != nil; el, replaced = s.wordPair(dig) {
+	for el, replaced := s.wordPair(dig, opt); el != nil; el, replaced = s.wordPair(dig, opt) {
 		res = res.Add(el)
 
 		if len(dig) == 0 {
@@ -148,7 +387,7 @@ func (s *Service) checkWordPairs(dig domain.Digest) domain.Digest {
 	return res
 }
 
-func (s *Service) wordPair(dig domain.Digest) (domain.DigestElement, bool) {
+func (s *Service) wordPair(dig domain.Digest, opt *CorrectOptions) (domain.DigestElement, bool) {
 	if len(dig) == 0 {
 		return nil, false
 	}
@@ -174,101 +413,237 @@ func (s *Service) wordPair(dig domain.Digest) (domain.DigestElement, bool) {
 	leftLang = s.langs.LangByWord(left.String())
 	rightLang = s.langs.LangByWord(right.String())
 
-	if leftLang == domain.UnknownLangCode || leftLang == domain.NumLangCode || rightLang != leftLang {
+	if leftLang == domain.UnknownLangCode || leftLang == domain.NumLangCode || rightLang != leftLang ||
+		!opt.langAllowed(leftLang) {
 		return left, false
 	}
 
 	merged := strings.ToLower(left.Merge(right).String())
-	if s.index.Weight(merged) > 0 {
+	if weight := s.index.Weight(merged); weight > 0 && opt.outweighs(weight, s.pairWeight(left, right)) {
 		return domain.NewDigestReady(merged), true
 	}
 
 	return left, false
 }
 
-func (s *Service) splittedWord(el domain.DigestRaw) (string, bool) {
-	splitted := s.mutate.InsertSpace(strings.ToLower(el.String()))
+// pairWeight - вес пары слов для сравнения со склейкой: вес более частого из них.
+func (s *Service) pairWeight(left, right domain.DigestRaw) uint32 {
+	return max(s.index.Weight(strings.ToLower(left.String())), s.index.Weight(strings.ToLower(right.String())))
+}
+
+// correctDigest исправляет токены дайджеста по очереди или, если задан Parallel.Workers, параллельно.
+// confidence - наименьшая из уверенностей в исправлениях токенов.
+func (s *Service) correctDigest(
+	ctx context.Context,
+	digest domain.Digest,
+	opt *CorrectOptions,
+) (res []string, confidence float64, err error) {
+	if s.workers > 1 {
+		return s.correctDigestParallel(ctx, digest, s.workers, opt)
+	}
+
+	res = make([]string, 0, len(digest))
+	confidence = 1
+	for _, v := range digest {
+		if err = ctx.Err(); err != nil {
+			return nil, 0, err
+		}
+
+		corrected, c := s.correctToken(ctx, v, opt)
+		res = append(res, corrected)
+		confidence = min(confidence, c)
+	}
+
+	// Поиск исправления последнего токена мог прерваться по ctx.
+	if err = ctx.Err(); err != nil {
+		return nil, 0, err
+	}
+
+	return res, confidence, nil
+}
+
+// correctToken исправляет один токен дайджеста: готовые токены отдаются как есть,
+// сырые разбиваются на два слова индекса или исправляются.
+// confidence - уверенность в исправлении опечатки, 1 - если опечатка не исправлялась.
+func (s *Service) correctToken(
+	ctx context.Context,
+	el domain.DigestElement,
+	opt *CorrectOptions,
+) (res string, confidence float64) {
+	vv, ok := el.(domain.DigestRaw)
+	if !ok {
+		return el.String(), 1
+	}
+
+	if !opt.langAllowed(s.langs.LangByWord(strings.ToLower(vv.String()))) {
+		return vv.String(), 1
+	}
+
+	if !opt.SkipSplit {
+		splitted, weight, found := s.splittedWord(vv)
+		if found && opt.outweighs(weight, s.index.Weight(strings.ToLower(vv.String()))) {
+			return splitted, 1
+		}
+	}
+
+	if opt.SkipEdit {
+		return vv.String(), 1
+	}
+
+	corrected, confidence := s.correctWordWith(ctx, vv, opt)
+
+	return corrected.String(), confidence
+}
+
+// splittedWord разбивает слово на два слова индекса. weight - вес найденного разбиения.
+func (s *Service) splittedWord(el domain.DigestRaw) (res string, weight uint32, found bool) {
 	var (
 		maxWeight uint32
 		best      string
 	)
 
-	for _, w := range splitted {
-		if weight := s.index.Weight(w); weight > maxWeight {
+	s.mutate.EachInsertSpace(strings.ToLower(el.String()), func(w []byte) bool {
+		if weight := s.index.WeightBytes(w); weight > maxWeight {
 			maxWeight = weight
-			best = w
+			best = string(w)
 		}
-	}
+
+		return true
+	})
 
 	if best != "" {
-		return best, true
+		return best, maxWeight, true
 	}
 
-	return el.String(), false
+	return el.String(), 0, false
 }
 
-func (s *Service) correctWord(el domain.DigestRaw) domain.DigestElement {
+// correctWordWith ищет исправление слова с настройками opt. Слова языков не из opt.Langs, правдоподобные слова
+// (см. plausible) и исправления, в том числе разбиения длинных слов, не прошедшие пороги Confidence, остаются как есть.
+// confidence - уверенность в исправлении, 1 - если слово не исправлено.
+func (s *Service) correctWordWith(
+	ctx context.Context,
+	el domain.DigestRaw,
+	opt *CorrectOptions,
+) (res domain.DigestElement, confidence float64) {
 	word := strings.ToLower(el.String())
 
 	if s.index.Weight(word) > 0 {
-		return domain.NewDigestReady(word)
+		return domain.NewDigestReady(word), 1
+	}
+
+	lang := s.langs.LangByWord(word)
+	if !opt.langAllowed(lang) || s.plausible(lang, word) {
+		return el, 1
+	}
+
+	if res, confidence, found := s.correctLongWord(el, lang, word); found {
+		return res, confidence
+	}
+
+	distance := opt.maxDistance(s.mutate.MaxDistance(utf8.RuneCountInString(word)))
+
+	var corrected string
+	if s.deleteTable != nil {
+		corrected = s.correctByDeleteTable(ctx, word, distance)
+	} else {
+		corrected = s.correctByBloom(ctx, lang, word, distance)
 	}
 
-	dels := s.mutate.Deletes(word)
-	for _, w := range dels {
+	if corrected == "" {
+		return el, 1
+	}
+
+	confidence, ok := s.correctionConfidence(lang, s.index.Weight(corrected))
+	if !ok {
+		return el, 1
+	}
+
+	return domain.NewDigestReady(corrected), confidence
+}
+
+// correctByBloom ищет исправление слова через bloom-фильтр. Кандидаты перебираются генераторами wordmutate.Component.Each*
+// и проверяются по индексу прямо в их буферах: строка создается только для найденного исправления.
+// Отдает пустую строку, если исправление не найдено или ctx отменен.
+func (s *Service) correctByBloom(ctx context.Context, lang, word string, distance int) string {
+	var correctWord string
+	s.mutate.EachDeleteN(word, distance, func(w []byte) bool {
+		// Поиск длинного слова может быть долгим, поэтому отмена ctx проверяется перед каждым удалением.
+		if ctx.Err() != nil {
+			return false
+		}
+
 		// Проверяем, нет ли в индексе самого удаления.
-		if weight := s.index.Weight(w); weight > 0 {
-			return domain.NewDigestReady(w)
+		if weight := s.index.WeightBytes(w); weight > 0 {
+			correctWord = string(w)
+
+			return false
+		}
+
+		if !s.bloom.Test(lang, w) {
+			return true
 		}
 
-		if s.bloom.Test(w) {
-			// Выполняем полный набор вставок по одной руне, проверяем на наличие их в индексе.
-			insertsOne := s.insertRune(w)
-			correctWord := s.findWordWithMaxWeight(insertsOne)
-			if correctWord != "" {
-				return domain.NewDigestReady(correctWord)
-			}
-
-			// Для каждой из однорунных вставок выполняем полный набор однорунных вставок
-			// и проверяем еще и их на наличие в индексе.
-			for _, plusOne := range insertsOne {
-				correctWord = s.findWordWithMaxWeight(s.insertRune(plusOne))
-				if correctWord != "" {
-					return domain.NewDigestReady(correctWord)
-				}
-			}
+		// Выполняем полный набор вставок по одной руне, проверяем на наличие их в индексе,
+		// затем - вставок двух рун и так далее, до максимального расстояния.
+		for depth := 1; depth <= distance && correctWord == ""; depth++ {
+			correctWord = s.findInsert(w, depth)
 		}
+
+		return correctWord == ""
+	})
+
+	return correctWord
+}
+
+// findInsert ищет слово индекса, получающееся из w вставкой depth рун.
+// Для каждой вставки одной руны по очереди выполняется полный набор вставок оставшихся рун,
+// на последнем уровне берется самое частое в индексе слово.
+func (s *Service) findInsert(w []byte, depth int) string {
+	if depth == 1 {
+		return s.findInsertWithMaxWeight(w)
 	}
 
-	return el
+	var res string
+	s.eachInsertRune(w, func(plusOne []byte) bool {
+		res = s.findInsert(plusOne, depth-1)
+
+		return res == ""
+	})
+
+	return res
 }
 
-func (s *Service) insertRune(w string) []string {
-	lang := s.langs.LangByWord(w)
+// eachInsertRune перебирает вставки одной руны алфавита языка w.
+func (s *Service) eachInsertRune(w []byte, fn func(c []byte) bool) bool {
+	lang := s.langs.LangByBytes(w)
 	switch lang {
 	case domain.RuLangCode:
-		return s.mutate.InsertRuneRu(w)
+		return s.mutate.EachInsertRuneRu(w, fn)
 	case domain.EnLangCode:
-		return s.mutate.InsertRuneEn(w)
+		return s.mutate.EachInsertRuneEn(w, fn)
 	case domain.NumLangCode:
-		return []string{w}
+		return fn(w)
 	}
 
-	s.logger.Debug("correctWord: language not detected")
+	s.logger.Debug("correctWordWith: language not detected")
 
-	return nil
+	return true
 }
 
-func (s *Service) findWordWithMaxWeight(words []string) string {
+// findInsertWithMaxWeight отдает самое частое в индексе слово из вставок одной руны в w.
+func (s *Service) findInsertWithMaxWeight(w []byte) string {
 	maxWeight := uint32(0)
 	res := ""
 
-	for _, w := range words {
-		if weight := s.index.Weight(w); weight > maxWeight {
+	s.eachInsertRune(w, func(c []byte) bool {
+		if weight := s.index.WeightBytes(c); weight > maxWeight {
 			maxWeight = weight
-			res = w
+			res = string(c)
 		}
-	}
+
+		return true
+	})
 
 	return res
 }
@@ -1,18 +1,28 @@
 package wordspell
 
 import (
+	"bytes"
+	"context"
+	"flag"
 	"fmt"
+	"io"
+	"os"
+	"path/filepath"
 	"strings"
 	"testing"
 
+	"github.com/pkg/errors"
 	"github.com/stretchr/testify/require"
 
 	"github.com/cannonflesh/wordspell/components/bloomfilter"
+	"github.com/cannonflesh/wordspell/components/deletetable"
 	"github.com/cannonflesh/wordspell/components/index"
 	"github.com/cannonflesh/wordspell/components/langdetect"
+	"github.com/cannonflesh/wordspell/components/manifest"
 	"github.com/cannonflesh/wordspell/components/trademarkindex"
 	"github.com/cannonflesh/wordspell/components/wordmutate"
 	"github.com/cannonflesh/wordspell/domain"
+	s3client "github.com/cannonflesh/wordspell/internal/s3"
 	"github.com/cannonflesh/wordspell/options"
 	"github.com/cannonflesh/wordspell/processors/dimensions"
 	"github.com/cannonflesh/wordspell/processors/dimsuffix"
@@ -20,7 +30,11 @@ import (
 	"github.com/cannonflesh/wordspell/processors/papersizes"
 	"github.com/cannonflesh/wordspell/processors/trademarks"
 	"github.com/cannonflesh/wordspell/processors/units"
+	"github.com/cannonflesh/wordspell/repo/cache"
+	"github.com/cannonflesh/wordspell/repo/compress"
+	"github.com/cannonflesh/wordspell/repo/embedded"
 	"github.com/cannonflesh/wordspell/repo/file"
+	s3source "github.com/cannonflesh/wordspell/repo/s3"
 	"github.com/cannonflesh/wordspell/testdata"
 )
 
@@ -39,23 +53,23 @@ func goldenSpeller(t *testing.T) (*Service, *testdata.Buffer) {
 
 	store := bloomfilter.NewMockDataStore(t)
 
-	preProcessors := []processor{
-		trademarks.New(tm),
-		dimsuffix.New(),
-		dimensions.New(),
-		papersizes.New(),
-		units.New(),
+	preProcessors := []namedProcessor{
+		{name: trademarks.Name, processor: trademarks.New(tm)},
+		{name: dimsuffix.Name, processor: dimsuffix.New()},
+		{name: dimensions.Name, processor: dimensions.New()},
+		{name: papersizes.Name, processor: papersizes.New()},
+		{name: units.Name, processor: units.New()},
 	}
 
-	postProcessors := []processor{
-		dupremove.New(),
+	postProcessors := []namedProcessor{
+		{name: dupremove.Name, processor: dupremove.New()},
 	}
 
 	s := &Service{
 		langs:  langdetect.New(),
 		index:  idx,
 		mutate: wordmutate.New(),
-		bloom:  bloomfilter.New(&bloomfilter.Options{}, store, lgr),
+		bloom:  &bloomFilters{shared: bloomfilter.New(&bloomfilter.Options{}, store, lgr)},
 
 		preProcessors:  preProcessors,
 		postProcessors: postProcessors,
@@ -63,78 +77,78 @@ func goldenSpeller(t *testing.T) (*Service, *testdata.Buffer) {
 		logger: lgr.WithField(domain.CategoryFieldName, "service.word_speller"),
 	}
 
-	err = fillBloomFilter(s.bloom, s.index, s.mutate)
+	_, err = fillBloomFilter(s.bloom.shared, s.index, s.mutate, 4)
 	require.NoError(t, err)
 
 	return s, lbuf
 }
 
-func TestService_correctWord(t *testing.T) {
+func TestService_correctWordWith(t *testing.T) {
 	s, lbuf := goldenSpeller(t)
 
 	t.Run("SuccessShortEn", func(t *testing.T) {
-		correct := s.correctWord("1thф")
+		correct, _ := s.correctWordWith(context.Background(), "1thф", defaultCorrectOptions)
 		require.Equal(t, domain.NewDigestReady("the"), correct)
 	})
 
 	t.Run("SuccessLongEn", func(t *testing.T) {
-		correct := s.correctWord("internati-nalizфtion")
+		correct, _ := s.correctWordWith(context.Background(), "internati-nalizфtion", defaultCorrectOptions)
 		require.Equal(t, domain.NewDigestReady("internationalization"), correct)
 	})
 
 	t.Run("SuccessShortRu", func(t *testing.T) {
-		correct := s.correctWord("ящиг")
+		correct, _ := s.correctWordWith(context.Background(), "ящиг", defaultCorrectOptions)
 		require.Equal(t, domain.NewDigestReady("ящик"), correct)
 
-		correct = s.correctWord("длf")
+		correct, _ = s.correctWordWith(context.Background(), "длf", defaultCorrectOptions)
 		require.Equal(t, domain.NewDigestReady("для"), correct)
 
-		correct = s.correctWord("д1я")
+		correct, _ = s.correctWordWith(context.Background(), "д1я", defaultCorrectOptions)
 		require.Equal(t, domain.NewDigestReady("для"), correct)
 	})
 
 	t.Run("SuccessLongRu", func(t *testing.T) {
-		correct := s.correctWord("безупасност2")
+		correct, _ := s.correctWordWith(context.Background(), "безупасност2", defaultCorrectOptions)
 		require.Equal(t, domain.NewDigestReady("безопасности"), correct)
 	})
 
 	t.Run("NoCheckEn", func(t *testing.T) {
-		correct := s.correctWord("internationalization")
+		correct, _ := s.correctWordWith(context.Background(), "internationalization", defaultCorrectOptions)
 		require.Equal(t, domain.NewDigestReady("internationalization"), correct)
 	})
 
 	t.Run("OneExtraRuneEn", func(t *testing.T) {
-		correct := s.correctWord("internationallization")
+		correct, _ := s.correctWordWith(context.Background(), "internationallization", defaultCorrectOptions)
 		require.Equal(t, domain.NewDigestReady("internationalization"), correct)
 	})
 
 	t.Run("TwoExtraRunesEn", func(t *testing.T) {
-		correct := s.correctWord("interniationallization")
+		correct, _ := s.correctWordWith(context.Background(), "interniationallization", defaultCorrectOptions)
 		require.Equal(t, domain.NewDigestReady("internationalization"), correct)
 	})
 
 	t.Run("NoCheckRu", func(t *testing.T) {
-		correct := s.correctWord("организация")
+		correct, _ := s.correctWordWith(context.Background(), "организация", defaultCorrectOptions)
 		require.Equal(t, domain.NewDigestReady("организация"), correct)
 	})
 
 	t.Run("OneExtraRuneRu", func(t *testing.T) {
-		correct := s.correctWord("организацияя")
+		correct, _ := s.correctWordWith(context.Background(), "организацияя", defaultCorrectOptions)
 		require.Equal(t, domain.NewDigestReady("организация"), correct)
 	})
 
 	t.Run("TwoExtraRunesRu", func(t *testing.T) {
-		correct := s.correctWord("организзацияя")
+		correct, _ := s.correctWordWith(context.Background(), "организзацияя", defaultCorrectOptions)
 		require.Equal(t, domain.NewDigestReady("организация"), correct)
 	})
 
 	t.Run("NoCheckNum", func(t *testing.T) {
-		correct := s.correctWord("1000.345")
+		correct, _ := s.correctWordWith(context.Background(), "1000.345", defaultCorrectOptions)
 		require.Equal(t, domain.NewDigestReady("1000.345"), correct)
 	})
 
 	t.Run("NotInIndex", func(t *testing.T) {
-		correct := s.correctWord("really-not-found")
+		correct, _ := s.correctWordWith(context.Background(), "really-not-found", defaultCorrectOptions)
 		require.Equal(t, domain.NewDigestRaw("really-not-found"), correct)
 	})
 
@@ -148,14 +162,14 @@ func TestService_checkWordPairs(t *testing.T) {
 		req := []string{"органи", "зация"}
 		digest := domain.ParseDigest(req)
 
-		res := s.checkWordPairs(digest)
+		res := s.checkWordPairs(digest, defaultCorrectOptions)
 		require.Equal(t, `(domain.DigestReady):"организация"`, serializeDigest(res))
 	})
 	t.Run("NumLangCodeWordBetweeenPairElements", func(t *testing.T) {
 		req := []string{"органи", "@International#Business#Machines", "зация"}
 		digest := domain.ParseDigest(req)
 
-		res := s.checkWordPairs(digest)
+		res := s.checkWordPairs(digest, defaultCorrectOptions)
 		require.Equal(
 			t,
 			`(domain.DigestRaw):"органи"|(domain.DigestReady):"International Business Machines"|(domain.DigestRaw):"зация"`,
@@ -187,7 +201,7 @@ func TestService_Correct(t *testing.T) {
 	s.index.SetLangIndex(domain.EnLangCode, enIdx)
 	s.index.SetLangIndex(domain.RuLangCode, ruIdx)
 
-	err := fillBloomFilter(s.bloom, s.index, s.mutate)
+	_, err := fillBloomFilter(s.bloom.shared, s.index, s.mutate, 4)
 	require.NoError(t, err)
 
 	t.Run("InternalAsIndividualWord", func(t *testing.T) {
@@ -236,6 +250,189 @@ func TestService_Correct(t *testing.T) {
 	})
 }
 
+func TestService_CorrectWithOptions(t *testing.T) {
+	s, _ := goldenSpeller(t)
+
+	s.index.SetLangIndex(domain.EnLangCode, map[string]uint32{
+		"crux":         1000,
+		"pux":          1000,
+		"inturnationa": 1000,
+	})
+	s.index.SetLangIndex(domain.RuLangCode, map[string]uint32{
+		"пукс":        1000,
+		"пречистый":   1000,
+		"органи":      500,
+		"организация": 1000,
+		"игрушкадля":  100,
+		"игрушка для": 1000,
+	})
+
+	_, err := fillBloomFilter(s.bloom.shared, s.index, s.mutate, 4)
+	require.NoError(t, err)
+
+	for _, tc := range []struct {
+		name     string
+		request  string
+		opt      CorrectOptions
+		expected string
+	}{
+		{name: "Default", request: "crax пакс органи зация", expected: "crux пукс организация"},
+		{name: "SkipPairs", request: "органи зация", opt: CorrectOptions{SkipPairs: true}, expected: "органи зация"},
+		{name: "SkipSplit", request: "игрушкадля", opt: CorrectOptions{SkipSplit: true}, expected: "игрушкадля"},
+		{name: "SkipEdit", request: "crax пакс", opt: CorrectOptions{SkipEdit: true}, expected: "crax пакс"},
+		{
+			name:     "DisabledProcessors",
+			request:  "crax International Business Machines пакс пакс",
+			opt:      CorrectOptions{DisabledProcessors: []string{trademarks.Name, dupremove.Name}},
+			expected: "crux inturnationa Business Machines пукс пукс",
+		},
+		{name: "Langs", request: "crax пакс", opt: CorrectOptions{Langs: []string{domain.RuLangCode}}, expected: "crax пукс"},
+		{name: "DefaultDistance", request: "пречстй", expected: "пречистый"},
+		{name: "MaxDistance", request: "пречстй", opt: CorrectOptions{MaxDistance: 1}, expected: "пречстй"},
+		{name: "DefaultDistanceDeletes", request: "пуксаа", expected: "пукс"},
+		{name: "MaxDistanceDeletes", request: "пуксаа", opt: CorrectOptions{MaxDistance: 1}, expected: "пуксаа"},
+		{name: "MaxDistanceOneDelete", request: "пукса", opt: CorrectOptions{MaxDistance: 1}, expected: "пукс"},
+		{name: "SplitOutweighs", request: "игрушкадля", opt: CorrectOptions{MinWeightRatio: 5}, expected: "игрушка для"},
+		{name: "SplitTooRare", request: "игрушкадля", opt: CorrectOptions{MinWeightRatio: 20}, expected: "игрушкадля"},
+		{name: "PairOutweighs", request: "органи зация", opt: CorrectOptions{MinWeightRatio: 2}, expected: "организация"},
+		{name: "PairTooRare", request: "органи зация", opt: CorrectOptions{MinWeightRatio: 3, SkipEdit: true}, expected: "органи зация"},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expected, s.CorrectWithOptions(tc.request, tc.opt))
+		})
+	}
+
+	require.Equal(t, s.Correct("crax пакс органи зация"), s.CorrectWithOptions("crax пакс органи зация", CorrectOptions{}))
+}
+
+func TestService_newService(t *testing.T) {
+	lgr, _ := testdata.NewTestLogger()
+
+	t.Run("Success", func(t *testing.T) {
+		store, m := goldenGeneration(t)
+
+		s, err := newService(&options.Options{Manifest: manifest.Options{Generation: m.Generation}}, store, lgr)
+		require.NoError(t, err)
+		require.Equal(t, "для", s.Correct("длf"))
+	})
+	t.Run("ReadOnlyStore", func(t *testing.T) {
+		store, _ := goldenGeneration(t)
+		before := dirState(t, store)
+
+		// Сервис не пишет в хранилище: через readOnlyStore записать нечего.
+		s, err := newService(&options.Options{}, &readOnlyStore{store: store}, lgr)
+		require.NoError(t, err)
+		require.Equal(t, "для", s.Correct("длf"))
+		require.Equal(t, before, dirState(t, store))
+	})
+	t.Run("NoManifest", func(t *testing.T) {
+		store := file.New(&file.Options{DataDir: testdata.ThisDir()})
+
+		_, err := newService(&options.Options{}, store, lgr)
+		require.EqualError(t, err, "no manifest found")
+	})
+	t.Run("GenerationMismatch", func(t *testing.T) {
+		store, _ := goldenGeneration(t)
+
+		_, err := newService(&options.Options{Manifest: manifest.Options{Generation: "old"}}, store, lgr)
+		require.ErrorContains(t, err, "manifest generation mismatch")
+	})
+	t.Run("StoreGenerationMismatch", func(t *testing.T) {
+		store, _ := goldenGeneration(t)
+
+		_, err := newService(&options.Options{}, &generationStore{Store: store, generation: "other"}, lgr)
+		require.ErrorContains(t, err, "manifest generation mismatch: expected other")
+	})
+	t.Run("StaleBloomFilter", func(t *testing.T) {
+		store, _ := goldenGeneration(t)
+
+		stale := bloomfilter.New(&bloomfilter.Options{}, store, lgr)
+		stale.Reset(100)
+		stale.Add("хрензначо")
+		require.NoError(t, stale.Save())
+
+		_, err := newService(&options.Options{}, store, lgr)
+		require.ErrorContains(t, err, "artifact bloom.dat: size mismatch")
+	})
+	t.Run("BloomFilterFromAnotherIndex", func(t *testing.T) {
+		store, m := goldenGeneration(t)
+
+		delete(m.Artifacts[bloomfilter.StoreKey].Sources, index.StoreKey(domain.EnLangCode))
+		require.NoError(t, m.Save(store))
+
+		_, err := newService(&options.Options{}, store, lgr)
+		require.EqualError(t, err, "artifact bloom.dat was not built from en.index")
+	})
+}
+
+// readOnlyStore прячет Save хранилища.
+type readOnlyStore struct {
+	store index.ReadOnlyStore
+}
+
+func (s *readOnlyStore) DataReader(key string) (io.ReadCloser, error) {
+	return s.store.DataReader(key)
+}
+
+func (s *readOnlyStore) IsExist(key string) (bool, error) {
+	return s.store.IsExist(key)
+}
+
+// dirState отдает версии всех артефактов хранилища.
+func dirState(t *testing.T, store *file.Store) map[string]string {
+	res := make(map[string]string)
+	for _, key := range []string{"ru.index", "en.index", "trademark.index", bloomfilter.StoreKey, manifest.StoreKey} {
+		v, err := store.Version(key)
+		require.NoError(t, err)
+		res[key] = v
+	}
+
+	return res
+}
+
+type generationStore struct {
+	*file.Store
+	generation string
+}
+
+func (s *generationStore) Generation() string {
+	return s.generation
+}
+
+// goldenGeneration собирает во временном каталоге поколение артефактов по данным из testdata.
+func goldenGeneration(t *testing.T) (*file.Store, *manifest.Manifest) {
+	lgr, _ := testdata.NewTestLogger()
+	store := file.New(&file.Options{DataDir: t.TempDir()})
+	rec := manifest.NewRecorder()
+	recStore := rec.Wrap(store)
+
+	langs := []string{domain.EnLangCode, domain.RuLangCode}
+	keys := []string{"trademark.index"}
+	for _, lang := range langs {
+		keys = append(keys, index.StoreKey(lang))
+	}
+
+	for _, k := range keys {
+		data, err := os.ReadFile(filepath.Join(testdata.ThisDir(), k))
+		require.NoError(t, err)
+		require.NoError(t, recStore.Save(k, bytes.NewReader(data)))
+	}
+
+	idx, err := index.NewService(&options.Options{Langs: langs}, langdetect.New(), store, lgr)
+	require.NoError(t, err)
+
+	bloom := bloomfilter.New(&bloomfilter.Options{}, recStore, lgr)
+	_, err = fillBloomFilter(bloom, idx, wordmutate.New(), 4)
+	require.NoError(t, err)
+	require.NoError(t, bloom.Save())
+	rec.Derive(bloomfilter.StoreKey, keys[1:]...)
+
+	m := rec.Manifest(manifest.NewGeneration(), manifest.BuildOptions{Langs: langs}, idx.WordsCount())
+	require.NoError(t, m.Save(store))
+
+	return store, m
+}
+
 func serializeDigest(dig domain.Digest) string {
 	res := make([]string, 0, len(dig))
 	for _, v := range dig {
@@ -244,3 +441,251 @@ func serializeDigest(dig domain.Digest) string {
 
 	return strings.Join(res, "|")
 }
+
+func TestService_MaxDistance(t *testing.T) {
+	buckets := []wordmutate.DistanceBucket{
+		{MaxLen: 4, MaxDistance: 1},
+		{MaxLen: 12, MaxDistance: 2},
+		{MaxLen: 30, MaxDistance: 3},
+	}
+
+	byDefault := testdataSpeller(t, &options.Options{})
+	// Вставки трех рун за bloom-фильтром слишком дороги для теста: длинные слова в нем не исправляются.
+	bloom := testdataSpeller(t, &options.Options{Mutate: wordmutate.Options{Distances: buckets[:2]}})
+	table := testdataSpeller(t, &options.Options{
+		Mutate:      wordmutate.Options{Distances: buckets},
+		DeleteTable: deletetable.Options{Enabled: true},
+	})
+
+	correct := func(s *Service, w string) string {
+		res, _ := s.correctWordWith(context.Background(), domain.NewDigestRaw(w), defaultCorrectOptions)
+
+		return res.String()
+	}
+
+	t.Run("ShortBucket", func(t *testing.T) {
+		require.Equal(t, "ящик", correct(byDefault, "ящбг"))
+		require.Equal(t, "ящбг", correct(bloom, "ящбг"))
+		require.Equal(t, "ящбг", correct(table, "ящбг"))
+
+		require.Equal(t, "для", correct(bloom, "длf"))
+		require.Equal(t, "для", correct(table, "длf"))
+		require.Equal(t, "ящик", correct(bloom, "ящиг"))
+	})
+
+	t.Run("MiddleBucket", func(t *testing.T) {
+		require.Equal(t, "безопасности", correct(bloom, "безупасност2"))
+		require.Equal(t, "безопасности", correct(table, "безупасност2"))
+		require.Equal(t, "безупаснаст2", correct(table, "безупаснаст2"))
+	})
+
+	t.Run("LongBucket", func(t *testing.T) {
+		require.Equal(t, "internationalization", correct(table, "internati-nalizфtiqn"))
+		require.Equal(t, "internati-nalizфtiqn", correct(byDefault, "internati-nalizфtiqn"))
+		require.Equal(t, "intxrnati-nalizфtiqn", correct(table, "intxrnati-nalizфtiqn"))
+	})
+
+	t.Run("TooLong", func(t *testing.T) {
+		require.Equal(t, "internationalization", correct(byDefault, "internati-nalizфtion"))
+		require.Equal(t, "internati-nalizфtion", correct(bloom, "internati-nalizфtion"))
+	})
+
+	t.Run("ManifestDistances", func(t *testing.T) {
+		mutate, err := wordmutate.NewWithOptions(&wordmutate.Options{Distances: buckets})
+		require.NoError(t, err)
+
+		m := &manifest.Manifest{}
+		require.NoError(t, requireDistances(m, wordmutate.New()))
+		require.EqualError(t, requireDistances(m, mutate),
+			"bloom filter was built with max edit distances 1:0,24:2, configured 4:1,12:2,30:3")
+
+		m.Options.Distances = mutate.Policy()
+		require.NoError(t, requireDistances(m, mutate))
+	})
+}
+
+func TestService_cachedStore(t *testing.T) {
+	l, _ := testdata.NewTestLogger()
+
+	t.Run("Permanent", func(t *testing.T) {
+		// Неверный адрес s3: ошибка настройки не прячется за локальным кешем.
+		opt := &options.Options{
+			S3Client: s3client.Options{Endpoint: "%%", AccessKeyID: "key", SecretAccessKey: "secret"},
+			Cache:    cache.Options{Dir: t.TempDir()},
+		}
+
+		_, cached, err := cachedStore(opt, l)
+		require.Error(t, err)
+		require.False(t, domain.IsTransient(err))
+		require.Nil(t, cached)
+	})
+	t.Run("Transient", func(t *testing.T) {
+		// s3 недоступен: артефакты читаются из кеша.
+		opt := &options.Options{
+			S3Client: s3client.Options{
+				Endpoint:        "127.0.0.1:1",
+				AccessKeyID:     "key",
+				SecretAccessKey: "secret",
+				RetriesCount:    1,
+				RetryTimeout:    1,
+			},
+			S3Data: s3source.Options{Bucket: "wordspell-index"},
+			Cache:  cache.Options{Dir: t.TempDir()},
+		}
+
+		store, cached, err := cachedStore(opt, l)
+		require.NoError(t, err)
+		require.NotNil(t, cached)
+		require.Equal(t, cached, store)
+	})
+}
+
+// flakyStore - хранилище поколения, которое отказывает на чтении артефакта failKey.
+type flakyStore struct {
+	generationStore
+	failKey string
+}
+
+func (s *flakyStore) DataReader(key string) (io.ReadCloser, error) {
+	if key == s.failKey {
+		return nil, domain.NewTransientError(errors.New("connection reset"))
+	}
+
+	return s.generationStore.DataReader(key)
+}
+
+func TestService_startService_CacheFallback(t *testing.T) {
+	lgr, lbuf := testdata.NewTestLogger()
+	opt := &options.Options{Cache: cache.Options{Dir: t.TempDir()}}
+
+	store, m := goldenGeneration(t)
+	cached, err := cache.New(&opt.Cache, &generationStore{Store: store, generation: m.Generation}, lgr)
+	require.NoError(t, err)
+
+	s, err := startService(opt, cached, cached, lgr)
+	require.NoError(t, err)
+	require.Equal(t, "для", s.Correct("длf"))
+
+	// s3 отказал посреди скачивания следующего поколения: спеллер стартует с предыдущим, полностью скачанным.
+	next, nextManifest := goldenGeneration(t)
+	cached, err = cache.New(&opt.Cache, &flakyStore{
+		generationStore: generationStore{Store: next, generation: nextManifest.Generation},
+		failKey:         bloomfilter.StoreKey,
+	}, lgr)
+	require.NoError(t, err)
+
+	s, err = startService(opt, cached, cached, lgr)
+	require.NoError(t, err)
+	require.Equal(t, "для", s.Correct("длf"))
+	require.Contains(t, lbuf.String(), "serving the last complete generation from local cache")
+	require.Equal(t, 2, strings.Count(lbuf.String(), "manifest verified, generation: "+m.Generation))
+
+	// Постоянные ошибки кеш не прячет.
+	cached, err = cache.New(&opt.Cache, &generationStore{Store: next, generation: "other"}, lgr)
+	require.NoError(t, err)
+
+	_, err = startService(opt, cached, cached, lgr)
+	require.ErrorContains(t, err, "manifest generation mismatch")
+}
+
+func TestService_loadManifest_Codec(t *testing.T) {
+	lgr, _ := testdata.NewTestLogger()
+
+	golden, m := goldenGeneration(t)
+
+	// Поколение сжато zstd, манифест лежит несжатым и называет алгоритм.
+	dir := t.TempDir()
+	zstdStore, err := compress.New(&compress.Options{Codec: compress.CodecZstd}, file.New(&file.Options{DataDir: dir}))
+	require.NoError(t, err)
+	for _, k := range m.Keys() {
+		dr, err := golden.DataReader(k)
+		require.NoError(t, err)
+		require.NoError(t, zstdStore.Save(k, dr))
+		require.NoError(t, dr.Close())
+	}
+	m.Options.Compression = compress.CodecZstd
+	require.NoError(t, m.Save(zstdStore.Raw()))
+
+	// Спеллер настроен на gzip, но читает артефакты сразу под ключами .zst.
+	inner := &probingStore{Store: file.New(&file.Options{DataDir: dir})}
+	store, err := compress.New(&compress.Options{Codec: compress.CodecGzip}, inner)
+	require.NoError(t, err)
+
+	s, err := newService(&options.Options{}, store, lgr)
+	require.NoError(t, err)
+	require.Equal(t, "для", s.Correct("длf"))
+	for _, key := range inner.probed {
+		require.False(t, strings.HasSuffix(key, ".gz"), key)
+	}
+}
+
+// probingStore запоминает ключи, которые проверялись через IsExist.
+type probingStore struct {
+	*file.Store
+	probed []string
+}
+
+func (p *probingStore) IsExist(key string) (bool, error) {
+	p.probed = append(p.probed, key)
+
+	return p.Store.IsExist(key)
+}
+
+var update = flag.Bool("update", false, "rebuild the embedded test generation in testdata")
+
+// buildEmbeddedGeneration пересобирает testdata.Generation по тестовому каталогу.
+func buildEmbeddedGeneration(t *testing.T, opt *options.Options) {
+	l, _ := testdata.NewTestLogger()
+
+	itemNames, itemDesc, catNames, tms, err := testdata.CatalogData()
+	require.NoError(t, err)
+
+	idxSrc := index.NewMockDataSource(t)
+	idxSrc.EXPECT().ItemData(0, 100000).Return(itemNames, itemDesc, nil).Once()
+	idxSrc.EXPECT().CategoryNames(0, 10000).Return(catNames, nil).Once()
+
+	tmSrc := trademarkindex.NewMockDataSource(t)
+	tmSrc.EXPECT().TradeMarkNames(0, 5000).Return(tms, nil).Once()
+
+	dir := filepath.Join(testdata.ThisDir(), testdata.GenerationDir)
+	require.NoError(t, os.RemoveAll(dir))
+	require.NoError(t, os.MkdirAll(dir, 0755))
+
+	b := &Builder{
+		opt:             opt,
+		itemSource:      idxSrc,
+		tradeMarkSource: tmSrc,
+		langs:           langdetect.New(),
+		generationStore: func(string) (index.DataStore, error) {
+			return file.New(&file.Options{DataDir: dir}), nil
+		},
+		logger: l,
+	}
+	require.NoError(t, b.Build())
+}
+
+func TestNewFromStore_Embedded(t *testing.T) {
+	lgr, _ := testdata.NewTestLogger()
+
+	opt := &options.Options{
+		Bloom: bloomfilter.Options{
+			FalsePositiveRate: 0.01,
+			Rare:              bloomfilter.RareOptions{Enabled: true},
+		},
+		Langs: []string{domain.RuLangCode, domain.EnLangCode},
+	}
+	if *update {
+		buildEmbeddedGeneration(t, opt)
+	}
+
+	// Спеллер работает без s3: все артефакты поколения вкомпилированы в бинарник.
+	store := embedded.New(testdata.Generation())
+	m, err := manifest.Load(store)
+	require.NoError(t, err)
+	require.Equal(t, []string{bloomfilter.StoreKey, "en.index", bloomfilter.RareStoreKey, "ru.index", "trademark.index"}, m.Keys())
+
+	s, err := NewFromStore(opt, store, lgr)
+	require.NoError(t, err)
+	require.NotNil(t, s.rare)
+	require.Equal(t, "для", s.Correct("длf"))
+}
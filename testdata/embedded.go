@@ -0,0 +1,29 @@
+package testdata
+
+import (
+	"embed"
+	"io/fs"
+)
+
+// Indexes - тестовые индексы, вкомпилированные в бинарник.
+//
+//go:embed ru.index en.index trademark.index
+var Indexes embed.FS
+
+//go:embed generation
+var generation embed.FS
+
+// GenerationDir - каталог полного поколения артефактов в testdata.
+const GenerationDir = "generation"
+
+// Generation отдает вкомпилированное в бинарник полное поколение артефактов, собранное по тестовому каталогу:
+// индексы, bloom-фильтр, фильтр редких слов и манифест.
+// Пересобирается командой go test -run TestNewFromStore_Embedded -update в корне модуля.
+func Generation() fs.FS {
+	res, err := fs.Sub(generation, GenerationDir)
+	if err != nil {
+		panic(err)
+	}
+
+	return res
+}